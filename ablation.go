@@ -0,0 +1,222 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonField describes one JSON-visible field of a Kubernetes API struct.
+type jsonField struct {
+	typ reflect.Type
+	// optional is true when the field is tagged omitempty/omitzero. Kubernetes API
+	// types follow the convention that required fields are serialized without
+	// omitempty, so those are never removed.
+	optional bool
+}
+
+// ablationPath addresses one node in a generic JSON tree. Each element is either
+// a map key (string) or an array index (int).
+type ablationPath []interface{}
+
+func (p ablationPath) String() string {
+	var b strings.Builder
+	for _, elem := range p {
+		switch e := elem.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(e)
+		case int:
+			fmt.Fprintf(&b, "[%d]", e)
+		}
+	}
+	return b.String()
+}
+
+// ablate generates one variant of baseJSON per optional field set in it, with
+// that field (and its whole subtree) removed. Fields are resolved against
+// objType through their json tags, so only fields known to the API type are
+// considered and fields the API marks as required are kept.
+func ablate(baseJSON []byte, objType reflect.Type) ([][]byte, error) {
+	log.Println("=== ABLATION (REMOVE ONE FIELD AT A TIME) ===")
+
+	var baseData interface{}
+	if err := stdjson.Unmarshal(baseJSON, &baseData); err != nil {
+		log.Printf("Failed to parse base JSON: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal base JSON: %w", err)
+	}
+
+	paths := collectAblationPaths(baseData, objType, nil)
+	results := make([][]byte, 0, len(paths))
+
+	for _, path := range paths {
+		// Work on a fresh copy of the base for every variant
+		var variant interface{}
+		if err := stdjson.Unmarshal(baseJSON, &variant); err != nil {
+			return nil, fmt.Errorf("failed to copy base JSON: %w", err)
+		}
+
+		if !removeAtPath(variant, path) {
+			log.Printf("  [ABLATE SKIP] %s: path not found", path)
+			continue
+		}
+
+		resultJSON, err := stdjson.MarshalIndent(variant, "", "  ")
+		if err != nil {
+			log.Printf("Failed to marshal ablated result %s: %v", path, err)
+			return nil, err
+		}
+
+		log.Printf("  [ABLATE] %s: removed", path)
+		results = append(results, resultJSON)
+	}
+
+	log.Printf("\n=== ABLATION COMPLETE: Generated %d results ===", len(results))
+	return results, nil
+}
+
+// collectAblationPaths walks data alongside t and returns the path of every
+// optional struct field present in data. Entries of maps (labels, resource
+// lists, ...) are data rather than API fields, so they are only descended into.
+func collectAblationPaths(data interface{}, t reflect.Type, path ablationPath) []ablationPath {
+	t = derefType(t)
+	if t == nil {
+		return nil
+	}
+
+	var paths []ablationPath
+
+	switch node := data.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFieldsOf(t)
+			for _, key := range keys {
+				field, ok := fields[key]
+				if !ok {
+					continue
+				}
+				childPath := appendPath(path, key)
+				if field.optional {
+					paths = append(paths, childPath)
+				}
+				paths = append(paths, collectAblationPaths(node[key], field.typ, childPath)...)
+			}
+		case reflect.Map:
+			for _, key := range keys {
+				paths = append(paths, collectAblationPaths(node[key], t.Elem(), appendPath(path, key))...)
+			}
+		}
+
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, elem := range node {
+				paths = append(paths, collectAblationPaths(elem, t.Elem(), appendPath(path, i))...)
+			}
+		}
+	}
+
+	return paths
+}
+
+// jsonFieldsOf returns the JSON-visible fields of struct type t keyed by their
+// JSON name. Embedded structs without a name (e.g. ProbeHandler in v1.Probe,
+// tagged `json:",inline"`) are flattened into their parent.
+func jsonFieldsOf(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" {
+			if embedded := derefType(sf.Type); embedded != nil && embedded.Kind() == reflect.Struct {
+				for k, v := range jsonFieldsOf(embedded) {
+					fields[k] = v
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields[name] = jsonField{
+			typ:      sf.Type,
+			optional: strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero"),
+		}
+	}
+
+	return fields
+}
+
+// removeAtPath deletes the node addressed by path from data. Only map entries
+// can be removed; it reports whether anything was deleted.
+func removeAtPath(data interface{}, path ablationPath) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	parent := data
+	for _, elem := range path[:len(path)-1] {
+		switch e := elem.(type) {
+		case string:
+			m, ok := parent.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			parent = m[e]
+		case int:
+			arr, ok := parent.([]interface{})
+			if !ok || e >= len(arr) {
+				return false
+			}
+			parent = arr[e]
+		}
+	}
+
+	key, ok := path[len(path)-1].(string)
+	if !ok {
+		return false
+	}
+	m, ok := parent.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, exists := m[key]; !exists {
+		return false
+	}
+	delete(m, key)
+	return true
+}
+
+func appendPath(path ablationPath, elem interface{}) ablationPath {
+	out := make(ablationPath, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
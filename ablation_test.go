@@ -0,0 +1,89 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+func TestAblateSkipsRequiredFields(t *testing.T) {
+	probe := v1.Probe{
+		ProbeHandler: v1.ProbeHandler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: "/healthz",
+				Port: intstr.FromInt32(8080),
+			},
+		},
+		InitialDelaySeconds: 15,
+		FailureThreshold:    1,
+	}
+	baseJSON, err := stdjson.Marshal(probe)
+	if err != nil {
+		t.Fatalf("failed to marshal probe: %v", err)
+	}
+
+	results, err := ablate(baseJSON, reflect.TypeOf(probe))
+	if err != nil {
+		t.Fatalf("ablate failed: %v", err)
+	}
+
+	var removed []string
+	for _, r := range results {
+		var got v1.Probe
+		if err := stdjson.Unmarshal(r, &got); err != nil {
+			t.Fatalf("failed to unmarshal ablated probe: %v", err)
+		}
+		switch {
+		case got.HTTPGet == nil:
+			removed = append(removed, "httpGet")
+		case got.HTTPGet.Path == "":
+			removed = append(removed, "httpGet.path")
+		case got.InitialDelaySeconds == 0:
+			removed = append(removed, "initialDelaySeconds")
+		case got.FailureThreshold == 0:
+			removed = append(removed, "failureThreshold")
+		case got.HTTPGet.Port.IntValue() == 0:
+			t.Errorf("required field httpGet.port was removed")
+		}
+	}
+	sort.Strings(removed)
+
+	want := []string{"failureThreshold", "httpGet", "httpGet.path", "initialDelaySeconds"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("ablated fields = %v, want %v", removed, want)
+	}
+}
+
+func TestGenerateEffectiveConfigAblation(t *testing.T) {
+	item := ctestglobals.HardcodedConfigItem{
+		FixtureFileName: "test_fixture.json",
+		TestInfo:        []string{"ablation of container fields"},
+		Field:           "containers",
+		K8sObjects:      []string{"pods"},
+		HardcodedConfig: v1.Container{
+			Name:            "test-container",
+			Image:           "busybox",
+			Command:         []string{"/bin/sleep"},
+			ImagePullPolicy: v1.PullIfNotPresent,
+		},
+	}
+
+	configObjs, _, err := GenerateEffectiveConfigReturnType[v1.Container](item, Ablation)
+	if err != nil {
+		t.Fatalf("GenerateEffectiveConfigReturnType failed: %v", err)
+	}
+	// name is required, image/command/imagePullPolicy are optional
+	if len(configObjs) != 3 {
+		t.Fatalf("expected 3 ablated configs, got %d", len(configObjs))
+	}
+	for i, c := range configObjs {
+		if c.Name != "test-container" {
+			t.Errorf("config %d: required field name was removed", i)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package activation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestActivateCtest reports the activation state of every package under
+// ACTIVATE_TARGET and, when ACTIVATE_MODE is set, puts them into that mode.
+// Packages that would not build in the new mode are left alone unless
+// ACTIVATE_FORCE=true.
+func TestActivateCtest(t *testing.T) {
+	k8sRoot := os.Getenv("K8S_ROOT")
+	fmt.Println("K8S_ROOT:", k8sRoot)
+	if k8sRoot == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			t.Fatalf("failed to get current working dir: %v", err)
+		}
+		k8sRoot = filepath.Clean(filepath.Join(cwd, "../../.."))
+	}
+
+	target := os.Getenv("ACTIVATE_TARGET")
+	if target == "" {
+		target = "test/e2e" // default folder
+	}
+	absTarget := target
+	if !filepath.IsAbs(target) {
+		absTarget = filepath.Join(k8sRoot, target)
+	}
+
+	var mode Mode
+	if v := os.Getenv("ACTIVATE_MODE"); v != "" {
+		var err error
+		if mode, err = ParseMode(v); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	force := strings.EqualFold(os.Getenv("ACTIVATE_FORCE"), "true")
+
+	pkgs, err := Scan(absTarget)
+	if err != nil {
+		t.Fatalf("failed to scan %s: %v", absTarget, err)
+	}
+
+	changed, refused := 0, 0
+	states := make(map[Mode]int)
+	for _, pkg := range pkgs {
+		rel, _ := filepath.Rel(k8sRoot, pkg.Dir)
+		if mode != "" && len(pkg.Pairs) > 0 && pkg.Mode != mode {
+			changes, problems, err := Apply(pkg.Dir, mode, force)
+			for _, p := range problems {
+				t.Logf("   ⚠️  %s", p)
+			}
+			if err != nil {
+				t.Errorf("❌ %v", err)
+				refused++
+			} else {
+				t.Logf("🔀 %s: %s -> %s (%d tag changes)", rel, pkg.Mode, mode, len(changes))
+				changed++
+			}
+			if pkg, err = Inspect(pkg.Dir); err != nil {
+				t.Fatalf("failed to inspect %s: %v", rel, err)
+			}
+		}
+
+		states[pkg.Mode]++
+		if len(pkg.Pairs) == 0 {
+			t.Logf("📦 %s: no rewritten files", rel)
+		} else {
+			t.Logf("📦 %s: %s, %d rewritten file(s)", rel, pkg.Mode, len(pkg.Pairs))
+		}
+		for _, o := range pkg.Orphans {
+			t.Logf("   ⚠️  orphan: %s", filepath.Base(o))
+		}
+		for _, p := range pkg.Problems {
+			t.Logf("   ⚠️  %s", p)
+		}
+	}
+
+	t.Log("===================================")
+	t.Logf("Activation Summary")
+	t.Logf("Packages          : %d", len(pkgs))
+	for _, m := range []Mode{ModeReplace, ModeSideBySide, ModeDisabled, ModeMixed, ModeBroken} {
+		t.Logf("  %-15s : %d", m, states[m])
+	}
+	if mode != "" {
+		t.Logf("Switched to %-6s : %d", mode, changed)
+		t.Logf("Refused           : %d", refused)
+	}
+	t.Log("===================================")
+}
@@ -0,0 +1,307 @@
+// Package activation switches packages between their original tests and the
+// ctest_ variants written by test_rewrite.
+//
+// A rewritten file ctest_foo_test.go sits next to its original foo_test.go.
+// Which of the two is built is controlled by build tags:
+//
+//	replace       the original is tagged "original", only the ctest_ file builds
+//	side-by-side  neither is tagged, both build (symbols must not collide)
+//	disabled      the ctest_ file is tagged "ctest", only the original builds
+//
+// Either file can still be run with -tags original or -tags ctest.
+package activation
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// Build tags excluding a file from the default build.
+const (
+	OriginalTag = "original"
+	CtestTag    = "ctest"
+)
+
+// Mode is the activation state of a pair of files or a package.
+type Mode string
+
+const (
+	ModeReplace    Mode = "replace"
+	ModeSideBySide Mode = "side-by-side"
+	ModeDisabled   Mode = "disabled"
+	// ModeBroken means both files are tagged and neither builds.
+	ModeBroken Mode = "broken"
+	// ModeMixed is a package whose pairs are in different modes.
+	ModeMixed Mode = "mixed"
+)
+
+// ParseMode parses a mode that can be applied: replace, side-by-side or disabled.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case ModeReplace, ModeSideBySide, ModeDisabled:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown activation mode %q, expected %s, %s or %s", s, ModeReplace, ModeSideBySide, ModeDisabled)
+}
+
+// Pair is an original test file and its rewrite.
+type Pair struct {
+	Original  string
+	Rewritten string
+	Mode      Mode
+}
+
+// Package is the activation state of one directory.
+type Package struct {
+	Dir   string
+	Pairs []Pair
+	Mode  Mode
+	// Orphans are files tagged "original" without a ctest_ file, whose tests
+	// are not run at all, and ctest_ files without an original.
+	Orphans []string
+	// Problems are the collisions and missing symbols of the current mode.
+	Problems []Problem
+}
+
+// Change is one build tag edit of a plan.
+type Change struct {
+	File string
+	Tag  string
+	// Add is true when the tag is added, false when it is removed.
+	Add bool
+}
+
+func (c Change) String() string {
+	if c.Add {
+		return fmt.Sprintf("tag %s with %q", c.File, c.Tag)
+	}
+	return fmt.Sprintf("remove tag %q from %s", c.Tag, c.File)
+}
+
+// Inspect returns the state of the package in dir.
+func Inspect(dir string) (*Package, error) {
+	files, err := goFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &Package{Dir: dir}
+	names := make(map[string]bool)
+	for _, f := range files {
+		names[f.name] = true
+	}
+	for _, f := range files {
+		original, isRewrite := strings.CutPrefix(f.name, walker.RewritePrefix)
+		switch {
+		case isRewrite && names[original]:
+			orig := files[indexOf(files, original)]
+			pkg.Pairs = append(pkg.Pairs, Pair{
+				Original:  filepath.Join(dir, original),
+				Rewritten: filepath.Join(dir, f.name),
+				Mode:      pairMode(orig.tags[OriginalTag], f.tags[CtestTag]),
+			})
+		case isRewrite:
+			pkg.Orphans = append(pkg.Orphans, filepath.Join(dir, f.name))
+		case f.tags[OriginalTag] && !names[walker.RewritePrefix+f.name]:
+			pkg.Orphans = append(pkg.Orphans, filepath.Join(dir, f.name))
+		}
+	}
+	for i, p := range pkg.Pairs {
+		if i == 0 {
+			pkg.Mode = p.Mode
+		} else if p.Mode != pkg.Mode {
+			pkg.Mode = ModeMixed
+		}
+	}
+	pkg.Problems = checkSymbols(files, func(f *goFile) bool { return f.active() })
+	return pkg, nil
+}
+
+// Scan inspects every directory under root that holds ctest_ files or files
+// tagged "original".
+func Scan(root string) ([]*Package, error) {
+	dirs := make(map[string]bool)
+	err := walker.New(walker.Rewrites).Walk(root, func(path string, d fs.DirEntry) error {
+		if !strings.HasSuffix(path, ".go") || dirs[filepath.Dir(path)] {
+			return nil
+		}
+		if walker.IsRewrite(path) {
+			dirs[filepath.Dir(path)] = true
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if HasTag(src, OriginalTag) {
+			dirs[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sorted []string
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+	var pkgs []*Package
+	for _, dir := range sorted {
+		pkg, err := Inspect(dir)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// Plan returns the tag changes putting every pair in dir into mode and the
+// problems the package would have in that mode.
+func Plan(dir string, mode Mode) ([]Change, []Problem, error) {
+	if _, err := ParseMode(string(mode)); err != nil {
+		return nil, nil, err
+	}
+	files, err := goFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wantOriginal, wantCtest := mode == ModeReplace, mode == ModeDisabled
+	var changes []Change
+	paired := make(map[string]bool)
+	for _, f := range files {
+		original, ok := strings.CutPrefix(f.name, walker.RewritePrefix)
+		if !ok || indexOf(files, original) < 0 {
+			continue
+		}
+		orig := files[indexOf(files, original)]
+		paired[orig.name], paired[f.name] = true, true
+		if orig.tags[OriginalTag] != wantOriginal {
+			changes = append(changes, Change{File: filepath.Join(dir, orig.name), Tag: OriginalTag, Add: wantOriginal})
+		}
+		if f.tags[CtestTag] != wantCtest {
+			changes = append(changes, Change{File: filepath.Join(dir, f.name), Tag: CtestTag, Add: wantCtest})
+		}
+	}
+
+	// The build after the changes
+	problems := checkSymbols(files, func(f *goFile) bool {
+		if !paired[f.name] {
+			return f.active()
+		}
+		if walker.IsRewrite(f.name) {
+			return f.otherTagsMatch && !wantCtest
+		}
+		return f.otherTagsMatch && !wantOriginal
+	})
+	return changes, problems, nil
+}
+
+// Apply puts every pair in dir into mode. The package is left untouched when
+// the new mode has problems, unless force is set.
+func Apply(dir string, mode Mode, force bool) ([]Change, []Problem, error) {
+	changes, problems, err := Plan(dir, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(problems) > 0 && !force {
+		return nil, problems, fmt.Errorf("%s would not build in mode %s: %d problem(s)", dir, mode, len(problems))
+	}
+	for i, c := range changes {
+		var err error
+		if c.Add {
+			_, err = AddTag(c.File, c.Tag)
+		} else {
+			_, err = RemoveTag(c.File, c.Tag)
+		}
+		if err != nil {
+			return changes[:i], problems, fmt.Errorf("failed to %s: %w", c, err)
+		}
+	}
+	return changes, problems, nil
+}
+
+func pairMode(originalTagged, ctestTagged bool) Mode {
+	switch {
+	case originalTagged && ctestTagged:
+		return ModeBroken
+	case originalTagged:
+		return ModeReplace
+	case ctestTagged:
+		return ModeDisabled
+	}
+	return ModeSideBySide
+}
+
+// goFile is a parsed Go file of a package directory.
+type goFile struct {
+	name string
+	f    *ast.File
+	// tags says which of OriginalTag and CtestTag the file requires
+	tags map[string]bool
+	// otherTagsMatch is false when the file is excluded from the build on this
+	// platform for other reasons, e.g. a _windows suffix
+	otherTagsMatch bool
+}
+
+func (f *goFile) active() bool {
+	return f.otherTagsMatch && !f.tags[OriginalTag] && !f.tags[CtestTag]
+}
+
+func goFiles(dir string) ([]*goFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ctx := build.Default
+	ctx.BuildTags = []string{OriginalTag, CtestTag}
+	fset := token.NewFileSet()
+
+	var files []*goFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		match, err := ctx.MatchFile(dir, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &goFile{
+			name:           e.Name(),
+			f:              f,
+			tags:           map[string]bool{OriginalTag: HasTag(src, OriginalTag), CtestTag: HasTag(src, CtestTag)},
+			otherTagsMatch: match,
+		})
+	}
+	return files, nil
+}
+
+func indexOf(files []*goFile, name string) int {
+	for i, f := range files {
+		if f.name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,110 @@
+package activation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"foo.go":               "package foo\n\nfunc Double(i int) int { return 2 * i }\n",
+		"a_test.go":            "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { _ = pod() }\n\nfunc pod() int { return Double(1) }\n",
+		"ctest_a_test.go":      "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) { _ = pod() }\n\nfunc pod() int { return Double(2) }\n",
+		"b_test.go":            "package foo\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) {}\n\nfunc helperB() {}\n",
+		"ctest_b_test.go":      "package foo\n\nimport \"testing\"\n\nfunc TestCtestB(t *testing.T) {}\n",
+		"c_test.go":            "package foo\n\nimport \"testing\"\n\nfunc TestC(t *testing.T) { helperB() }\n",
+		"ext_test.go":          "package foo_test\n\nfunc pod() {}\n",
+		"ctest_orphan_test.go": "package foo\n",
+	})
+
+	pkg, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if pkg.Mode != ModeSideBySide || len(pkg.Pairs) != 2 || len(pkg.Orphans) != 1 {
+		t.Errorf("unexpected package %+v", pkg)
+	}
+	// pod is declared in a_test.go and ctest_a_test.go, not in package foo_test
+	if len(pkg.Problems) != 1 || pkg.Problems[0].Kind != ProblemCollision || pkg.Problems[0].Name != "pod" || pkg.Problems[0].Package != "foo" {
+		t.Errorf("unexpected problems %v", pkg.Problems)
+	}
+
+	// c_test.go still needs helperB from the original b_test.go
+	changes, problems, err := Plan(dir, ModeReplace)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(changes) != 2 || !changes[0].Add || changes[0].Tag != OriginalTag {
+		t.Errorf("unexpected changes %v", changes)
+	}
+	if len(problems) != 1 || problems[0].Kind != ProblemMissing || problems[0].Name != "helperB" || !reflect.DeepEqual(problems[0].Files, []string{"c_test.go"}) {
+		t.Errorf("unexpected problems %v", problems)
+	}
+	if _, _, err := Apply(dir, ModeReplace, false); err == nil {
+		t.Fatalf("expected Apply to refuse a mode that does not build")
+	}
+	if pkg, _ := Inspect(dir); pkg.Mode != ModeSideBySide {
+		t.Errorf("refused Apply changed the package to %s", pkg.Mode)
+	}
+
+	writeFiles(t, dir, map[string]string{"ctest_b_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestCtestB(t *testing.T) {}\n\nfunc helperB() {}\n"})
+	if _, _, err := Apply(dir, ModeReplace, false); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	pkg, err = Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if pkg.Mode != ModeReplace || len(pkg.Problems) != 0 {
+		t.Errorf("expected a clean replace, got %+v", pkg)
+	}
+
+	if _, _, err := Apply(dir, ModeDisabled, false); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	pkg, _ = Inspect(dir)
+	if pkg.Mode != ModeDisabled || len(pkg.Problems) != 0 {
+		t.Errorf("expected disabled, got %+v", pkg)
+	}
+	src, _ := os.ReadFile(filepath.Join(dir, "a_test.go"))
+	if strings.Contains(string(src), "go:build") {
+		t.Errorf("original is still tagged:\n%s", src)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c", "vendor/d"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, filepath.Join(root, "a"), map[string]string{"x_test.go": "package a\n", "ctest_x_test.go": "package a\n"})
+	writeFiles(t, filepath.Join(root, "b"), map[string]string{"x_test.go": "//go:build original\n\npackage b\n"})
+	writeFiles(t, filepath.Join(root, "c"), map[string]string{"x_test.go": "package c\n"})
+	writeFiles(t, filepath.Join(root, "vendor/d"), map[string]string{"ctest_x_test.go": "package d\n"})
+
+	pkgs, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Dir != filepath.Join(root, "a") || pkgs[1].Dir != filepath.Join(root, "b") {
+		t.Fatalf("unexpected packages %+v", pkgs)
+	}
+	if len(pkgs[1].Orphans) != 1 {
+		t.Errorf("expected the tagged file without rewrite to be an orphan: %+v", pkgs[1])
+	}
+}
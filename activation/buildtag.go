@@ -0,0 +1,185 @@
+package activation
+
+import (
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+)
+
+// HasTag reports whether the build constraint of src requires tag, i.e. whether
+// tag is a top-level conjunct such as in "//go:build original" or
+// "//go:build original && linux".
+func HasTag(src []byte, tag string) bool {
+	_, expr := findConstraint(src)
+	return expr != nil && hasConjunct(expr, tag)
+}
+
+// AddTag makes the file at path require tag and reports whether it changed.
+func AddTag(path, tag string) (bool, error) {
+	return editFile(path, func(src []byte) ([]byte, error) { return addTag(path, src, tag) })
+}
+
+// RemoveTag drops tag from the build constraint of the file at path and reports
+// whether it changed. A constraint left empty is removed.
+func RemoveTag(path, tag string) (bool, error) {
+	return editFile(path, func(src []byte) ([]byte, error) { return WithoutTag(src, tag), nil })
+}
+
+// WithoutTag returns src with tag dropped from its build constraint, what
+// RemoveTag would write.
+func WithoutTag(src []byte, tag string) []byte {
+	line, expr := findConstraint(src)
+	if line == nil || !hasConjunct(expr, tag) {
+		return src
+	}
+	return replaceConstraint(src, line, dropConjunct(expr, tag))
+}
+
+func editFile(path string, edit func([]byte) ([]byte, error)) (bool, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	out, err := edit(src)
+	if err != nil || bytes.Equal(out, src) {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, out, info.Mode().Perm())
+}
+
+// constraintLine is the //go:build line of a file and the // +build lines kept
+// for old toolchains.
+type constraintLine struct {
+	start, end int // byte range of the //go:build line, including "\n"
+	plus       [][2]int
+}
+
+// findConstraint locates the //go:build line of src, which has to come before
+// the package clause.
+func findConstraint(src []byte) (*constraintLine, constraint.Expr) {
+	var (
+		line *constraintLine
+		expr constraint.Expr
+		plus [][2]int
+	)
+	for off := 0; off < len(src); {
+		end := bytes.IndexByte(src[off:], '\n') + off + 1
+		if end == off {
+			end = len(src)
+		}
+		text := strings.TrimSpace(string(src[off:end]))
+		if strings.HasPrefix(text, "package ") {
+			break
+		}
+		switch {
+		case constraint.IsGoBuild(text) && line == nil:
+			if e, err := constraint.Parse(text); err == nil {
+				line, expr = &constraintLine{start: off, end: end}, e
+			}
+		case constraint.IsPlusBuild(text):
+			plus = append(plus, [2]int{off, end})
+		}
+		off = end
+	}
+	if line != nil {
+		line.plus = plus
+	}
+	return line, expr
+}
+
+func addTag(path string, src []byte, tag string) ([]byte, error) {
+	line, expr := findConstraint(src)
+	if line != nil {
+		if hasConjunct(expr, tag) {
+			return src, nil
+		}
+		return replaceConstraint(src, line, &constraint.AndExpr{X: &constraint.TagExpr{Tag: tag}, Y: expr}), nil
+	}
+
+	// Put the constraint right above the package doc comment, below the license
+	// and the rewrite stamp
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	pos := f.Package
+	if f.Doc != nil {
+		pos = f.Doc.Pos()
+	}
+	off := fset.Position(pos).Offset
+	var out bytes.Buffer
+	out.Write(src[:off])
+	fmt.Fprintf(&out, "//go:build %s\n\n", tag)
+	out.Write(src[off:])
+	return out.Bytes(), nil
+}
+
+// replaceConstraint writes expr in place of the constraint of src, with matching
+// // +build lines if the file had any. A nil expr removes the constraint and the
+// blank line after it.
+func replaceConstraint(src []byte, line *constraintLine, expr constraint.Expr) []byte {
+	var text string
+	if expr != nil {
+		text = "//go:build " + expr.String() + "\n"
+		if len(line.plus) > 0 {
+			plus, err := constraint.PlusBuildLines(expr)
+			if err == nil {
+				text += strings.Join(plus, "\n") + "\n"
+			}
+		}
+	}
+
+	// The //go:build line and the // +build lines are replaced as one block
+	start, end := line.start, line.end
+	for _, p := range line.plus {
+		start, end = min(start, p[0]), max(end, p[1])
+	}
+	if expr == nil && bytes.HasPrefix(src[end:], []byte("\n")) {
+		end++
+	}
+	var out bytes.Buffer
+	out.Write(src[:start])
+	out.WriteString(text)
+	out.Write(src[end:])
+	return out.Bytes()
+}
+
+func hasConjunct(expr constraint.Expr, tag string) bool {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return e.Tag == tag
+	case *constraint.AndExpr:
+		return hasConjunct(e.X, tag) || hasConjunct(e.Y, tag)
+	}
+	return false
+}
+
+// dropConjunct removes the top-level conjunct tag from expr, nil when nothing is
+// left.
+func dropConjunct(expr constraint.Expr, tag string) constraint.Expr {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		if e.Tag == tag {
+			return nil
+		}
+	case *constraint.AndExpr:
+		x, y := dropConjunct(e.X, tag), dropConjunct(e.Y, tag)
+		switch {
+		case x == nil:
+			return y
+		case y == nil:
+			return x
+		}
+		return &constraint.AndExpr{X: x, Y: y}
+	}
+	return expr
+}
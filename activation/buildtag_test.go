@@ -0,0 +1,82 @@
+package activation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddRemoveTag(t *testing.T) {
+	license := "/*\nCopyright 2024 The Kubernetes Authors.\n*/\n\n"
+	for _, tc := range []struct {
+		name   string
+		src    string
+		tagged string
+	}{
+		{
+			name:   "no constraint",
+			src:    license + "// Package foo tests foo.\npackage foo\n",
+			tagged: license + "//go:build original\n\n// Package foo tests foo.\npackage foo\n",
+		},
+		{
+			name:   "stamped rewrite",
+			src:    "// ctest rewrite: prompt v1 (sha256:abc), model m\n\npackage foo\n",
+			tagged: "// ctest rewrite: prompt v1 (sha256:abc), model m\n\n//go:build original\n\npackage foo\n",
+		},
+		{
+			name:   "existing constraint",
+			src:    "//go:build linux || darwin\n\npackage foo\n",
+			tagged: "//go:build original && (linux || darwin)\n\npackage foo\n",
+		},
+		{
+			name:   "plus build lines",
+			src:    "//go:build linux\n// +build linux\n\npackage foo\n",
+			tagged: "//go:build original && linux\n// +build original,linux\n\npackage foo\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "foo_test.go")
+			if err := os.WriteFile(path, []byte(tc.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if changed, err := AddTag(path, OriginalTag); err != nil || !changed {
+				t.Fatalf("AddTag = %v, %v", changed, err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tc.tagged {
+				t.Errorf("tagged file:\n%s\nwant:\n%s", got, tc.tagged)
+			}
+			if !HasTag(got, OriginalTag) || HasTag(got, CtestTag) {
+				t.Errorf("HasTag is wrong for\n%s", got)
+			}
+			if changed, err := AddTag(path, OriginalTag); err != nil || changed {
+				t.Errorf("second AddTag = %v, %v", changed, err)
+			}
+
+			if changed, err := RemoveTag(path, OriginalTag); err != nil || !changed {
+				t.Fatalf("RemoveTag = %v, %v", changed, err)
+			}
+			got, _ = os.ReadFile(path)
+			if string(got) != tc.src {
+				t.Errorf("untagged file:\n%s\nwant:\n%s", got, tc.src)
+			}
+		})
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	for src, want := range map[string]bool{
+		"//go:build original\n\npackage foo\n":             true,
+		"//go:build linux && original\n\npackage foo\n":    true,
+		"//go:build !original\n\npackage foo\n":            false,
+		"//go:build original || linux\n\npackage foo\n":    false,
+		"package foo\n\n//go:build original\n":             false,
+		"// +build original\n\npackage foo\n":              false,
+		"/* header */\n//go:build original\n\npackage foo": true,
+	} {
+		if got := HasTag([]byte(src), OriginalTag); got != want {
+			t.Errorf("HasTag(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package activation
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Problem kinds.
+const (
+	// ProblemCollision is a symbol declared in more than one built file.
+	ProblemCollision = "collision"
+	// ProblemMissing is a symbol used by a built file but only declared in
+	// files left out of the build.
+	ProblemMissing = "missing"
+)
+
+// Problem is a symbol that keeps a package from building in some mode.
+type Problem struct {
+	Kind string
+	// Package is the package clause, which tells foo and foo_test apart
+	Package string
+	Name    string
+	// Files declare the symbol (collision) or use it (missing)
+	Files []string
+}
+
+func (p Problem) String() string {
+	if p.Kind == ProblemCollision {
+		return fmt.Sprintf("%s.%s is declared in %s", p.Package, p.Name, strings.Join(p.Files, ", "))
+	}
+	return fmt.Sprintf("%s.%s is used in %s but declared only in files left out of the build", p.Package, p.Name, strings.Join(p.Files, ", "))
+}
+
+// checkSymbols reports the collisions and missing symbols of the files built
+// according to active, per package clause.
+func checkSymbols(files []*goFile, active func(*goFile) bool) []Problem {
+	type pkgSymbols struct {
+		declared map[string][]string // symbol -> built files declaring it
+		excluded map[string]bool     // symbols of files left out of the build
+		used     map[string][]string // unresolved identifier -> built files using it
+	}
+	pkgs := make(map[string]*pkgSymbols)
+	for _, gf := range files {
+		name := gf.f.Name.Name
+		ps := pkgs[name]
+		if ps == nil {
+			ps = &pkgSymbols{declared: map[string][]string{}, excluded: map[string]bool{}, used: map[string][]string{}}
+			pkgs[name] = ps
+		}
+		if !active(gf) {
+			for _, sym := range declaredSymbols(gf.f) {
+				ps.excluded[sym] = true
+			}
+			continue
+		}
+		for _, sym := range declaredSymbols(gf.f) {
+			ps.declared[sym] = append(ps.declared[sym], gf.name)
+		}
+		seen := make(map[string]bool)
+		for _, id := range gf.f.Unresolved {
+			if !seen[id.Name] {
+				seen[id.Name] = true
+				ps.used[id.Name] = append(ps.used[id.Name], gf.name)
+			}
+		}
+	}
+
+	var problems []Problem
+	for pkg, ps := range pkgs {
+		for sym, in := range ps.declared {
+			if len(in) > 1 {
+				problems = append(problems, Problem{Kind: ProblemCollision, Package: pkg, Name: sym, Files: in})
+			}
+		}
+		for sym, in := range ps.used {
+			if ps.excluded[sym] && len(ps.declared[sym]) == 0 {
+				problems = append(problems, Problem{Kind: ProblemMissing, Package: pkg, Name: sym, Files: in})
+			}
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return problems
+}
+
+// declaredSymbols returns the package-level names declared in f; methods are
+// named Type.Method.
+func declaredSymbols(f *ast.File) []string {
+	var syms []string
+	add := func(id *ast.Ident) {
+		if id.Name != "_" {
+			syms = append(syms, id.Name)
+		}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				if d.Name.Name != "init" {
+					add(d.Name)
+				}
+				continue
+			}
+			if recv := receiverType(d.Recv.List[0].Type); recv != "" {
+				syms = append(syms, recv+"."+d.Name.Name)
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name)
+				case *ast.ValueSpec:
+					for _, id := range s.Names {
+						add(id)
+					}
+				}
+			}
+		}
+	}
+	return syms
+}
+
+func receiverType(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(e.X)
+	case *ast.IndexExpr:
+		return receiverType(e.X)
+	case *ast.IndexListExpr:
+		return receiverType(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// Case is one generated configuration together with a label describing where it
+// came from, e.g. "baseline" for the unmodified hardcoded config or "union-2" for
+// the second config produced by the Union mode.
+type Case[T any] struct {
+	Index  int    `json:"index"`
+	Label  string `json:"label"`
+	Config T      `json:"config"`
+}
+
+// IsBaseline reports whether the case is the unmodified hardcoded config.
+func (c Case[T]) IsBaseline() bool {
+	return c.Label == ctestglobals.BaselineLabel
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ExtendOnly:
+		return "extend"
+	case OverrideOnly:
+		return "override"
+	case Union:
+		return "union"
+	case Ablation:
+		return "ablation"
+	default:
+		return fmt.Sprintf("mode(%d)", int(m))
+	}
+}
+
+// BaselineEnabled reports whether CTEST_INCLUDE_BASELINE asks for the hardcoded
+// config to be run as case 0 by GenerateEffectiveConfigReturnType.
+func BaselineEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv(ctestglobals.IncludeBaselineEnv)), "true")
+}
+
+// CaseLabel returns the label of the i-th config returned by
+// GenerateEffectiveConfigReturnType for the given mode, so rewritten tests can
+// tell the baseline run apart from the mutated ones in their logs.
+func CaseLabel(i int, mode Mode) string {
+	if BaselineEnabled() {
+		if i == 0 {
+			return ctestglobals.BaselineLabel
+		}
+		return fmt.Sprintf("%s-%d", mode, i)
+	}
+	return fmt.Sprintf("%s-%d", mode, i+1)
+}
+
+// GenerateCases works like Generate but always prepends the unmodified hardcoded
+// config as case 0 labeled "baseline", so a test can confirm the original behavior
+// and compare it with the mutated runs. The returned JSON is an array of
+// {"index", "label", "config"} objects.
+func GenerateCases[T any](item ctestglobals.HardcodedConfigItem, opts ...Option) ([]Case[T], []byte, error) {
+	o := newGenerateOptions(opts...)
+	configs, _, err := Generate[T](item, append(opts, WithIncludeOriginal(true))...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cases := make([]Case[T], 0, len(configs))
+	for i, config := range configs {
+		label := ctestglobals.BaselineLabel
+		if i > 0 {
+			label = fmt.Sprintf("%s-%d", o.mode, i)
+		}
+		cases = append(cases, Case[T]{Index: i, Label: label, Config: config})
+	}
+
+	casesJSON, err := stdjson.Marshal(cases)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal cases to JSON: %w", err)
+	}
+	return cases, casesJSON, nil
+}
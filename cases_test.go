@@ -0,0 +1,46 @@
+package ctest
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+func TestGenerateCasesPrependsBaseline(t *testing.T) {
+	cases, _, err := GenerateCases[[]v1.Container](testContainerItem(),
+		WithMode(Union),
+		WithFixtureData(testContainerFixtures(t)),
+	)
+	if err != nil {
+		t.Fatalf("GenerateCases failed: %v", err)
+	}
+	if len(cases) != 4 {
+		t.Fatalf("expected baseline + 3 cases, got %d", len(cases))
+	}
+	if !cases[0].IsBaseline() || cases[0].Config[0].Image != "busybox" {
+		t.Errorf("case 0 is not the baseline: %+v", cases[0])
+	}
+	if cases[1].Label != "union-1" {
+		t.Errorf("case 1 label = %q, want union-1", cases[1].Label)
+	}
+}
+
+func TestBaselineReturnedWhenNothingDiffers(t *testing.T) {
+	t.Setenv(ctestglobals.IncludeBaselineEnv, "true")
+
+	item := testContainerItem()
+	item.HardcodedConfig = v1.Container{Name: "required-only"}
+
+	// Only "name" is set and it is required, so ablation produces no new config
+	configs, _, err := GenerateEffectiveConfigReturnType[v1.Container](item, Ablation)
+	if err != nil {
+		t.Fatalf("GenerateEffectiveConfigReturnType failed: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Name != "required-only" {
+		t.Fatalf("expected only the baseline config, got %+v", configs)
+	}
+	if got := CaseLabel(0, Ablation); got != ctestglobals.BaselineLabel {
+		t.Errorf("CaseLabel(0) = %q, want %q", got, ctestglobals.BaselineLabel)
+	}
+}
@@ -0,0 +1,212 @@
+package clean
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/activation"
+	testrewrite "k8s.io/kubernetes/test/ctest/test_rewrite"
+)
+
+// What a clean run did to a backed up file.
+const (
+	ActionDeleted  = "deleted"
+	ActionUntagged = "untagged"
+)
+
+// BackupManifest is the name of the file listing the contents of a backup.
+const BackupManifest = "backup.json"
+
+// backupTimeFormat names the backup directories; they sort by time.
+const backupTimeFormat = "20060102T150405"
+
+// Backup lists the files one clean run changed, copied under files/ at their
+// path relative to Target.
+type Backup struct {
+	CreatedAt time.Time     `json:"createdAt"`
+	Target    string        `json:"target"`
+	Files     []BackupEntry `json:"files"`
+}
+
+// BackupEntry is one backed up file. Journal is the journal entry of a deleted
+// rewrite, put back by Undo.
+type BackupEntry struct {
+	Path    string                    `json:"path"`
+	Action  string                    `json:"action"`
+	Journal *testrewrite.JournalEntry `json:"journal,omitempty"`
+}
+
+type backup struct {
+	dir string
+	Backup
+}
+
+func newBackup(backupDir, target string) (*backup, error) {
+	now := time.Now()
+	dir := filepath.Join(backupDir, now.Format(backupTimeFormat))
+	for i := 2; ; i++ {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dir = filepath.Join(backupDir, fmt.Sprintf("%s-%d", now.Format(backupTimeFormat), i))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	return &backup{dir: dir, Backup: Backup{CreatedAt: now, Target: target}}, nil
+}
+
+func (b *backup) add(file, action string, entry *testrewrite.JournalEntry) error {
+	rel, err := filepath.Rel(b.Target, file)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(b.dir, "files", rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", file, err)
+	}
+	b.Files = append(b.Files, BackupEntry{Path: filepath.ToSlash(rel), Action: action, Journal: entry})
+	return nil
+}
+
+func (b *backup) save() error {
+	data, err := json.MarshalIndent(b.Backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.dir, BackupManifest), data, 0644)
+}
+
+// LatestBackup returns the newest backup directory in backupDir.
+func LatestBackup(backupDir string) (string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			if _, err := os.Stat(filepath.Join(backupDir, e.Name(), BackupManifest)); err == nil {
+				dirs = append(dirs, e.Name())
+			}
+		}
+	}
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("no backups in %s", backupDir)
+	}
+	sort.Strings(dirs)
+	return filepath.Join(backupDir, dirs[len(dirs)-1]), nil
+}
+
+// UndoOptions configures Undo.
+type UndoOptions struct {
+	// Force overwrites files changed since the clean.
+	Force bool
+	// DryRun only reports what would be restored.
+	DryRun bool
+	// JournalPath is the rewrite journal the entries of deleted rewrites are put
+	// back into; empty leaves the journal alone.
+	JournalPath string
+	// Logf receives one message per file, fmt.Printf style.
+	Logf func(format string, args ...interface{})
+}
+
+// UndoSummary counts the files of an undo. Conflicts are files changed since
+// the clean: a deleted rewrite that exists again with other content, or an
+// untagged original that was edited.
+type UndoSummary struct {
+	Restored  int
+	Unchanged int
+	Conflicts []string
+}
+
+// Undo puts the files of the backup in dir back in place. With conflicts
+// nothing is restored unless opts.Force is set.
+func Undo(dir string, opts UndoOptions) (*UndoSummary, error) {
+	if opts.Logf == nil {
+		opts.Logf = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	}
+	data, err := os.ReadFile(filepath.Join(dir, BackupManifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	var b Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, BackupManifest), err)
+	}
+
+	type restore struct {
+		entry BackupEntry
+		path  string
+		data  []byte
+	}
+	summary := &UndoSummary{}
+	var restores []restore
+	for _, e := range b.Files {
+		saved, err := os.ReadFile(filepath.Join(dir, "files", filepath.FromSlash(e.Path)))
+		if err != nil {
+			return nil, fmt.Errorf("backup is incomplete: %w", err)
+		}
+		file := filepath.Join(b.Target, filepath.FromSlash(e.Path))
+		current, err := os.ReadFile(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		exists := err == nil
+		switch {
+		case exists && bytes.Equal(current, saved):
+			summary.Unchanged++
+			continue
+		case e.Action == ActionDeleted && exists:
+			summary.Conflicts = append(summary.Conflicts, file+": exists again with other content")
+		case e.Action == ActionUntagged && !exists:
+			summary.Conflicts = append(summary.Conflicts, file+": was deleted")
+		case e.Action == ActionUntagged && !bytes.Equal(current, activation.WithoutTag(saved, activation.OriginalTag)):
+			summary.Conflicts = append(summary.Conflicts, file+": was edited")
+		}
+		restores = append(restores, restore{entry: e, path: file, data: saved})
+	}
+	if len(summary.Conflicts) > 0 && !opts.Force {
+		return summary, fmt.Errorf("%d conflicts undoing %s, nothing was restored", len(summary.Conflicts), dir)
+	}
+
+	journal, err := testrewrite.OpenJournal(opts.JournalPath)
+	if err != nil {
+		return summary, err
+	}
+	for _, r := range restores {
+		if opts.DryRun {
+			opts.Logf("♻️  Would restore %s file: %s", r.entry.Action, r.path)
+			summary.Restored++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+			return summary, err
+		}
+		if err := os.WriteFile(r.path, r.data, 0644); err != nil {
+			return summary, fmt.Errorf("failed to restore %s: %w", r.path, err)
+		}
+		if r.entry.Journal != nil {
+			if err := journal.Put(*r.entry.Journal); err != nil {
+				return summary, fmt.Errorf("failed to update journal: %w", err)
+			}
+		}
+		opts.Logf("♻️  Restored %s file: %s", r.entry.Action, r.path)
+		summary.Restored++
+	}
+	return summary, nil
+}
@@ -0,0 +1,304 @@
+// Package clean rolls rewritten tests back: it deletes the ctest_ files and
+// removes the original build tag from the tests they replaced.
+//
+// Rewrites edited by hand since they were generated, and rewrites that cannot
+// be checked for that, are kept unless forced; every file is backed up before
+// it is deleted or untagged, see Undo.
+package clean
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/kubernetes/test/ctest/activation"
+	testrewrite "k8s.io/kubernetes/test/ctest/test_rewrite"
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// Options configures a clean run.
+type Options struct {
+	// DryRun only reports the files that would be deleted and the build tag
+	// changes as diffs.
+	DryRun bool
+	// Force also deletes rewrites that were edited by hand since they were
+	// generated, and rewrites without a recorded generation hash.
+	Force bool
+	// JournalPath is the rewrite journal, which has the generation hash, model
+	// and date of every rewrite. Without it a rewrite cannot be checked for hand
+	// edits, and model and date come from its stamp and modification time.
+	JournalPath string
+	// BackupDir receives a timestamped backup of every file before it is deleted
+	// or untagged; empty disables backups.
+	BackupDir string
+	// Packages, Model, Since and Until select the rewrites to clean; the zero
+	// values select all. Packages are directories relative to the target, a
+	// rewrite is selected when it is in one of them or below, and may use
+	// path.Match globs. Model and the date range match the generation of the
+	// rewrite.
+	Packages []string
+	Model    string
+	Since    time.Time
+	Until    time.Time
+	// Logf receives one message per file, fmt.Printf style.
+	Logf func(format string, args ...interface{})
+}
+
+func (o *Options) filtered() bool {
+	return len(o.Packages) > 0 || o.Model != "" || !o.Since.IsZero() || !o.Until.IsZero()
+}
+
+// Summary counts the files of a clean run. Modified lists the rewrites kept
+// because they were edited by hand, Unverified the rewrites kept because no
+// generation hash is recorded for them. Errors holds the files that could not
+// be deleted or untagged; they are counted as skipped.
+type Summary struct {
+	Deleted    int
+	Cleaned    int
+	Skipped    int
+	Filtered   int
+	Modified   []string
+	Unverified []string
+	// Backup is the backup directory of the run, empty if nothing was backed up.
+	Backup string
+	Errors []error
+}
+
+// generation is what is known about how a rewrite was generated.
+type generation struct {
+	model string
+	date  time.Time
+	// hash is the recorded generation hash, empty if the journal has none
+	hash  string
+	entry *testrewrite.JournalEntry
+}
+
+func generationOf(journal *testrewrite.Journal, original, rewrite string, src []byte) generation {
+	var g generation
+	// An entry of a rewrite written to an overlay directory is not about this
+	// file. A later attempt that wrote nothing keeps the hash of the rewrite.
+	if entry, ok := journal.Get(original); ok && (entry.Output == "" || samePath(entry.Output, rewrite)) {
+		if entry.Status == testrewrite.StatusRewritten {
+			g.entry, g.model, g.date = &entry, entry.Model, entry.UpdatedAt
+		}
+		g.hash = entry.OutputHash
+	}
+	if model := testrewrite.StampModel(src); model != "" {
+		g.model = model
+	}
+	if g.date.IsZero() {
+		if info, err := os.Stat(rewrite); err == nil {
+			g.date = info.ModTime()
+		}
+	}
+	return g
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func (o *Options) selects(rel string, g generation) bool {
+	if len(o.Packages) > 0 && !inPackages(path.Dir(rel), o.Packages) {
+		return false
+	}
+	if o.Model != "" && g.model != o.Model {
+		return false
+	}
+	if !o.Since.IsZero() && g.date.Before(o.Since) {
+		return false
+	}
+	return o.Until.IsZero() || g.date.Before(o.Until)
+}
+
+func inPackages(dir string, packages []string) bool {
+	for _, p := range packages {
+		p = strings.Trim(filepath.ToSlash(p), "/")
+		if ok, _ := path.Match(p, dir); ok || p == "." || dir == p || strings.HasPrefix(dir, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDate parses the -since and -until dates of a clean, either 2006-01-02 or
+// RFC 3339.
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected 2006-01-02 or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// Run cleans the Go files under target.
+func Run(target string, opts Options) (*Summary, error) {
+	if opts.Logf == nil {
+		opts.Logf = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	}
+	journal, err := testrewrite.OpenJournal(opts.JournalPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := walker.New(walker.Rewrites).Files(target, ".go")
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect Go files: %w", err)
+	}
+
+	// Decide which rewrites go before touching anything
+	summary := &Summary{}
+	rewrites := make(map[string]bool) // rewrite path -> deleted
+	var deletes []string
+	entries := make(map[string]*testrewrite.JournalEntry)
+	for _, f := range files {
+		if !walker.IsRewrite(f) {
+			continue
+		}
+		rewrites[f] = false
+		src, err := os.ReadFile(f)
+		if err != nil {
+			summary.Errors = append(summary.Errors, err)
+			summary.Skipped++
+			continue
+		}
+		g := generationOf(journal, walker.OriginalOf(f), f, src)
+		rel, _ := filepath.Rel(target, f)
+		if !opts.selects(filepath.ToSlash(rel), g) {
+			summary.Filtered++
+			continue
+		}
+		switch {
+		case opts.Force:
+		case g.hash == "":
+			opts.Logf("✋ Keeping rewrite without a recorded generation hash: %s", f)
+			summary.Unverified = append(summary.Unverified, f)
+			summary.Skipped++
+			continue
+		case testrewrite.ContentHash(src) != g.hash:
+			opts.Logf("✋ Keeping rewrite edited since it was generated: %s", f)
+			summary.Modified = append(summary.Modified, f)
+			summary.Skipped++
+			continue
+		}
+		rewrites[f] = true
+		deletes = append(deletes, f)
+		entries[f] = g.entry
+	}
+
+	// Originals are untagged when their rewrite goes; tagged files without a
+	// rewrite only when cleaning everything
+	var untags []string
+	for _, f := range files {
+		if walker.IsRewrite(f) {
+			continue
+		}
+		deleted, hasRewrite := rewrites[walker.RewriteOf(f)]
+		if (hasRewrite && !deleted) || (!hasRewrite && opts.filtered()) {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		if err != nil {
+			summary.Errors = append(summary.Errors, err)
+			summary.Skipped++
+			continue
+		}
+		if !activation.HasTag(src, activation.OriginalTag) {
+			summary.Skipped++
+			continue
+		}
+		untags = append(untags, f)
+	}
+
+	if opts.DryRun {
+		for _, f := range deletes {
+			opts.Logf("🗑️  Would delete rewritten file: %s", f)
+			summary.Deleted++
+		}
+		for _, f := range untags {
+			diff, err := tagRemovalDiff(f)
+			if err != nil {
+				summary.Errors = append(summary.Errors, fmt.Errorf("failed checking build tag for %s: %w", f, err))
+				summary.Skipped++
+				continue
+			}
+			opts.Logf("🏷️  Would remove build tag from: %s\n%s", f, diff)
+			summary.Cleaned++
+		}
+		return summary, nil
+	}
+
+	var b *backup
+	if opts.BackupDir != "" && len(deletes)+len(untags) > 0 {
+		if b, err = newBackup(opts.BackupDir, target); err != nil {
+			return nil, err
+		}
+		summary.Backup = b.dir
+		for _, f := range deletes {
+			if err := b.add(f, ActionDeleted, entries[f]); err != nil {
+				return summary, err
+			}
+		}
+		for _, f := range untags {
+			if err := b.add(f, ActionUntagged, nil); err != nil {
+				return summary, err
+			}
+		}
+		if err := b.save(); err != nil {
+			return summary, err
+		}
+	}
+
+	for _, f := range deletes {
+		if err := os.Remove(f); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Errorf("failed to delete %s: %w", f, err))
+			summary.Skipped++
+			continue
+		}
+		opts.Logf("🗑️  Deleted rewritten file: %s", f)
+		summary.Deleted++
+		// Without its rewrite the file is not done any more
+		if err := journal.Delete(walker.OriginalOf(f)); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Errorf("failed to update journal: %w", err))
+		}
+	}
+	for _, f := range untags {
+		if _, err := activation.RemoveTag(f, activation.OriginalTag); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Errorf("failed removing build tag for %s: %w", f, err))
+			summary.Skipped++
+			continue
+		}
+		opts.Logf("🏷️  Removed build tag from: %s", f)
+		summary.Cleaned++
+	}
+	return summary, nil
+}
+
+// tagRemovalDiff returns the diff removing the original build tag from path
+// would make, empty when the file is not tagged.
+func tagRemovalDiff(path string) (string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	out := activation.WithoutTag(src, activation.OriginalTag)
+	if bytes.Equal(out, src) {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(src)),
+		B:        difflib.SplitLines(string(out)),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
+	})
+}
@@ -6,12 +6,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
-
-	ctestutils "k8s.io/kubernetes/test/ctest/utils"
+	"time"
 )
 
-// TestCleanRewrites rolls back all rewritten files to original state
-func TestCleanRewrites(t *testing.T) {
+func k8sRootFromEnv(t *testing.T) string {
 	k8sRoot := os.Getenv("K8S_ROOT")
 	fmt.Println("K8S_ROOT:", k8sRoot)
 	if k8sRoot == "" {
@@ -21,6 +19,33 @@ func TestCleanRewrites(t *testing.T) {
 		}
 		k8sRoot = filepath.Clean(filepath.Join(cwd, "../../.."))
 	}
+	return k8sRoot
+}
+
+// journalAndBackups returns the rewrite journal (REWRITE_JOURNAL) and the
+// backup directory (CLEAN_BACKUP_DIR, set it empty to disable backups).
+func journalAndBackups(k8sRoot string) (string, string) {
+	logs := filepath.Join(k8sRoot, "test", "ctest", "logs")
+	journal, backups := filepath.Join(logs, "rewrite_journal.json"), filepath.Join(logs, "clean_backups")
+	if v, ok := os.LookupEnv("REWRITE_JOURNAL"); ok {
+		journal = v
+	}
+	if v, ok := os.LookupEnv("CLEAN_BACKUP_DIR"); ok {
+		backups = v
+	}
+	return journal, backups
+}
+
+// TestCleanRewrites rolls back all rewritten files to original state. With
+// CLEAN_DRY_RUN=true it only lists the files it would delete and the build tag
+// changes as diffs.
+//
+// Rewrites edited since they were generated are kept unless CLEAN_FORCE=true.
+// CLEAN_PACKAGES (comma-separated directories relative to CLEAN_TARGET),
+// CLEAN_MODEL, CLEAN_SINCE and CLEAN_UNTIL (2006-01-02) select the rewrites to
+// clean. Files are backed up first, see TestUndoClean.
+func TestCleanRewrites(t *testing.T) {
+	k8sRoot := k8sRootFromEnv(t)
 
 	// Default folder
 	target := os.Getenv("CLEAN_TARGET")
@@ -35,90 +60,92 @@ func TestCleanRewrites(t *testing.T) {
 		absTarget = filepath.Join(k8sRoot, target)
 	}
 
-	//absTarget, err := filepath.Abs(target)
-	// if err != nil {
-	// 	t.Fatalf("failed to resolve absolute path: %v", err)
-	// }
-
-	files, err := ctestutils.CollectAllGoFiles(absTarget)
-	if err != nil {
-		t.Fatalf("failed to collect Go files: %v", err)
+	opts := Options{
+		DryRun: strings.EqualFold(os.Getenv("CLEAN_DRY_RUN"), "true"),
+		Force:  strings.EqualFold(os.Getenv("CLEAN_FORCE"), "true"),
+		Model:  os.Getenv("CLEAN_MODEL"),
+		Logf:   t.Logf,
 	}
-
-	deleted := 0
-	cleaned := 0
-	skipped := 0
-
-	for _, f := range files {
-		base := filepath.Base(f)
-
-		// Delete rewritten files
-		if strings.HasPrefix(base, "ctest_") {
-			if err := os.Remove(f); err != nil {
-				t.Errorf("failed to delete %s: %v", f, err)
-			} else {
-				t.Logf("🗑️  Deleted rewritten file: %s", f)
-				deleted++
-			}
-			continue
+	opts.JournalPath, opts.BackupDir = journalAndBackups(k8sRoot)
+	for _, p := range strings.Split(os.Getenv("CLEAN_PACKAGES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			opts.Packages = append(opts.Packages, p)
 		}
-
-		// Remove build tags from original files
-		hasTag, err := hasOriginalBuildTag(f)
-		if err != nil {
-			t.Errorf("failed checking build tag for %s: %v", f, err)
-			skipped++
-			continue
-		}
-
-		if hasTag {
-			if err := removeOriginalBuildTag(f); err != nil {
-				t.Errorf("failed removing build tag for %s: %v", f, err)
-				skipped++
-			} else {
-				t.Logf("🏷️  Removed build tag from: %s", f)
-				cleaned++
+	}
+	for _, d := range []struct {
+		env string
+		dst *time.Time
+	}{{"CLEAN_SINCE", &opts.Since}, {"CLEAN_UNTIL", &opts.Until}} {
+		if v := os.Getenv(d.env); v != "" {
+			date, err := ParseDate(v)
+			if err != nil {
+				t.Fatalf("%s: %v", d.env, err)
 			}
-		} else {
-			skipped++
+			*d.dst = date
 		}
 	}
+	if opts.DryRun {
+		t.Log("Dry run: nothing is deleted or changed")
+	}
+
+	summary, err := Run(absTarget, opts)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, err := range summary.Errors {
+		t.Errorf("%v", err)
+	}
 
 	t.Log("===================================")
 	t.Logf("Clean Summary")
-	t.Logf("Deleted rewritten files : %d", deleted)
-	t.Logf("Cleaned build tags      : %d", cleaned)
-	t.Logf("Skipped files           : %d", skipped)
+	t.Logf("Deleted rewritten files : %d", summary.Deleted)
+	t.Logf("Kept, edited by hand    : %d", len(summary.Modified))
+	t.Logf("Kept, no recorded hash  : %d", len(summary.Unverified))
+	t.Logf("Not selected            : %d", summary.Filtered)
+	t.Logf("Cleaned build tags      : %d", summary.Cleaned)
+	t.Logf("Skipped files           : %d", summary.Skipped)
+	if summary.Backup != "" {
+		t.Logf("Backup                  : %s", summary.Backup)
+	}
 	t.Log("===================================")
 }
 
-func hasOriginalBuildTag(path string) (bool, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return false, err
+// TestUndoClean restores the backup CLEAN_UNDO, a backup directory or "latest"
+// for the newest one in CLEAN_BACKUP_DIR. Files changed since the clean stop the
+// undo unless CLEAN_FORCE=true.
+func TestUndoClean(t *testing.T) {
+	k8sRoot := k8sRootFromEnv(t)
+	dir := os.Getenv("CLEAN_UNDO")
+	if dir == "" {
+		t.Skip("CLEAN_UNDO is not set")
 	}
-
-	content := string(data)
-
-	return strings.HasPrefix(content, "//go:build original") ||
-		strings.HasPrefix(content, "// +build original"), nil
-}
-
-func removeOriginalBuildTag(path string) error {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return err
+	journal, backups := journalAndBackups(k8sRoot)
+	if dir == "latest" {
+		var err error
+		if dir, err = LatestBackup(backups); err != nil {
+			t.Fatalf("%v", err)
+		}
 	}
 
-	content := string(data)
-	lines := strings.Split(content, "\n")
-	var newLines []string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "//go:build original") || strings.HasPrefix(line, "// +build original") {
-			continue
+	summary, err := Undo(dir, UndoOptions{
+		Force:       strings.EqualFold(os.Getenv("CLEAN_FORCE"), "true"),
+		DryRun:      strings.EqualFold(os.Getenv("CLEAN_DRY_RUN"), "true"),
+		JournalPath: journal,
+		Logf:        t.Logf,
+	})
+	if summary != nil {
+		for _, c := range summary.Conflicts {
+			t.Logf("⚠️  %s", c)
 		}
-		newLines = append(newLines, line)
+	}
+	if err != nil {
+		t.Fatalf("%v", err)
 	}
 
-	return os.WriteFile(path, []byte(strings.Join(newLines, "\n")), 0644)
+	t.Log("===================================")
+	t.Logf("Undo Summary (%s)", dir)
+	t.Logf("Restored files : %d", summary.Restored)
+	t.Logf("Unchanged      : %d", summary.Unchanged)
+	t.Logf("Conflicts      : %d", len(summary.Conflicts))
+	t.Log("===================================")
 }
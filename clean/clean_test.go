@@ -0,0 +1,216 @@
+package clean
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/activation"
+	testrewrite "k8s.io/kubernetes/test/ctest/test_rewrite"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunAndUndo(t *testing.T) {
+	target := t.TempDir()
+	tagged := "//go:build original\n\npackage %s\n"
+	rewrite := "// ctest rewrite: prompt v1 (sha256:abc), model %s\n\npackage %s\n"
+	files := map[string]string{
+		"apps/job_test.go":        strings.ReplaceAll(tagged, "%s", "apps"),
+		"apps/ctest_job_test.go":  "// ctest rewrite: prompt v1 (sha256:abc), model m1\n\npackage apps\n",
+		"apps/pod_test.go":        strings.ReplaceAll(tagged, "%s", "apps"),
+		"apps/ctest_pod_test.go":  "// ctest rewrite: prompt v1 (sha256:abc), model m1\n\npackage apps // edited\n",
+		"node/node_test.go":       strings.ReplaceAll(tagged, "%s", "node"),
+		"node/ctest_node_test.go": "// ctest rewrite: prompt v1 (sha256:abc), model m2\n\npackage node\n",
+	}
+	writeTree(t, target, files)
+
+	// The journal knows the generated content of the apps rewrites
+	journalPath := filepath.Join(t.TempDir(), "journal.json")
+	journal, err := testrewrite.OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"job", "pod"} {
+		generated := strings.Replace(rewrite, "%s", "m1", 1)
+		generated = strings.Replace(generated, "%s", "apps", 1)
+		entry := testrewrite.JournalEntry{
+			File:       filepath.Join(target, "apps", name+"_test.go"),
+			Status:     testrewrite.StatusRewritten,
+			Model:      "m1",
+			OutputHash: testrewrite.ContentHash([]byte(generated)),
+		}
+		if err := journal.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups := t.TempDir()
+	opts := Options{JournalPath: journalPath, BackupDir: backups, Model: "m1", Logf: t.Logf}
+	summary, err := Run(target, opts)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// The edited pod rewrite stays, and so does the tag of its original; node is
+	// another model
+	if summary.Deleted != 1 || summary.Cleaned != 1 || summary.Filtered != 1 || len(summary.Modified) != 1 || summary.Backup == "" {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	for rel, want := range map[string]bool{"apps/ctest_job_test.go": false, "apps/ctest_pod_test.go": true, "node/ctest_node_test.go": true} {
+		if _, err := os.Stat(filepath.Join(target, rel)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", rel, err == nil, want)
+		}
+	}
+	src, _ := os.ReadFile(filepath.Join(target, "apps/pod_test.go"))
+	if string(src) != files["apps/pod_test.go"] {
+		t.Errorf("original of the kept rewrite was changed:\n%s", src)
+	}
+	if journal, _ = testrewrite.OpenJournal(journalPath); journal != nil {
+		if _, ok := journal.Get(filepath.Join(target, "apps/job_test.go")); ok {
+			t.Errorf("journal entry of the deleted rewrite was kept")
+		}
+	}
+
+	latest, err := LatestBackup(backups)
+	if err != nil || latest != summary.Backup {
+		t.Fatalf("LatestBackup = %s, %v, want %s", latest, err, summary.Backup)
+	}
+	undo, err := Undo(latest, UndoOptions{JournalPath: journalPath, Logf: t.Logf})
+	if err != nil || undo.Restored != 2 {
+		t.Fatalf("Undo = %+v, %v", undo, err)
+	}
+	for rel, content := range files {
+		if got, _ := os.ReadFile(filepath.Join(target, rel)); string(got) != content {
+			t.Errorf("%s after undo:\n%s\nwant:\n%s", rel, got, content)
+		}
+	}
+	if journal, _ = testrewrite.OpenJournal(journalPath); journal != nil {
+		if _, ok := journal.Get(filepath.Join(target, "apps/job_test.go")); !ok {
+			t.Errorf("journal entry was not put back")
+		}
+	}
+
+	// Forcing also deletes the edited rewrite; undo then conflicts with a file
+	// written since
+	opts.Force = true
+	if summary, err = Run(target, opts); err != nil || summary.Deleted != 2 {
+		t.Fatalf("forced Run = %+v, %v", summary, err)
+	}
+	writeTree(t, target, map[string]string{"apps/ctest_job_test.go": "package apps // new\n"})
+	if undo, err = Undo(summary.Backup, UndoOptions{Logf: t.Logf}); err == nil || len(undo.Conflicts) != 1 {
+		t.Fatalf("expected a conflict, got %+v, %v", undo, err)
+	}
+}
+
+func TestRunKeepsUnverified(t *testing.T) {
+	target := t.TempDir()
+	generated := "// ctest rewrite: prompt v1 (sha256:abc), model m1\n\npackage apps\n"
+	writeTree(t, target, map[string]string{
+		"apps/job_test.go":       "//go:build original\n\npackage apps\n",
+		"apps/ctest_job_test.go": generated,
+		"apps/ctest_pod_test.go": generated,
+	})
+
+	// The last attempt for job failed and wrote nothing, its rewrite keeps the
+	// recorded hash; pod was rewritten before hashes were recorded
+	journalPath := filepath.Join(t.TempDir(), "journal.json")
+	journal, err := testrewrite.OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = journal.Record(testrewrite.JournalEntry{
+		File:       filepath.Join(target, "apps", "job_test.go"),
+		Status:     testrewrite.StatusFailed,
+		Model:      "m1",
+		OutputHash: testrewrite.ContentHash([]byte(generated)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{JournalPath: journalPath, Logf: t.Logf}
+	summary, err := Run(target, opts)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if summary.Deleted != 1 || len(summary.Unverified) != 1 || !strings.HasSuffix(summary.Unverified[0], "ctest_pod_test.go") {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	opts.Force = true
+	if summary, err = Run(target, opts); err != nil || summary.Deleted != 1 || len(summary.Unverified) != 0 {
+		t.Fatalf("forced Run = %+v, %v", summary, err)
+	}
+}
+
+func TestRunAfterDisabledRewrite(t *testing.T) {
+	target := t.TempDir()
+	writeTree(t, target, map[string]string{
+		"go.mod":           "module example.com/foo\n\ngo 1.21\n",
+		"foo/a_test.go":    "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+		"fake/default.txt": "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n",
+	})
+	file := filepath.Join(target, "foo", "a_test.go")
+	client := testrewrite.NewFakeClient(filepath.Join(target, "fake"), "m1")
+	journalPath := filepath.Join(t.TempDir(), "journal.json")
+	opts := testrewrite.RewriteOptions{JournalPath: journalPath, Activation: "disabled", Logf: t.Logf}
+	if _, err := testrewrite.RewriteFiles(context.Background(), client, []string{file}, opts); err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	src, _ := os.ReadFile(filepath.Join(target, "foo", "ctest_a_test.go"))
+	if !activation.HasTag(src, activation.CtestTag) {
+		t.Fatalf("rewrite not disabled:\n%s", src)
+	}
+
+	// The ctest tag added after the rewrite was recorded is not a hand edit
+	opts.Overwrite = true
+	summary, err := testrewrite.RewriteFiles(context.Background(), client, []string{file}, opts)
+	if err != nil || summary.AlreadyRewritten != 1 {
+		t.Fatalf("expected the journal to skip the rewrite, got %+v, %v", summary, err)
+	}
+	cleaned, err := Run(target, Options{JournalPath: journalPath, Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if cleaned.Deleted != 1 || len(cleaned.Modified) != 0 {
+		t.Fatalf("unexpected summary %+v", cleaned)
+	}
+}
+
+func TestSelects(t *testing.T) {
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	g := generation{model: "m1", date: day}
+	since, _ := ParseDate("2026-03-01")
+	until, _ := ParseDate("2026-03-02")
+	for _, tc := range []struct {
+		opts Options
+		rel  string
+		want bool
+	}{
+		{Options{}, "apps/ctest_a_test.go", true},
+		{Options{Packages: []string{"apps"}}, "apps/sub/ctest_a_test.go", true},
+		{Options{Packages: []string{"ap*"}}, "apps/ctest_a_test.go", true},
+		{Options{Packages: []string{"node"}}, "apps/ctest_a_test.go", false},
+		{Options{Model: "m2"}, "apps/ctest_a_test.go", false},
+		{Options{Since: since, Until: until}, "apps/ctest_a_test.go", true},
+		{Options{Until: since}, "apps/ctest_a_test.go", false},
+	} {
+		if got := tc.opts.selects(tc.rel, g); got != tc.want {
+			t.Errorf("selects(%+v, %s) = %v, want %v", tc.opts, tc.rel, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/clean"
+	"k8s.io/kubernetes/test/ctest/collection"
+)
+
+// cleanPaths returns the rewrite journal and the backup directory of clean and
+// undo under k8sRoot, unless given.
+func cleanPaths(k8sRoot, journal, backups string) (string, string) {
+	if journal == "" {
+		journal = envOr("REWRITE_JOURNAL", filepath.Join(logsDir(k8sRoot), "rewrite_journal.json"))
+	}
+	if backups == "" {
+		backups = envOr("CLEAN_BACKUP_DIR", filepath.Join(logsDir(k8sRoot), "clean_backups"))
+	}
+	return resolve(k8sRoot, journal), resolve(k8sRoot, backups)
+}
+
+func runClean(e *env, args []string) int {
+	fs, root := newFlagSet(e, "clean", "[flags]",
+		"Delete the ctest_ files under -target and remove the original build tag from the tests they replaced.\n"+
+			"Rewrites edited since they were generated, or without a generation hash in the journal, are kept\n"+
+			"unless -force is given. Every file is backed up first; 'ctest undo' puts them back.")
+	var (
+		target   = fs.String("target", envOr("CLEAN_TARGET", "test/e2e"), "directory to clean")
+		dryRun   = fs.Bool("dry-run", false, "only list the files that would be deleted and the build tag changes")
+		force    = fs.Bool("force", false, "also delete rewrites edited by hand or without a recorded generation hash")
+		packages = fs.String("packages", envOr("CLEAN_PACKAGES", ""), "comma-separated directories relative to -target to clean, globs allowed")
+		model    = fs.String("model", envOr("CLEAN_MODEL", ""), "only clean rewrites generated by this model ('deterministic' for rewrites without a model)")
+		since    = fs.String("since", envOr("CLEAN_SINCE", ""), "only clean rewrites generated on or after this date (2006-01-02 or RFC 3339)")
+		until    = fs.String("until", envOr("CLEAN_UNTIL", ""), "only clean rewrites generated before this date")
+		journal  = fs.String("journal", "", "rewrite journal with the generation hashes (default: test/ctest/logs/rewrite_journal.json)")
+		backups  = fs.String("backup-dir", "", "backup directory (default: test/ctest/logs/clean_backups)")
+		noBackup = fs.Bool("no-backup", false, "do not back up files before deleting or untagging them")
+	)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+
+	opts := clean.Options{DryRun: *dryRun, Force: *force, Model: *model, Packages: collection.SplitGlobs(*packages), Logf: e.logf}
+	opts.JournalPath, opts.BackupDir = cleanPaths(k8sRoot, *journal, *backups)
+	if *noBackup {
+		opts.BackupDir = ""
+	}
+	for _, d := range []struct {
+		value string
+		dst   *time.Time
+	}{{*since, &opts.Since}, {*until, &opts.Until}} {
+		if d.value == "" {
+			continue
+		}
+		if *d.dst, err = clean.ParseDate(d.value); err != nil {
+			e.errorf("clean: %v", err)
+			return exitUsage
+		}
+	}
+	if *dryRun {
+		e.logf("Dry run: nothing is deleted or changed")
+	}
+
+	summary, err := clean.Run(resolve(k8sRoot, *target), opts)
+	if err != nil {
+		e.errorf("clean: %v", err)
+		return exitFailed
+	}
+	for _, err := range summary.Errors {
+		e.errorf("%v", err)
+	}
+
+	e.logf("===================================")
+	e.logf("Clean Summary")
+	e.logf("Deleted rewritten files : %d", summary.Deleted)
+	e.logf("Kept, edited by hand    : %d", len(summary.Modified))
+	e.logf("Kept, no recorded hash  : %d", len(summary.Unverified))
+	e.logf("Not selected            : %d", summary.Filtered)
+	e.logf("Cleaned build tags      : %d", summary.Cleaned)
+	e.logf("Skipped files           : %d", summary.Skipped)
+	if summary.Backup != "" {
+		e.logf("Backup                  : %s", summary.Backup)
+	}
+	e.logf("===================================")
+	if len(summary.Errors) > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func runUndo(e *env, args []string) int {
+	fs, root := newFlagSet(e, "undo", "[flags]",
+		"Put back the files a clean deleted or untagged, from its backup. Files changed since the clean are\n"+
+			"conflicts; nothing is restored while there are conflicts unless -force is given.")
+	var (
+		backup  = fs.String("backup", "", "backup to restore (default: the newest in -backup-dir)")
+		backups = fs.String("backup-dir", "", "backup directory (default: test/ctest/logs/clean_backups)")
+		journal = fs.String("journal", "", "rewrite journal to put the entries of restored rewrites back into")
+		force   = fs.Bool("force", false, "overwrite files changed since the clean")
+		dryRun  = fs.Bool("dry-run", false, "only list the files that would be restored")
+	)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+	journalPath, backupDir := cleanPaths(k8sRoot, *journal, *backups)
+	dir := *backup
+	if dir == "" {
+		if dir, err = clean.LatestBackup(backupDir); err != nil {
+			e.errorf("undo: %v", err)
+			return exitFailed
+		}
+	}
+	dir = resolve(k8sRoot, dir)
+
+	summary, err := clean.Undo(dir, clean.UndoOptions{Force: *force, DryRun: *dryRun, JournalPath: journalPath, Logf: e.logf})
+	if summary != nil {
+		for _, c := range summary.Conflicts {
+			e.logf("⚠️  %s", c)
+		}
+	}
+	if err != nil {
+		e.errorf("undo: %v", err)
+		return exitFailed
+	}
+
+	e.logf("===================================")
+	e.logf("Undo Summary (%s)", dir)
+	e.logf("Restored files : %d", summary.Restored)
+	e.logf("Unchanged      : %d", summary.Unchanged)
+	e.logf("Conflicts      : %d", len(summary.Conflicts))
+	e.logf("===================================")
+	return exitOK
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"path/filepath"
+
+	"k8s.io/kubernetes/test/ctest/collection"
+)
+
+func runCollect(e *env, args []string) int {
+	fs, root := newFlagSet(e, "collect", "[flags]",
+		"Copy the ctest_ files under -target to -dest, keeping their path relative to the Kubernetes root,\n"+
+			"with a manifest of their paths and hashes. A -dest ending in .tar.gz, .tgz or .zip is written as an archive.")
+	var (
+		target  = fs.String("target", envOr("COLLECT_TARGET", "staging"), "directory to collect from")
+		dest    = fs.String("dest", envOr("COLLECT_DEST", filepath.Join("test", "ctest", "logs", "ctest_collection")), "destination directory or archive")
+		include = fs.String("include", envOr("COLLECT_INCLUDE", ""), "comma-separated globs a file has to match, e.g. test/e2e/**")
+		exclude = fs.String("exclude", envOr("COLLECT_EXCLUDE", ""), "comma-separated globs of files to leave out")
+	)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+
+	destPath := resolve(k8sRoot, *dest)
+	summary, err := collection.Collect(k8sRoot, resolve(k8sRoot, *target), collection.Options{
+		Dest:    destPath,
+		Include: collection.SplitGlobs(*include),
+		Exclude: collection.SplitGlobs(*exclude),
+		Logf:    e.logf,
+	})
+	if err != nil {
+		e.errorf("collect: %v", err)
+		return exitFailed
+	}
+	for _, err := range summary.Errors {
+		e.errorf("%v", err)
+	}
+
+	e.logf("===================================")
+	e.logf("Collection Summary")
+	e.logf("Copied ctest_ files : %d", summary.Copied)
+	e.logf("Filtered out        : %d", summary.Skipped)
+	e.logf("Destination         : %s", destPath)
+	e.logf("===================================")
+	if len(summary.Errors) > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func runRestore(e *env, args []string) int {
+	fs, root := newFlagSet(e, "restore", "-from <collection> [flags]",
+		"Write the files of a collection (directory or archive made by 'ctest collect') back into the tree.\n"+
+			"Files that differ from the tree or whose original test is missing are conflicts; nothing is\n"+
+			"written while there are conflicts unless -force is given.")
+	var (
+		from   = fs.String("from", envOr("RESTORE_SOURCE", ""), "collection directory or archive (required)")
+		force  = fs.Bool("force", false, "overwrite conflicting files")
+		dryRun = fs.Bool("dry-run", false, "only list the files that would be restored")
+	)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if *from == "" {
+		e.errorf("restore: missing -from")
+		fs.Usage()
+		return exitUsage
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+
+	summary, err := collection.Restore(resolve(k8sRoot, *from), k8sRoot, collection.RestoreOptions{Force: *force, DryRun: *dryRun, Logf: e.logf})
+	if summary != nil {
+		for _, c := range summary.Conflicts {
+			e.logf("⚠️  %s", c)
+		}
+	}
+	if err != nil {
+		e.errorf("restore: %v", err)
+		return exitFailed
+	}
+
+	e.logf("===================================")
+	e.logf("Restore Summary")
+	e.logf("Restored ctest_ files : %d", summary.Restored)
+	e.logf("Unchanged             : %d", summary.Unchanged)
+	e.logf("Conflicts             : %d", len(summary.Conflicts))
+	e.logf("===================================")
+	return exitOK
+}
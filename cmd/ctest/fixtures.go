@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	ctest "k8s.io/kubernetes/test/ctest"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+	"k8s.io/kubernetes/test/ctest/fixtures"
+	"k8s.io/kubernetes/test/ctest/gitscan"
+)
+
+func runFixtures(e *env, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "gen":
+			return runFixturesGen(e, args[1:])
+		case "inspect":
+			return runFixturesInspect(e, args[1:])
+		case "help", "-h", "-help", "--help":
+			fmt.Fprintf(e.stdout, "Usage: ctest fixtures gen|inspect [flags]\n")
+			return exitOK
+		}
+	}
+	e.errorf("usage: ctest fixtures gen|inspect [flags]")
+	return exitUsage
+}
+
+func runFixturesGen(e *env, args []string) int {
+	fs, root := newFlagSet(e, "fixtures gen", "-repo <dir> | -corpus <file> [flags]",
+		"Replace test/ctest/fixtures/"+ctestglobals.TestExternalFixtureFile+" with the Kubernetes objects of the YAML files under -repo.\n"+
+			"With -rev or -incremental the committed files of the git repository -repo are scanned instead of its working tree.\n"+
+			"With -corpus all repositories of a corpus manifest are scanned, and -only regenerates some of them.")
+	repo := fs.String("repo", "", "repository to scan for YAML manifests")
+	rev := fs.String("rev", envOr("REPO_REV", ""), "git commit, tag or branch to scan")
+	incremental := fs.Bool("incremental", false, "only parse the files changed since the commit of the last scan")
+	corpusFile := fs.String("corpus", envOr("CORPUS", ""), "corpus manifest listing the repositories to scan")
+	only := fs.String("only", "", "comma-separated labels of the corpus sources to regenerate, keeping the others")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if (*repo == "") == (*corpusFile == "") {
+		e.errorf("fixtures gen: need one of -repo or -corpus")
+		fs.Usage()
+		return exitUsage
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+	var corpus *gitscan.Corpus
+	if *corpusFile != "" {
+		// Load before changing directory, the paths of the sources are relative
+		// to the manifest
+		if corpus, err = gitscan.LoadCorpus(*corpusFile); err != nil {
+			e.errorf("fixtures gen: %v", err)
+			return exitFailed
+		}
+	}
+	repoDir, err := filepath.Abs(*repo)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+
+	// The fixture store writes ./fixtures/<file>, relative to test/ctest like the
+	// go test driver
+	if err := os.Chdir(filepath.Join(k8sRoot, "test", "ctest")); err != nil {
+		e.errorf("fixtures gen: %v", err)
+		return exitFailed
+	}
+	if corpus != nil {
+		summaries, err := gitscan.GenerateCorpusFixtures(corpus, gitscan.CorpusOptions{Only: gitscan.SplitLabels(*only), Incremental: *incremental})
+		if err != nil {
+			e.errorf("fixtures gen: %v", err)
+			return exitFailed
+		}
+		total := 0
+		for _, s := range summaries {
+			state := "scanned"
+			if s.Kept {
+				state = "kept"
+			}
+			e.logf("%-24s %-8s %d objects from %d files", s.Label, state, s.Objects, s.Files)
+			total += s.Objects
+		}
+		e.logf("Generated fixtures from %d objects of %d sources", total, len(summaries))
+		return exitOK
+	}
+	if *rev == "" && !*incremental {
+		n, err := ctest.GenerateFixtures(repoDir)
+		if err != nil {
+			e.errorf("fixtures gen: %v", err)
+			return exitFailed
+		}
+		e.logf("Generated fixtures from %d objects under %s", n, repoDir)
+		return exitOK
+	}
+	summary, err := gitscan.GenerateFixturesFromGit(repoDir, gitscan.Options{Rev: *rev, Incremental: *incremental})
+	if err != nil {
+		e.errorf("fixtures gen: %v", err)
+		return exitFailed
+	}
+	e.logf("Generated fixtures from %d objects of %s at %s", summary.Objects, repoDir, summary.Commit)
+	if summary.Since != "" {
+		e.logf("Parsed %d of %d files changed since %s", summary.Parsed, summary.Files, summary.Since)
+	}
+	return exitOK
+}
+
+// fixtureObject is the part of a fixture object inspect lists.
+type fixtureObject struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+func runFixturesInspect(e *env, args []string) int {
+	fs, _ := newFlagSet(e, "fixtures inspect", "[-file <name>] [-kind <kind>]",
+		"Count the objects of each kind in an embedded fixture file, or list the objects of one kind.")
+	file := fs.String("file", ctestglobals.TestExternalFixtureFile, "embedded fixture file")
+	kind := fs.String("kind", "", "list the objects of this kind, e.g. Deployment or configMaps")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	var kinds []string
+	if *kind != "" {
+		kinds = []string{*kind}
+	}
+	store, err := fixtures.LoadFixturesAsJSON(*file, kinds...)
+	if err != nil {
+		e.errorf("fixtures inspect: %v", err)
+		return exitFailed
+	}
+	keys := make([]string, 0, len(store))
+	for key := range store {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	total := 0
+	for _, key := range keys {
+		var objs []fixtureObject
+		if err := json.Unmarshal(store[key], &objs); err != nil {
+			e.errorf("fixtures inspect: %s is not a list of objects: %v", key, err)
+			return exitFailed
+		}
+		total += len(objs)
+		e.logf("%-26s %d", key, len(objs))
+		if *kind == "" {
+			continue
+		}
+		for _, obj := range objs {
+			if obj.Metadata.Namespace != "" {
+				e.logf("  %s/%s", obj.Metadata.Namespace, obj.Metadata.Name)
+			} else {
+				e.logf("  %s", obj.Metadata.Name)
+			}
+		}
+	}
+	e.logf("%-26s %d", "Total", total)
+	return exitOK
+}
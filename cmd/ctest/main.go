@@ -0,0 +1,150 @@
+// Command ctest drives the ctest tooling: it generates and inspects fixtures,
+// rewrites tests with an LLM, reviews, cleans up and collects the rewrites.
+//
+// Run it from the Kubernetes root, or pass -root:
+//
+//	go run ./test/ctest/cmd/ctest rewrite -target test/e2e/apps -workers 4
+//
+// Building it needs github.com/go-git/go-git/v5 in the Kubernetes go.mod, see
+// package gitscan.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes: exitFailed when the command ran but some files failed,
+// exitUsage for bad arguments.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// command is one subcommand. run gets the arguments after the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(env *env, args []string) int
+}
+
+var commands = []command{
+	{"fixtures", "generate or inspect the fixture file (fixtures gen, fixtures inspect)", runFixtures},
+	{"rewrite", "rewrite Go tests into ctest_ files with an LLM", runRewrite},
+	{"report", "write the review report of rewritten files", runReport},
+	{"clean", "delete ctest_ files and untag the originals, with a backup", runClean},
+	{"undo", "restore the files of a clean from its backup", runUndo},
+	{"collect", "copy ctest_ files out of the tree, to a directory or an archive", runCollect},
+	{"restore", "write a collection back into the tree", runRestore},
+}
+
+// env is where a command writes its output.
+type env struct {
+	stdout, stderr io.Writer
+}
+
+func (e *env) logf(format string, args ...interface{}) {
+	fmt.Fprintf(e.stdout, format+"\n", args...)
+}
+
+func (e *env) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(e.stderr, "ctest: "+format+"\n", args...)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	e := &env{stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(e, args[1:])
+		}
+	}
+	e.errorf("unknown command %q", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: ctest <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'ctest <command> -h' for the flags of a command.\n")
+}
+
+// newFlagSet returns the flag set of a command, with -root. Errors are reported
+// to e.stderr and returned by Parse, help requests as flag.ErrHelp.
+func newFlagSet(e *env, name, args, summary string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ctest %s %s\n\n%s\n\nFlags:\n", name, args, summary)
+		fs.PrintDefaults()
+	}
+	root := fs.String("root", os.Getenv("K8S_ROOT"), "Kubernetes root; relative paths are resolved against it (default: $K8S_ROOT or the current directory)")
+	return fs, root
+}
+
+// parse parses args and returns the exit code to use when the command should not
+// run: exitOK for -h, exitUsage for bad flags or positional arguments.
+func parse(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// resolveRoot returns the absolute Kubernetes root, the current directory when
+// root is empty.
+func resolveRoot(root string) (string, error) {
+	if root == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(root)
+}
+
+// resolve returns path, or path under root when it is relative.
+func resolve(root, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// envOr returns the environment variable key, def when it is not set.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// logsDir is test/ctest/logs under root, where reports and journals go.
+func logsDir(root string) string {
+	return filepath.Join(root, "test", "ctest", "logs")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCLI(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var out bytes.Buffer
+	code := run(args, &out, &out)
+	return code, out.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"bogus"}, exitUsage},
+		{[]string{"fixtures"}, exitUsage},
+		{[]string{"fixtures", "gen"}, exitUsage},
+		{[]string{"clean", "-h"}, exitOK},
+		{[]string{"clean", "-no-such-flag"}, exitUsage},
+		{[]string{"collect", "extra"}, exitUsage},
+	} {
+		if code, out := runCLI(t, tc.args...); code != tc.code {
+			t.Errorf("ctest %s = %d, want %d\n%s", strings.Join(tc.args, " "), code, tc.code, out)
+		}
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "test", "e2e", "foo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":          "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go":       "//go:build original\n\npackage foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+		"ctest_a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := filepath.Join(t.TempDir(), "report")
+	if code, out := runCLI(t, "report", "-root", root, "-out", report); code != exitOK || !strings.Contains(out, "(1 files)") {
+		t.Fatalf("report = %d\n%s", code, out)
+	}
+	if _, err := os.Stat(report + ".md"); err != nil {
+		t.Errorf("report not written: %v", err)
+	}
+
+	dest := t.TempDir()
+	if code, out := runCLI(t, "collect", "-root", root, "-target", "test", "-dest", dest); code != exitOK {
+		t.Fatalf("collect = %d\n%s", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "test", "e2e", "foo", "ctest_a_test.go")); err != nil {
+		t.Errorf("ctest_ file not collected: %v", err)
+	}
+
+	// The rewrite is not in the journal, so it cannot be checked for hand edits
+	if code, out := runCLI(t, "clean", "-root", root, "-dry-run"); code != exitOK || !strings.Contains(out, "Kept, no recorded hash  : 1") {
+		t.Fatalf("clean -dry-run = %d\n%s", code, out)
+	}
+	if code, out := runCLI(t, "clean", "-root", root, "-dry-run", "-force"); code != exitOK || !strings.Contains(out, "-//go:build original") {
+		t.Fatalf("clean -dry-run -force = %d\n%s", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ctest_a_test.go")); err != nil {
+		t.Fatalf("dry run deleted the rewrite: %v", err)
+	}
+	if code, out := runCLI(t, "clean", "-root", root, "-force"); code != exitOK {
+		t.Fatalf("clean = %d\n%s", code, out)
+	}
+	src, _ := os.ReadFile(filepath.Join(dir, "a_test.go"))
+	if _, err := os.Stat(filepath.Join(dir, "ctest_a_test.go")); err == nil || strings.Contains(string(src), "go:build") {
+		t.Errorf("clean left the rewrite or the tag:\n%s", src)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+
+	testrewrite "k8s.io/kubernetes/test/ctest/test_rewrite"
+)
+
+func runReport(e *env, args []string) int {
+	fs, root := newFlagSet(e, "report", "[flags]",
+		"Review the ctest_ files under -target, or the rewrites of an -overlay directory, and write\n"+
+			"the report as <out>.md and <out>.json, lowest score first.")
+	var (
+		target  = fs.String("target", envOr("REWRITE_TARGET", "test/e2e"), "directory with rewritten ctest_ files")
+		overlay = fs.String("overlay", "", "review the rewrites recorded in this overlay directory instead")
+		out     = fs.String("out", "", "report path without extension (default: $REWRITE_REPORT or test/ctest/logs/rewrite_report)")
+		vet     = fs.Bool("vet", false, "also run go vet on rewrites that compile")
+	)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+
+	var results []testrewrite.FileResult
+	if *overlay != "" {
+		results, err = testrewrite.OverlayResults(resolve(k8sRoot, *overlay))
+	} else {
+		results, err = testrewrite.RewrittenResults(resolve(k8sRoot, *target))
+	}
+	if err != nil {
+		e.errorf("report: %v", err)
+		return exitFailed
+	}
+	reportBase := *out
+	if reportBase == "" {
+		reportBase = envOr("REWRITE_REPORT", filepath.Join(logsDir(k8sRoot), "rewrite_report"))
+	}
+	if !writeReport(e, testrewrite.BuildReviewReport(results, *vet), resolve(k8sRoot, reportBase)) {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func writeReport(e *env, report *testrewrite.ReviewReport, base string) bool {
+	if err := report.WriteFiles(base); err != nil {
+		e.errorf("failed to write review report: %v", err)
+		return false
+	}
+	e.logf("Review report     : %s.{md,json} (%d files)", base, len(report.Files))
+	return true
+}
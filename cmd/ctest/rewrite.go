@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/activation"
+	testrewrite "k8s.io/kubernetes/test/ctest/test_rewrite"
+)
+
+func runRewrite(e *env, args []string) int {
+	fs, root := newFlagSet(e, "rewrite", "[flags]",
+		"Rewrite the Go tests under -target into ctest_ files with an LLM. Flags default to the\n"+
+			"environment variables of 'make testrewrite'; the other REWRITE_* variables are read as well.")
+	llmConfig := testrewrite.LLMConfigFromEnv()
+	llmConfig.AddFlags(fs)
+	var (
+		target     = fs.String("target", envOr("REWRITE_TARGET", "test/e2e"), "directory or file to rewrite")
+		overwrite  = fs.Bool("overwrite", false, "rewrite files whose ctest_ file is not up to date in the journal ($OVERWRITE_REWRITTEN)")
+		workers    = fs.Int("workers", 1, "files rewritten concurrently ($REWRITE_WORKERS)")
+		dryRun     = fs.Bool("dry-run", false, "only log what would be written, with diffs ($REWRITE_DRY_RUN)")
+		overlay    = fs.String("overlay", "", "write rewrites to this directory with an overlay.json ($REWRITE_OVERLAY_DIR)")
+		activate   = fs.String("activation", "", "put the package of every rewrite into this mode: replace, side-by-side or disabled ($REWRITE_ACTIVATION)")
+		journal    = fs.String("journal", "", "progress journal (default: test/ctest/logs/rewrite_journal.json)")
+		cacheMode  = fs.String("cache", "", "response cache: on, off or refresh ($REWRITE_CACHE)")
+		cacheDir   = fs.String("cache-dir", "", "response cache directory (default: test/ctest/logs/rewrite_cache)")
+		cachePrune = fs.Duration("cache-prune", 0, "first drop cached replies unused for this long; 0s clears the cache ($REWRITE_CACHE_PRUNE)")
+		deadline   = fs.Duration("deadline", 0, "stop the run after this long ($REWRITE_DEADLINE)")
+		report     = fs.String("report", "", "review report written as <path>.md and <path>.json (default: test/ctest/logs/rewrite_report)")
+	)
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	k8sRoot, err := resolveRoot(*root)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitFailed
+	}
+
+	// Flags given on the command line win over the environment
+	opts := testrewrite.RewriteOptionsFromEnv(k8sRoot)
+	opts.Logf = e.logf
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "overwrite":
+			opts.Overwrite = *overwrite
+		case "workers":
+			opts.Workers = *workers
+		case "dry-run":
+			opts.DryRun = *dryRun
+		case "overlay":
+			opts.OverlayDir = *overlay
+		case "activation":
+			opts.Activation = activation.Mode(*activate)
+		case "journal":
+			opts.JournalPath = *journal
+		case "cache":
+			opts.CacheMode = *cacheMode
+		case "cache-dir":
+			opts.CacheDir = *cacheDir
+		}
+	})
+	if opts.Prompts, err = testrewrite.PromptSetFromEnv(); err != nil {
+		e.errorf("failed to load prompt: %v", err)
+		return exitFailed
+	}
+	client, err := testrewrite.NewLLMClient(llmConfig)
+	if err != nil {
+		e.errorf("failed to create LLM client: %v", err)
+		return exitFailed
+	}
+	if *deadline == 0 {
+		if v := os.Getenv("REWRITE_DEADLINE"); v != "" {
+			if *deadline, err = time.ParseDuration(v); err != nil {
+				e.errorf("invalid REWRITE_DEADLINE %q: %v", v, err)
+				return exitUsage
+			}
+		}
+	}
+
+	absTarget := resolve(k8sRoot, *target)
+	e.logf("Rewrite target: %s", absTarget)
+	e.logf("Using LLM provider: %s, model: %s", llmConfig.Provider, client.Model())
+	e.logf("Workers: %d, request interval: %s, journal: %s", opts.Workers, opts.Interval, opts.JournalPath)
+	e.logf("Prompt: %s (sha256:%s)", opts.Prompts.Version, opts.Prompts.Hash)
+	e.logf("Response cache: %s (%s)", opts.CacheDir, opts.CacheMode)
+	if opts.DryRun {
+		e.logf("Dry run: nothing is written")
+	}
+
+	prune := isSet(fs, "cache-prune")
+	if v := os.Getenv("REWRITE_CACHE_PRUNE"); v != "" && !prune {
+		if *cachePrune, err = time.ParseDuration(v); err != nil {
+			e.errorf("invalid REWRITE_CACHE_PRUNE %q: %v", v, err)
+			return exitUsage
+		}
+		prune = true
+	}
+	if prune && opts.CacheDir != "" {
+		cache, err := testrewrite.OpenResponseCache(opts.CacheDir)
+		if err != nil {
+			e.errorf("failed to open response cache: %v", err)
+			return exitFailed
+		}
+		removed, err := cache.Prune(*cachePrune)
+		if err != nil {
+			e.errorf("%v", err)
+			return exitFailed
+		}
+		e.logf("Pruned %d cached responses unused for %s", removed, *cachePrune)
+	}
+
+	files, err := testrewrite.CollectGoFilesFromRepo(k8sRoot, absTarget)
+	if err != nil {
+		e.errorf("failed to collect files: %v", err)
+		return exitFailed
+	}
+	if len(files) == 0 {
+		e.logf("No Go files to rewrite")
+		return exitOK
+	}
+
+	// Ctrl-C or -deadline stop the run; finished files stay in the journal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
+
+	summary, err := testrewrite.RewriteFiles(ctx, client, files, opts)
+	if err != nil {
+		e.errorf("rewrite failed: %v", err)
+		return exitFailed
+	}
+	code := exitOK
+	for _, res := range summary.Results {
+		if res.Err != nil {
+			e.errorf("%v", res.Err)
+			code = exitFailed
+		}
+		for _, action := range res.Planned {
+			e.logf("📝 would %s", action)
+		}
+		if res.Diff != "" {
+			e.logf("%s", res.Diff)
+		}
+	}
+
+	e.logf("===================================")
+	e.logf("Rewrite Summary")
+	summary.Log(e.logf)
+	e.logf("Elapsed time      : %s", summary.Elapsed.Round(time.Second))
+	e.logf("===================================")
+
+	if opts.DryRun {
+		return code
+	}
+	if opts.OverlayDir != "" {
+		e.logf("Test the rewrites with: go test -overlay=%s ...", filepath.Join(opts.OverlayDir, testrewrite.OverlayFile))
+	}
+	reportBase := *report
+	if reportBase == "" {
+		reportBase = envOr("REWRITE_REPORT", filepath.Join(logsDir(k8sRoot), "rewrite_report"))
+	}
+	if !writeReport(e, testrewrite.BuildReviewReport(summary.Results, opts.Validation.Vet), reportBase) {
+		return exitFailed
+	}
+	return code
+}
+
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
@@ -0,0 +1,265 @@
+package collection
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ManifestFile is the name of the manifest at the top of a collection.
+const ManifestFile = "manifest.json"
+
+// Manifest lists the files of a collection.
+type Manifest struct {
+	CreatedAt time.Time `json:"createdAt"`
+	// Root is the Kubernetes root the files were collected from.
+	Root  string          `json:"root"`
+	Files []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is one collected file. Path is slash-separated and relative to
+// the Kubernetes root.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+func (m *Manifest) marshal() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// archiveKind returns "tar.gz" or "zip" for an archive name, "" for a directory.
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// collectionWriter adds files to a collection; rel is slash-separated.
+type collectionWriter interface {
+	Add(rel string, data []byte) error
+	Close() error
+}
+
+func newCollectionWriter(dest string) (collectionWriter, error) {
+	kind := archiveKind(dest)
+	if kind == "" {
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return nil, err
+		}
+		return dirWriter(dest), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return nil, err
+	}
+	if kind == "zip" {
+		return &zipWriter{f: f, w: zip.NewWriter(f)}, nil
+	}
+	gz := gzip.NewWriter(f)
+	return &tarWriter{f: f, gz: gz, w: tar.NewWriter(gz)}, nil
+}
+
+type dirWriter string
+
+func (d dirWriter) Add(rel string, data []byte) error {
+	dest := filepath.Join(string(d), filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+func (d dirWriter) Close() error { return nil }
+
+type tarWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+	w  *tar.Writer
+}
+
+func (t *tarWriter) Add(rel string, data []byte) error {
+	hdr := &tar.Header{Name: rel, Mode: 0644, Size: int64(len(data)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := t.w.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := t.w.Write(data)
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	return errors.Join(t.w.Close(), t.gz.Close(), t.f.Close())
+}
+
+type zipWriter struct {
+	f *os.File
+	w *zip.Writer
+}
+
+func (z *zipWriter) Add(rel string, data []byte) error {
+	w, err := z.w.CreateHeader(&zip.FileHeader{Name: rel, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return errors.Join(z.w.Close(), z.f.Close())
+}
+
+// readCollection reads the manifest and the files of the collection at src, a
+// directory or an archive, and checks the files against the manifest.
+func readCollection(src string) (*Manifest, map[string][]byte, error) {
+	files := make(map[string][]byte)
+	var err error
+	switch archiveKind(src) {
+	case "tar.gz":
+		err = readTarGz(src, files)
+	case "zip":
+		err = readZip(src, files)
+	default:
+		err = readDir(src, files)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	data, ok := files[ManifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no %s", src, ManifestFile)
+	}
+	delete(files, ManifestFile)
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the manifest of %s: %w", src, err)
+	}
+
+	var problems []string
+	listed := make(map[string]bool)
+	for _, entry := range m.Files {
+		listed[entry.Path] = true
+		if err := checkPath(entry.Path); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		data, ok := files[entry.Path]
+		switch {
+		case !ok:
+			problems = append(problems, entry.Path+" is missing")
+		case hashOf(data) != entry.SHA256:
+			problems = append(problems, entry.Path+" does not match its hash")
+		}
+	}
+	// A directory may hold files of earlier collections, an archive may not
+	for _, p := range sortedPaths(files) {
+		if !listed[p] && archiveKind(src) != "" {
+			problems = append(problems, p+" is not in the manifest")
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%s does not match its manifest: %s", src, strings.Join(problems, "; "))
+	}
+	return m, files, nil
+}
+
+// checkPath rejects manifest paths that would be written outside the tree.
+func checkPath(rel string) error {
+	if rel == "" || path.IsAbs(rel) || strings.Contains(rel, `\`) || path.Clean(rel) != rel || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("invalid path %q", rel)
+	}
+	return nil
+}
+
+func readDir(src string, files map[string][]byte) error {
+	return filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+}
+
+func readTarGz(src string, files map[string][]byte) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	r := tar.NewReader(gz)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			return err
+		}
+		files[hdr.Name] = buf.Bytes()
+	}
+}
+
+func readZip(src string, files map[string][]byte) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		files[f.Name] = data
+	}
+	return nil
+}
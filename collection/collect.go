@@ -0,0 +1,161 @@
+// Package collection copies rewritten ctest_ files out of the tree, keeping
+// their path relative to the Kubernetes root, and restores them into a tree.
+//
+// A collection is a directory, a .tar.gz (.tgz) or a .zip archive holding the
+// files and a manifest of their paths and hashes, see Manifest.
+package collection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// Options configures a collection run.
+type Options struct {
+	// Dest is the destination directory, or an archive when it ends in .tar.gz,
+	// .tgz or .zip.
+	Dest string
+	// Include and Exclude are globs matched against the slash-separated path of a
+	// ctest_ file relative to the Kubernetes root, see walker.MatchGlob. With Include
+	// set a file has to match one of them; a file matching Exclude is left out.
+	Include []string
+	Exclude []string
+	// Logf receives one message per collected file, fmt.Printf style.
+	Logf func(format string, args ...interface{})
+}
+
+// Summary counts the files of a collection run. Skipped are the ctest_ files
+// left out by the filters. Errors holds the ctest_ files that could not be read.
+type Summary struct {
+	Copied   int
+	Skipped  int
+	Errors   []error
+	Manifest *Manifest
+}
+
+// Collect copies the ctest_ Go files under target to opts.Dest, at their path
+// relative to k8sRoot, with a manifest.
+func Collect(k8sRoot, target string, opts Options) (*Summary, error) {
+	if opts.Logf == nil {
+		opts.Logf = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	}
+	if opts.Dest == "" {
+		return nil, fmt.Errorf("no destination given")
+	}
+	if err := walker.CheckGlobs(append(append([]string{}, opts.Include...), opts.Exclude...)); err != nil {
+		return nil, err
+	}
+
+	// Earlier collections into the tree are not collected again
+	rules := walker.Rewrites
+	if rel, err := filepath.Rel(k8sRoot, opts.Dest); err == nil && !strings.HasPrefix(rel, "..") {
+		rules = rules.Append(walker.MustParseRules("/" + filepath.ToSlash(rel) + "/"))
+	}
+	walk := &walker.Walker{Rules: rules, Base: k8sRoot}
+
+	summary := &Summary{Manifest: &Manifest{CreatedAt: time.Now(), Root: k8sRoot}}
+	var files []collectedFile
+	err := walk.Walk(target, func(p string, d fs.DirEntry) error {
+		if !walker.IsRewrite(p) || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(k8sRoot, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			summary.Errors = append(summary.Errors, fmt.Errorf("%s is not under %s", p, k8sRoot))
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !walker.Select(rel, opts.Include, opts.Exclude) {
+			summary.Skipped++
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			summary.Errors = append(summary.Errors, fmt.Errorf("failed to read file %s: %w", p, err))
+			return nil
+		}
+		files = append(files, collectedFile{path: rel, data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect Go files: %w", err)
+	}
+
+	for _, f := range files {
+		summary.Manifest.Files = append(summary.Manifest.Files, ManifestEntry{Path: f.path, SHA256: hashOf(f.data), Size: int64(len(f.data))})
+	}
+	w, err := newCollectionWriter(opts.Dest)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCollection(w, summary.Manifest, files); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", opts.Dest, err)
+	}
+	for _, f := range files {
+		opts.Logf("📄 Collected: %s", f.path)
+	}
+	summary.Copied = len(files)
+	return summary, nil
+}
+
+type collectedFile struct {
+	path string // slash-separated, relative to the Kubernetes root
+	data []byte
+}
+
+func writeCollection(w collectionWriter, m *Manifest, files []collectedFile) (err error) {
+	defer func() {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	data, err := m.marshal()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(ManifestFile, data); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := w.Add(f.path, f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitGlobs splits a comma-separated list of globs, as read from COLLECT_INCLUDE
+// and COLLECT_EXCLUDE.
+func SplitGlobs(s string) []string {
+	var globs []string
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			globs = append(globs, g)
+		}
+	}
+	return globs
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedPaths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -1,15 +1,13 @@
 package collection
 
 import (
-	ctestutils "k8s.io/kubernetes/test/ctest/utils"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
-// TestCollectCtestFiles collects all ctest_ Go files and copies them
-// to a test folder while preserving the directory structure
-func TestCollectCtestFiles(t *testing.T) {
+func k8sRootFromEnv(t *testing.T) string {
 	k8sRoot := os.Getenv("K8S_ROOT")
 	t.Log("K8S_ROOT:", k8sRoot)
 	if k8sRoot == "" {
@@ -19,6 +17,15 @@ func TestCollectCtestFiles(t *testing.T) {
 		}
 		k8sRoot = filepath.Clean(filepath.Join(cwd, "../../.."))
 	}
+	return k8sRoot
+}
+
+// TestCollectCtestFiles collects all ctest_ Go files and copies them
+// to a test folder while preserving the directory structure. COLLECT_DEST
+// may name a .tar.gz or .zip archive instead, COLLECT_INCLUDE and
+// COLLECT_EXCLUDE are comma-separated globs.
+func TestCollectCtestFiles(t *testing.T) {
+	k8sRoot := k8sRootFromEnv(t)
 
 	target := os.Getenv("COLLECT_TARGET")
 	if target == "" {
@@ -32,64 +39,61 @@ func TestCollectCtestFiles(t *testing.T) {
 		absTarget = filepath.Join(k8sRoot, target)
 	}
 
-	// Destination folder for test collection
-	destRoot := `D:\k8s test rewrite collection`
+	// Destination folder or archive for test collection
+	destRoot := os.Getenv("COLLECT_DEST")
+	if destRoot == "" {
+		destRoot = filepath.Join(k8sRoot, "test", "ctest", "logs", "ctest_collection")
+	}
 
-	files, err := ctestutils.CollectAllGoFiles(absTarget)
+	summary, err := Collect(k8sRoot, absTarget, Options{
+		Dest:    destRoot,
+		Include: SplitGlobs(os.Getenv("COLLECT_INCLUDE")),
+		Exclude: SplitGlobs(os.Getenv("COLLECT_EXCLUDE")),
+		Logf:    t.Logf,
+	})
 	if err != nil {
-		t.Fatalf("failed to collect Go files: %v", err)
+		t.Fatalf("%v", err)
+	}
+	for _, err := range summary.Errors {
+		t.Errorf("%v", err)
 	}
 
-	copied := 0
-	skipped := 0
-
-	for _, f := range files {
-		base := filepath.Base(f)
-		if !startsWithCtest(base) {
-			skipped++
-			continue
-		}
-
-		relPath, err := filepath.Rel(k8sRoot, f)
-		if err != nil {
-			t.Errorf("failed to get relative path for %s: %v", f, err)
-			skipped++
-			continue
-		}
-
-		destPath := filepath.Join(destRoot, relPath)
-		destDir := filepath.Dir(destPath)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			t.Errorf("failed to create dir %s: %v", destDir, err)
-			skipped++
-			continue
-		}
+	t.Log("===================================")
+	t.Log("Collection Summary")
+	t.Logf("Copied ctest_ files : %d", summary.Copied)
+	t.Logf("Filtered out        : %d", summary.Skipped)
+	t.Logf("Destination         : %s", destRoot)
+	t.Log("===================================")
+}
 
-		data, err := os.ReadFile(f)
-		if err != nil {
-			t.Errorf("failed to read file %s: %v", f, err)
-			skipped++
-			continue
-		}
+// TestRestoreCtestFiles restores the collection RESTORE_SOURCE into the tree.
+// Conflicts stop the restore unless RESTORE_FORCE=true; RESTORE_DRY_RUN=true
+// only lists the files.
+func TestRestoreCtestFiles(t *testing.T) {
+	k8sRoot := k8sRootFromEnv(t)
+	src := os.Getenv("RESTORE_SOURCE")
+	if src == "" {
+		t.Skip("RESTORE_SOURCE is not set")
+	}
 
-		if err := os.WriteFile(destPath, data, 0644); err != nil {
-			t.Errorf("failed to write file %s: %v", destPath, err)
-			skipped++
-			continue
+	summary, err := Restore(src, k8sRoot, RestoreOptions{
+		Force:  strings.EqualFold(os.Getenv("RESTORE_FORCE"), "true"),
+		DryRun: strings.EqualFold(os.Getenv("RESTORE_DRY_RUN"), "true"),
+		Logf:   t.Logf,
+	})
+	if summary != nil {
+		for _, c := range summary.Conflicts {
+			t.Logf("⚠️  %s", c)
 		}
-
-		t.Logf("📄 Copied: %s -> %s", f, destPath)
-		copied++
+	}
+	if err != nil {
+		t.Fatalf("%v", err)
 	}
 
 	t.Log("===================================")
-	t.Log("Collection Summary")
-	t.Logf("Copied ctest_ files : %d", copied)
-	t.Logf("Skipped files       : %d", skipped)
-	t.Logf("Destination root    : %s", destRoot)
+	t.Log("Restore Summary")
+	t.Logf("Restored ctest_ files : %d", summary.Restored)
+	t.Logf("Unchanged             : %d", summary.Unchanged)
+	t.Logf("Conflicts             : %d", len(summary.Conflicts))
 	t.Log("===================================")
 }
-
-func startsWithCtest(name string) bool {
-	return len(name) >= 6 && name[:6] == "ctest_"
-}
@@ -0,0 +1,88 @@
+package collection
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectAndRestore(t *testing.T) {
+	files := map[string]string{
+		"test/e2e/apps/job_test.go":             "package apps\n",
+		"test/e2e/apps/ctest_job_test.go":       "package apps // rewritten\n",
+		"test/e2e/framework/ctest_util_test.go": "package framework\n",
+		"pkg/kubelet/ctest_kubelet_test.go":     "package kubelet\n",
+	}
+	for _, dest := range []string{"collection", "collection.tar.gz", "collection.zip"} {
+		t.Run(dest, func(t *testing.T) {
+			src := t.TempDir()
+			writeTree(t, src, files)
+			dest := filepath.Join(t.TempDir(), dest)
+
+			summary, err := Collect(src, src, Options{Dest: dest, Exclude: []string{"pkg/**"}, Logf: t.Logf})
+			if err != nil {
+				t.Fatalf("Collect failed: %v", err)
+			}
+			// framework is no longer skipped, pkg is excluded
+			if summary.Copied != 2 || summary.Skipped != 1 || len(summary.Manifest.Files) != 2 {
+				t.Fatalf("unexpected summary %+v", summary)
+			}
+
+			// The original of the framework file is missing in the new tree
+			tree := t.TempDir()
+			writeTree(t, tree, map[string]string{"test/e2e/apps/job_test.go": "package apps\n"})
+			summary2, err := Restore(dest, tree, RestoreOptions{Logf: t.Logf})
+			if err == nil || len(summary2.Conflicts) != 1 || !strings.Contains(summary2.Conflicts[0].Reason, "original") {
+				t.Fatalf("expected a missing original conflict, got %+v, %v", summary2, err)
+			}
+			if _, err := os.Stat(filepath.Join(tree, "test/e2e/apps/ctest_job_test.go")); err == nil {
+				t.Fatalf("restore with conflicts wrote files")
+			}
+
+			writeTree(t, tree, map[string]string{"test/e2e/framework/util_test.go": "package framework\n"})
+			if summary2, err = Restore(dest, tree, RestoreOptions{Logf: t.Logf}); err != nil || summary2.Restored != 2 {
+				t.Fatalf("Restore = %+v, %v", summary2, err)
+			}
+			got, _ := os.ReadFile(filepath.Join(tree, "test/e2e/apps/ctest_job_test.go"))
+			if string(got) != files["test/e2e/apps/ctest_job_test.go"] {
+				t.Errorf("restored %q", got)
+			}
+
+			// A locally edited rewrite conflicts, -force overwrites it
+			writeTree(t, tree, map[string]string{"test/e2e/apps/ctest_job_test.go": "package apps // edited\n"})
+			if summary2, err = Restore(dest, tree, RestoreOptions{Logf: t.Logf}); err == nil || summary2.Unchanged != 1 {
+				t.Fatalf("expected a conflict, got %+v, %v", summary2, err)
+			}
+			if summary2, err = Restore(dest, tree, RestoreOptions{Force: true, Logf: t.Logf}); err != nil || summary2.Restored != 1 {
+				t.Fatalf("forced Restore = %+v, %v", summary2, err)
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsTamperedCollection(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"a/x_test.go": "package a\n", "a/ctest_x_test.go": "package a\n"})
+	dest := filepath.Join(t.TempDir(), "collection")
+	if _, err := Collect(src, src, Options{Dest: dest, Logf: t.Logf}); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	writeTree(t, dest, map[string]string{"a/ctest_x_test.go": "package b\n"})
+	if _, err := Restore(dest, src, RestoreOptions{Logf: t.Logf}); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package collection
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// RestoreOptions configures Restore.
+type RestoreOptions struct {
+	// Force overwrites files that differ from the collection and restores files
+	// whose original test is missing.
+	Force bool
+	// DryRun only reports what would be restored.
+	DryRun bool
+	// Logf receives one message per file, fmt.Printf style.
+	Logf func(format string, args ...interface{})
+}
+
+// Conflict is a collected file that cannot be restored as is.
+type Conflict struct {
+	Path   string
+	Reason string
+}
+
+func (c Conflict) String() string { return c.Path + ": " + c.Reason }
+
+// RestoreSummary counts the files of a restore. Unchanged files are already in
+// the tree with the same content.
+type RestoreSummary struct {
+	Restored  int
+	Unchanged int
+	Conflicts []Conflict
+}
+
+// Restore writes the files of the collection at src into k8sRoot. A file
+// conflicts when the tree has a different file at its path or lacks the original
+// test it rewrites; with conflicts nothing is written unless opts.Force is set.
+func Restore(src, k8sRoot string, opts RestoreOptions) (*RestoreSummary, error) {
+	if opts.Logf == nil {
+		opts.Logf = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	}
+	m, files, err := readCollection(src)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RestoreSummary{}
+	var restore []ManifestEntry
+	for _, entry := range m.Files {
+		dest := filepath.Join(k8sRoot, filepath.FromSlash(entry.Path))
+		current, err := os.ReadFile(dest)
+		switch {
+		case err == nil && bytes.Equal(current, files[entry.Path]):
+			summary.Unchanged++
+			continue
+		case err == nil:
+			summary.Conflicts = append(summary.Conflicts, Conflict{entry.Path, "differs from the file in the tree"})
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+		original := filepath.ToSlash(walker.OriginalOf(filepath.FromSlash(entry.Path)))
+		if _, err := os.Stat(filepath.Join(k8sRoot, filepath.FromSlash(original))); err != nil {
+			summary.Conflicts = append(summary.Conflicts, Conflict{entry.Path, "original " + original + " is not in the tree"})
+		}
+		restore = append(restore, entry)
+	}
+	if len(summary.Conflicts) > 0 && !opts.Force {
+		return summary, fmt.Errorf("%d conflicts restoring %s, nothing was written", len(summary.Conflicts), src)
+	}
+
+	for _, entry := range restore {
+		dest := filepath.Join(k8sRoot, filepath.FromSlash(entry.Path))
+		if opts.DryRun {
+			opts.Logf("📄 Would restore: %s", dest)
+			summary.Restored++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return summary, err
+		}
+		if err := os.WriteFile(dest, files[entry.Path], 0644); err != nil {
+			return summary, fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+		opts.Logf("📄 Restored: %s", dest)
+		summary.Restored++
+	}
+	return summary, nil
+}
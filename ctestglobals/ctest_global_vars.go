@@ -48,34 +48,12 @@ var (
 	StartExtendModeSeparator   = "\n==================== CTEST EXTEND ONLY START ===================="
 	StartOverrideModeSeparator = "\n==================== CTEST OVERRIDE ONLY START ===================="
 	StartUnionModeSeparator    = "\n==================== CTEST UNION MODE START ===================="
+	StartAblationModeSeparator = "\n==================== CTEST ABLATION MODE START ===================="
+	BaselineLabel              = "baseline"
+	IncludeBaselineEnv         = "CTEST_INCLUDE_BASELINE"
 	KeyKind                    = "kind"
 	KeyApiVersion              = "apiVersion"
-	FixtureIncludeObjects      = []string{
-		"Deployment",
-		"StatefulSet",
-		"DaemonSet",
-		"ReplicaSet",
-		"Pod",
-		"Service",
-		"ConfigMap",
-		"Secret",
-		"Namespace",
-		"ServiceAccount",
-		"PersistentVolume",
-		"PersistentVolumeClaim",
-		"ResourceQuota",
-		"LimitRange",
-		"Job",
-		"CronJob",
-		"Ingress",
-		"NetworkPolicy",
-		"Role",
-		"RoleBinding",
-		"ClusterRole",
-		"ClusterRoleBinding",
-		"StorageClass",
-		"CustomResourceDefinition",
-	}
+	FixtureIncludeObjects      = KindNames()
 	WeirdPaths            = []string{"github/workflows", ".github", ".travis.yml"}
 	PodSpecIncludeObjects = []string{"deployments", "pods", "statefulSets", "daemonSets", "replicaSets"}
 	DebugPrefix           = func() string {
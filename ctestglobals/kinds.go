@@ -0,0 +1,112 @@
+package ctestglobals
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is a Kubernetes object kind the fixtures can hold.
+type Kind struct {
+	// Name is the kind, e.g. "StatefulSet"
+	Name string
+	// FixtureKey is the key of the kind in the fixture files, which is what
+	// HardcodedConfig.K8sObjects lists, e.g. "statefulSets"
+	FixtureKey string
+	// Resource is the API resource name, e.g. "statefulsets"
+	Resource string
+}
+
+// Kinds is the registry of fixture kinds, in the order they are saved in the
+// fixture files.
+var Kinds = []Kind{
+	{"Deployment", "deployments", "deployments"},
+	{"StatefulSet", "statefulSets", "statefulsets"},
+	{"DaemonSet", "daemonSets", "daemonsets"},
+	{"ReplicaSet", "replicaSets", "replicasets"},
+	{"Pod", "pods", "pods"},
+	{"Service", "services", "services"},
+	{"ConfigMap", "configMaps", "configmaps"},
+	{"Secret", "secrets", "secrets"},
+	{"Namespace", "namespaces", "namespaces"},
+	{"ServiceAccount", "serviceAccounts", "serviceaccounts"},
+	{"PersistentVolume", "persistentVolumes", "persistentvolumes"},
+	{"PersistentVolumeClaim", "persistentVolumeClaims", "persistentvolumeclaims"},
+	{"ResourceQuota", "resourceQuotas", "resourcequotas"},
+	{"LimitRange", "limitRanges", "limitranges"},
+	{"Job", "jobs", "jobs"},
+	{"CronJob", "cronJobs", "cronjobs"},
+	{"Ingress", "ingresses", "ingresses"},
+	{"NetworkPolicy", "networkPolicies", "networkpolicies"},
+	{"Role", "roles", "roles"},
+	{"RoleBinding", "roleBindings", "rolebindings"},
+	{"ClusterRole", "clusterRoles", "clusterroles"},
+	{"ClusterRoleBinding", "clusterRoleBindings", "clusterrolebindings"},
+	{"StorageClass", "storageClasses", "storageclasses"},
+	{"PriorityClass", "priorityClasses", "priorityclasses"},
+	{"CustomResourceDefinition", "customResourceDefinitions", "customresourcedefinitions"},
+}
+
+// KindNames returns the Name of every registered kind.
+func KindNames() []string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = k.Name
+	}
+	return names
+}
+
+// FixtureKeys returns the FixtureKey of every registered kind.
+func FixtureKeys() []string {
+	keys := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		keys[i] = k.FixtureKey
+	}
+	return keys
+}
+
+// LookupKind finds the kind s refers to. The match ignores case and accepts the
+// kind name, the fixture key, the resource name and the singular or naively
+// pluralized forms ("cronjob", "networkpolicys").
+func LookupKind(s string) (Kind, bool) {
+	norm := singular(strings.ToLower(strings.TrimSpace(s)))
+	for _, k := range Kinds {
+		if norm == strings.ToLower(k.Name) {
+			return k, true
+		}
+	}
+	return Kind{}, false
+}
+
+// ResolveFixtureKeys maps every entry of objects to its FixtureKey, see
+// LookupKind. Empty entries are dropped; unknown ones are an error.
+func ResolveFixtureKeys(objects []string) ([]string, error) {
+	var keys, unknown []string
+	for _, o := range objects {
+		if strings.TrimSpace(o) == "" {
+			continue
+		}
+		k, ok := LookupKind(o)
+		if !ok {
+			unknown = append(unknown, o)
+			continue
+		}
+		keys = append(keys, k.FixtureKey)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown K8sObjects %q, expected fixture keys such as %q", unknown, FixtureKeys())
+	}
+	return keys, nil
+}
+
+// singular strips the plural ending of a lower-case kind.
+func singular(s string) string {
+	switch {
+	case strings.HasSuffix(s, "ies"):
+		return strings.TrimSuffix(s, "ies") + "y"
+	case strings.HasSuffix(s, "sses"):
+		return strings.TrimSuffix(s, "es")
+	case strings.HasSuffix(s, "s") && !strings.HasSuffix(s, "ss"):
+		return strings.TrimSuffix(s, "s")
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package ctestglobals
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLookupKind(t *testing.T) {
+	for in, want := range map[string]string{
+		"statefulSets":    "statefulSets",
+		"statefulsets":    "statefulSets",
+		"StatefulSet":     "statefulSets",
+		"cronjob":         "cronJobs",
+		"networkpolicys":  "networkPolicies",
+		"NetworkPolicies": "networkPolicies",
+		"ingress":         "ingresses",
+		"Ingresses":       "ingresses",
+		"storageclasses":  "storageClasses",
+		"services":        "services",
+		" configmaps ":    "configMaps",
+	} {
+		k, ok := LookupKind(in)
+		if !ok || k.FixtureKey != want {
+			t.Errorf("LookupKind(%q) = %q, %v, want %q", in, k.FixtureKey, ok, want)
+		}
+	}
+	for _, in := range []string{"ingressws", "", "podspec"} {
+		if k, ok := LookupKind(in); ok {
+			t.Errorf("LookupKind(%q) = %q, expected no match", in, k.FixtureKey)
+		}
+	}
+}
+
+func TestResolveFixtureKeys(t *testing.T) {
+	keys, err := ResolveFixtureKeys([]string{"pods", "", "Deployment", "daemonsets"})
+	if err != nil {
+		t.Fatalf("ResolveFixtureKeys failed: %v", err)
+	}
+	if want := []string{"pods", "deployments", "daemonSets"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ResolveFixtureKeys = %v, want %v", keys, want)
+	}
+	if _, err := ResolveFixtureKeys([]string{"pods", "ingressws"}); err == nil {
+		t.Errorf("expected an error for an unknown kind")
+	}
+	for _, key := range PodSpecIncludeObjects {
+		if k, ok := LookupKind(key); !ok || k.FixtureKey != key {
+			t.Errorf("PodSpecIncludeObjects entry %q is not a fixture key", key)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// dependencyFixtureKeys maps the reference kinds that can be materialized to their
+// top-level key in the fixture file.
+var dependencyFixtureKeys = map[string]string{
+	RefServiceAccount:        "serviceAccounts",
+	RefConfigMap:             "configMaps",
+	RefSecret:                "secrets",
+	RefPersistentVolumeClaim: "persistentVolumeClaims",
+	RefPriorityClass:         "priorityClasses",
+}
+
+// Dependencies are the objects an effective config references that have to exist
+// before it is created. They are copied from the fixtures, renamed for the test and
+// placed in the test namespace (PriorityClasses are cluster scoped).
+type Dependencies struct {
+	ServiceAccounts        []*corev1.ServiceAccount
+	ConfigMaps             []*corev1.ConfigMap
+	Secrets                []*corev1.Secret
+	PersistentVolumeClaims []*corev1.PersistentVolumeClaim
+	PriorityClasses        []*schedulingv1.PriorityClass
+	// Missing lists the references ("Kind/name") that were not found in the fixtures.
+	Missing []string
+}
+
+// Objects returns the dependencies in the order they should be created.
+func (d *Dependencies) Objects() []runtime.Object {
+	var objs []runtime.Object
+	for _, pc := range d.PriorityClasses {
+		objs = append(objs, pc)
+	}
+	for _, sa := range d.ServiceAccounts {
+		objs = append(objs, sa)
+	}
+	for _, cm := range d.ConfigMaps {
+		objs = append(objs, cm)
+	}
+	for _, secret := range d.Secrets {
+		objs = append(objs, secret)
+	}
+	for _, pvc := range d.PersistentVolumeClaims {
+		objs = append(objs, pvc)
+	}
+	return objs
+}
+
+// WithFixtureDependencies makes the reconciler treat ConfigMaps, Secrets, PVCs,
+// ServiceAccounts and PriorityClasses found in the fixtures as available, so that
+// references to them survive and can be created with MaterializeDependencies.
+func WithFixtureDependencies() Option {
+	return func(o *generateOptions) {
+		o.fixtureDependencies = true
+	}
+}
+
+// MaterializeDependencies looks up the objects referenced by obj in the fixtures
+// (selected with WithFixtureFile/WithFixtureData) and returns copies of them named
+// "<name>-<suffix>" in namespace, together with obj rewritten to use the new names.
+// The test creates deps.Objects() before obj. References that are not in the
+// fixtures are left untouched and listed in Missing.
+func MaterializeDependencies[T any](obj T, namespace, suffix string, opts ...Option) (T, *Dependencies, error) {
+	var zero T
+	o := newGenerateOptions(opts...)
+
+	objJSON, err := stdjson.Marshal(obj)
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to marshal object: %w", err)
+	}
+	var data interface{}
+	if err := stdjson.Unmarshal(objJSON, &data); err != nil {
+		return zero, nil, fmt.Errorf("failed to unmarshal object: %w", err)
+	}
+
+	refs := knownReferences{}
+	collectReferences(data, refs)
+
+	store, err := o.loadFixtures(nil)
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to load fixtures: %w", err)
+	}
+
+	deps := &Dependencies{}
+	renames := make(map[string]map[string]string)
+	for _, kind := range []string{RefPriorityClass, RefServiceAccount, RefConfigMap, RefSecret, RefPersistentVolumeClaim} {
+		for _, name := range sortedNames(refs[kind]) {
+			if builtinReferences[kind][name] {
+				continue
+			}
+			newName := dependencyName(name, suffix)
+			found, err := deps.add(store, kind, name, newName, namespace)
+			if err != nil {
+				return zero, nil, err
+			}
+			if !found {
+				deps.Missing = append(deps.Missing, kind+"/"+name)
+				continue
+			}
+			if renames[kind] == nil {
+				renames[kind] = make(map[string]string)
+			}
+			renames[kind][name] = newName
+		}
+	}
+
+	r := &reconciler{known: knownReferences{}, collect: true, renames: renames}
+	data = r.walk(data, "")
+
+	rewritten, err := stdjson.Marshal(data)
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to marshal rewritten object: %w", err)
+	}
+	var result T
+	if err := stdjson.Unmarshal(rewritten, &result); err != nil {
+		return zero, nil, fmt.Errorf("failed to unmarshal rewritten object: %w", err)
+	}
+
+	for kind, names := range renames {
+		for name, newName := range names {
+			fmt.Printf(ctestglobals.DebugPrefix()+" Materialized %s %q as %q\n", kind, name, newName)
+		}
+	}
+	for _, missing := range deps.Missing {
+		fmt.Println(ctestglobals.DebugPrefix(), "Dependency not found in fixtures:", missing)
+	}
+	return result, deps, nil
+}
+
+// add copies the fixture object of the given kind and name into d. It reports false
+// when the fixtures do not contain such an object.
+func (d *Dependencies) add(store map[string]stdjson.RawMessage, kind, name, newName, namespace string) (bool, error) {
+	raw, ok := store[dependencyFixtureKeys[kind]]
+	if !ok {
+		return false, nil
+	}
+
+	switch kind {
+	case RefServiceAccount:
+		var items []*corev1.ServiceAccount
+		if err := stdjson.Unmarshal(raw, &items); err != nil {
+			return false, fmt.Errorf("failed to decode %s fixtures: %w", kind, err)
+		}
+		for _, sa := range items {
+			if sa != nil && sa.Name == name {
+				d.ServiceAccounts = append(d.ServiceAccounts, &corev1.ServiceAccount{
+					ObjectMeta:                   dependencyMeta(sa.ObjectMeta, newName, namespace),
+					AutomountServiceAccountToken: sa.AutomountServiceAccountToken,
+				})
+				return true, nil
+			}
+		}
+
+	case RefConfigMap:
+		var items []*corev1.ConfigMap
+		if err := stdjson.Unmarshal(raw, &items); err != nil {
+			return false, fmt.Errorf("failed to decode %s fixtures: %w", kind, err)
+		}
+		for _, cm := range items {
+			if cm != nil && cm.Name == name {
+				copied := cm.DeepCopy()
+				copied.ObjectMeta = dependencyMeta(cm.ObjectMeta, newName, namespace)
+				d.ConfigMaps = append(d.ConfigMaps, copied)
+				return true, nil
+			}
+		}
+
+	case RefSecret:
+		var items []*corev1.Secret
+		if err := stdjson.Unmarshal(raw, &items); err != nil {
+			return false, fmt.Errorf("failed to decode %s fixtures: %w", kind, err)
+		}
+		for _, secret := range items {
+			if secret != nil && secret.Name == name {
+				copied := secret.DeepCopy()
+				copied.ObjectMeta = dependencyMeta(secret.ObjectMeta, newName, namespace)
+				d.Secrets = append(d.Secrets, copied)
+				return true, nil
+			}
+		}
+
+	case RefPersistentVolumeClaim:
+		var items []*corev1.PersistentVolumeClaim
+		if err := stdjson.Unmarshal(raw, &items); err != nil {
+			return false, fmt.Errorf("failed to decode %s fixtures: %w", kind, err)
+		}
+		for _, pvc := range items {
+			if pvc != nil && pvc.Name == name {
+				copied := pvc.DeepCopy()
+				copied.ObjectMeta = dependencyMeta(pvc.ObjectMeta, newName, namespace)
+				// The source repo's volumes and storage classes do not exist in the
+				// test cluster, let the default provisioner bind the claim
+				copied.Spec.VolumeName = ""
+				copied.Spec.StorageClassName = nil
+				copied.Spec.Selector = nil
+				copied.Status = corev1.PersistentVolumeClaimStatus{}
+				d.PersistentVolumeClaims = append(d.PersistentVolumeClaims, copied)
+				return true, nil
+			}
+		}
+
+	case RefPriorityClass:
+		var items []*schedulingv1.PriorityClass
+		if err := stdjson.Unmarshal(raw, &items); err != nil {
+			return false, fmt.Errorf("failed to decode %s fixtures: %w", kind, err)
+		}
+		for _, pc := range items {
+			if pc != nil && pc.Name == name {
+				copied := pc.DeepCopy()
+				copied.ObjectMeta = dependencyMeta(pc.ObjectMeta, newName, "")
+				// A second global default would be rejected by the API server
+				copied.GlobalDefault = false
+				d.PriorityClasses = append(d.PriorityClasses, copied)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// fixtureReferences returns the names of all objects in the fixtures that
+// MaterializeDependencies can create.
+func fixtureReferences(store map[string]stdjson.RawMessage) (knownReferences, error) {
+	known := knownReferences{}
+	for kind, key := range dependencyFixtureKeys {
+		raw, ok := store[key]
+		if !ok {
+			continue
+		}
+		var items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := stdjson.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("failed to decode %s fixtures: %w", key, err)
+		}
+		for _, item := range items {
+			known.add(kind, item.Metadata.Name)
+		}
+	}
+	return known, nil
+}
+
+// dependencyMeta keeps only the metadata that makes sense on a fresh object.
+func dependencyMeta(meta metav1.ObjectMeta, name, namespace string) metav1.ObjectMeta {
+	out := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels:    meta.Labels,
+	}
+	for k, v := range meta.Annotations {
+		if k == corev1.LastAppliedConfigAnnotation {
+			continue
+		}
+		if out.Annotations == nil {
+			out.Annotations = make(map[string]string)
+		}
+		out.Annotations[k] = v
+	}
+	return out
+}
+
+func dependencyName(name, suffix string) string {
+	if suffix == "" {
+		return name
+	}
+	return name + "-" + suffix
+}
+
+func sortedNames(names map[string]bool) []string {
+	out := make([]string, 0, len(names))
+	for name := range names {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
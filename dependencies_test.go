@@ -0,0 +1,145 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaterializeDependencies(t *testing.T) {
+	storageClass := "fast-ssd"
+	store := map[string]stdjson.RawMessage{}
+	for key, items := range map[string]interface{}{
+		"configMaps": []v1.ConfigMap{{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "prod", ResourceVersion: "42"},
+			Data:       map[string]string{"mode": "fast"},
+		}},
+		"persistentVolumeClaims": []v1.PersistentVolumeClaim{{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "prod"},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-123", StorageClassName: &storageClass},
+		}},
+		"priorityClasses": []schedulingv1.PriorityClass{{
+			ObjectMeta:    metav1.ObjectMeta{Name: "high"},
+			Value:         1000,
+			GlobalDefault: true,
+		}},
+	} {
+		raw, err := stdjson.Marshal(items)
+		if err != nil {
+			t.Fatalf("failed to marshal %s: %v", key, err)
+		}
+		store[key] = raw
+	}
+
+	spec := v1.PodSpec{
+		ServiceAccountName: "default",
+		PriorityClassName:  "high",
+		Containers: []v1.Container{{
+			Name:    "c",
+			EnvFrom: []v1.EnvFromSource{{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-config"}}}},
+			Env: []v1.EnvVar{{Name: "TOKEN", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "token"}, Key: "t",
+			}}}},
+		}},
+		Volumes: []v1.Volume{{Name: "data", VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data"},
+		}}},
+	}
+
+	got, deps, err := MaterializeDependencies(spec, "e2e-ns", "abc", WithFixtureData(store))
+	if err != nil {
+		t.Fatalf("MaterializeDependencies failed: %v", err)
+	}
+
+	if got.PriorityClassName != "high-abc" {
+		t.Errorf("priorityClassName = %q, want high-abc", got.PriorityClassName)
+	}
+	if got.ServiceAccountName != "default" {
+		t.Errorf("builtin service account was renamed to %q", got.ServiceAccountName)
+	}
+	if name := got.Containers[0].EnvFrom[0].ConfigMapRef.Name; name != "app-config-abc" {
+		t.Errorf("envFrom configMap = %q, want app-config-abc", name)
+	}
+	if name := got.Volumes[0].PersistentVolumeClaim.ClaimName; name != "data-abc" {
+		t.Errorf("claimName = %q, want data-abc", name)
+	}
+	if name := got.Containers[0].Env[0].ValueFrom.SecretKeyRef.Name; name != "token" {
+		t.Errorf("missing secret reference changed to %q", name)
+	}
+
+	if len(deps.ConfigMaps) != 1 || deps.ConfigMaps[0].Namespace != "e2e-ns" || deps.ConfigMaps[0].ResourceVersion != "" {
+		t.Errorf("unexpected configMaps: %+v", deps.ConfigMaps)
+	}
+	if len(deps.PersistentVolumeClaims) != 1 {
+		t.Fatalf("expected 1 PVC, got %d", len(deps.PersistentVolumeClaims))
+	}
+	if pvc := deps.PersistentVolumeClaims[0]; pvc.Spec.VolumeName != "" || pvc.Spec.StorageClassName != nil {
+		t.Errorf("PVC still bound to source cluster: %+v", pvc.Spec)
+	}
+	if len(deps.PriorityClasses) != 1 || deps.PriorityClasses[0].Namespace != "" || deps.PriorityClasses[0].GlobalDefault {
+		t.Errorf("unexpected priorityClasses: %+v", deps.PriorityClasses)
+	}
+	if len(deps.Missing) != 1 || deps.Missing[0] != "Secret/token" {
+		t.Errorf("Missing = %v, want [Secret/token]", deps.Missing)
+	}
+	if objs := deps.Objects(); len(objs) != 3 {
+		t.Errorf("expected 3 objects to create, got %d", len(objs))
+	}
+
+	// Generate keeps references that MaterializeDependencies can satisfy
+	item := testContainerItem()
+	item.HardcodedConfig = []v1.Container{{Name: "test-container", Image: "busybox"}}
+	pods, err := stdjson.Marshal([]v1.Pod{{Spec: v1.PodSpec{Containers: []v1.Container{{
+		Name:    "a",
+		EnvFrom: []v1.EnvFromSource{{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-config"}}}},
+	}}}}})
+	if err != nil {
+		t.Fatalf("failed to marshal pods: %v", err)
+	}
+	store["pods"] = pods
+	configs, _, err := Generate[[]v1.Container](item, WithFixtureData(store), WithFixtureDependencies())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(configs) != 1 || len(configs[0][0].EnvFrom) != 1 {
+		t.Errorf("expected envFrom of fixture ConfigMap to be kept, got %+v", configs)
+	}
+}
+
+func TestMaterializeGeneratedDependencies(t *testing.T) {
+	cms, err := stdjson.Marshal([]v1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "app-config"}, Data: map[string]string{"mode": "fast"}}})
+	if err != nil {
+		t.Fatalf("failed to marshal configMaps: %v", err)
+	}
+	pods, err := stdjson.Marshal([]v1.Pod{{Spec: v1.PodSpec{Containers: []v1.Container{{
+		Name:    "a",
+		EnvFrom: []v1.EnvFromSource{{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-config"}}}},
+	}}}}})
+	if err != nil {
+		t.Fatalf("failed to marshal pods: %v", err)
+	}
+	store := map[string]stdjson.RawMessage{"configMaps": cms, "pods": pods}
+
+	// The path of the rewritten tests: generate, then create what the config needs
+	item := testContainerItem()
+	configs, _, err := GenerateEffectiveConfigReturnType[[]v1.Container](item, ExtendOnly, WithFixtureData(store))
+	if err != nil {
+		t.Fatalf("GenerateEffectiveConfigReturnType failed: %v", err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 config, got %+v", configs)
+	}
+	got, deps, err := MaterializeDependencies(configs[0], "e2e-ns", "abc", WithFixtureData(store))
+	if err != nil {
+		t.Fatalf("MaterializeDependencies failed: %v", err)
+	}
+	if len(deps.ConfigMaps) != 1 || deps.ConfigMaps[0].Name != "app-config-abc" || deps.ConfigMaps[0].Data["mode"] != "fast" {
+		t.Errorf("unexpected configMaps: %+v", deps.ConfigMaps)
+	}
+	if len(got[0].EnvFrom) != 1 || got[0].EnvFrom[0].ConfigMapRef.Name != "app-config-abc" {
+		t.Errorf("envFrom not pointed at the created ConfigMap: %+v", got[0].EnvFrom)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/kubernetes/test/ctest/ctestglobals"
@@ -53,6 +54,9 @@ var (
 	// Storage
 	StorageClasses []*storagev1.StorageClass
 
+	// Scheduling
+	PriorityClasses []*schedulingv1.PriorityClass
+
 	// Extensions
 	CustomResourceDefinitions []*apiextensionsv1.CustomResourceDefinition
 
@@ -106,6 +110,9 @@ func InitializeFixtures() {
 	// Storage
 	StorageClasses = []*storagev1.StorageClass{}
 
+	// Scheduling
+	PriorityClasses = []*schedulingv1.PriorityClass{}
+
 	// Extensions
 	CustomResourceDefinitions = []*apiextensionsv1.CustomResourceDefinition{}
 
@@ -280,6 +287,14 @@ func AddStorageClass(storageClass *storagev1.StorageClass) {
 	AllObjects = append(AllObjects, storageClass)
 }
 
+// ========== SCHEDULING ADDERS ==========
+func AddPriorityClass(priorityClass *schedulingv1.PriorityClass) {
+	mu.Lock()
+	defer mu.Unlock()
+	PriorityClasses = append(PriorityClasses, priorityClass)
+	AllObjects = append(AllObjects, priorityClass)
+}
+
 // ========== EXTENSIONS ADDERS ==========
 func AddCustomResourceDefinition(crd *apiextensionsv1.CustomResourceDefinition) {
 	mu.Lock()
@@ -434,6 +449,13 @@ func GetStorageClasses() []*storagev1.StorageClass {
 	return StorageClasses
 }
 
+// Scheduling Getters
+func GetPriorityClasses() []*schedulingv1.PriorityClass {
+	mu.RLock()
+	defer mu.RUnlock()
+	return PriorityClasses
+}
+
 // Extensions Getters
 func GetCustomResourceDefinitions() []*apiextensionsv1.CustomResourceDefinition {
 	mu.RLock()
@@ -528,6 +550,7 @@ func SaveFixtures() error {
 		ClusterRoles              []*rbacv1.ClusterRole                       `json:"clusterRoles"`
 		ClusterRoleBindings       []*rbacv1.ClusterRoleBinding                `json:"clusterRoleBindings"`
 		StorageClasses            []*storagev1.StorageClass                   `json:"storageClasses"`
+		PriorityClasses           []*schedulingv1.PriorityClass               `json:"priorityClasses"`
 		CustomResourceDefinitions []*apiextensionsv1.CustomResourceDefinition `json:"customResourceDefinitions"`
 	}{
 		Deployments:               Deployments,
@@ -553,6 +576,7 @@ func SaveFixtures() error {
 		ClusterRoles:              ClusterRoles,
 		ClusterRoleBindings:       ClusterRoleBindings,
 		StorageClasses:            StorageClasses,
+		PriorityClasses:           PriorityClasses,
 		CustomResourceDefinitions: CustomResourceDefinitions,
 	}
 
@@ -609,6 +633,7 @@ func LoadFixtures() error {
 		ClusterRoles              []*rbacv1.ClusterRole                       `json:"clusterRoles"`
 		ClusterRoleBindings       []*rbacv1.ClusterRoleBinding                `json:"clusterRoleBindings"`
 		StorageClasses            []*storagev1.StorageClass                   `json:"storageClasses"`
+		PriorityClasses           []*schedulingv1.PriorityClass               `json:"priorityClasses"`
 		CustomResourceDefinitions []*apiextensionsv1.CustomResourceDefinition `json:"customResourceDefinitions"`
 	}
 
@@ -640,6 +665,7 @@ func LoadFixtures() error {
 	ClusterRoles = fixturesData.ClusterRoles
 	ClusterRoleBindings = fixturesData.ClusterRoleBindings
 	StorageClasses = fixturesData.StorageClasses
+	PriorityClasses = fixturesData.PriorityClasses
 	CustomResourceDefinitions = fixturesData.CustomResourceDefinitions
 
 	totalCount := getTotalCount()
@@ -682,6 +708,7 @@ func ClearFixtures() error {
 	ClusterRoles = nil
 	ClusterRoleBindings = nil
 	StorageClasses = nil
+	PriorityClasses = nil
 	CustomResourceDefinitions = nil
 
 	// Remove file
@@ -731,6 +758,7 @@ func GetCounts() map[string]int {
 		"ClusterRoles":              len(ClusterRoles),
 		"ClusterRoleBindings":       len(ClusterRoleBindings),
 		"StorageClasses":            len(StorageClasses),
+		"PriorityClasses":           len(PriorityClasses),
 		"CustomResourceDefinitions": len(CustomResourceDefinitions),
 	}
 }
@@ -749,5 +777,5 @@ func getTotalCount() int {
 		len(ResourceQuotas) + len(LimitRanges) + len(Jobs) + len(CronJobs) +
 		len(Ingresses) + len(NetworkPolicies) + len(Roles) + len(RoleBindings) +
 		len(ClusterRoles) + len(ClusterRoleBindings) + len(StorageClasses) +
-		len(CustomResourceDefinitions)
+		len(PriorityClasses) + len(CustomResourceDefinitions)
 }
@@ -19,6 +19,7 @@ var fixtureFS embed.FS
 // - If requested types are passed and any of them are not present in the file -> returns an error listing missing keys.
 // - If a requested key exists but its value is JSON null, it is omitted from the returned map (no error).
 // - If no types requested: include all top-level keys whose value != null.
+// - Types are matched like ctestglobals.LookupKind, so "configmaps" or "ConfigMap" load "configMaps".
 func LoadFixturesAsJSON(fileName string, types ...string) (map[string]json.RawMessage, error) {
 	// Construct the path within the embedded filesystem
 	// fsPath := "fixtures/" + fileName
@@ -62,14 +63,20 @@ func LoadFixturesAsJSON(fileName string, types ...string) (map[string]json.RawMe
 	// When specific types requested, ensure they exist in the file.
 	var missing []string
 	for _, t := range types {
-		v, ok := root[t]
+		key := t
+		if _, ok := root[key]; !ok {
+			if kind, found := ctestglobals.LookupKind(t); found {
+				key = kind.FixtureKey
+			}
+		}
+		v, ok := root[key]
 		if !ok {
 			missing = append(missing, t)
 			continue
 		}
 		// If it exists but is null, skip (no error)
 		if !isNull(v) {
-			result[t] = v
+			result[key] = v
 		}
 	}
 
@@ -41,3 +41,33 @@ func keys(m map[string]json.RawMessage) []string {
 	}
 	return out
 }
+
+func TestFixtureKeysMatchKindRegistry(t *testing.T) {
+	all, err := LoadFixturesAsJSON(ctestglobals.TestExternalFixtureFile)
+	if err != nil {
+		t.Fatalf("load all fixtures failed: %v", err)
+	}
+	registered := make(map[string]bool)
+	for _, key := range ctestglobals.FixtureKeys() {
+		registered[key] = true
+	}
+	for key := range all {
+		if !registered[key] {
+			t.Errorf("fixture key %q is missing from ctestglobals.Kinds", key)
+		}
+	}
+
+	// Kind names and lower-case plurals load the same fixtures
+	selected, err := LoadFixturesAsJSON(ctestglobals.TestExternalFixtureFile, "statefulsets", "Deployment")
+	if err != nil {
+		t.Fatalf("tolerant load failed: %v", err)
+	}
+	for _, key := range []string{"statefulSets", "deployments"} {
+		if _, ok := selected[key]; !ok && all[key] != nil {
+			t.Errorf("expected %q in %v", key, keys(selected))
+		}
+	}
+	if _, err := LoadFixturesAsJSON(ctestglobals.TestExternalFixtureFile, "ingressws"); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+}
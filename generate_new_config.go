@@ -15,7 +15,6 @@ import (
 	// "k8s.io/apimachinery/pkg/runtime"
 	// "k8s.io/apimachinery/pkg/runtime"
 	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
-	fixtures "k8s.io/kubernetes/test/ctest/fixtures"
 	utils "k8s.io/kubernetes/test/ctest/utils"
 )
 
@@ -26,6 +25,9 @@ const (
 	ExtendOnly Mode = iota
 	OverrideOnly
 	Union
+	// Ablation removes one optional field (or subtree) of the hardcoded config
+	// at a time. It does not use external fixtures.
+	Ablation
 )
 
 // // GenerateEffectiveConfig takes a single entry (one element) from
@@ -229,6 +231,8 @@ const (
 //   - ExtendOnly: Adds missing fields from external fixtures without overriding existing values
 //   - OverrideOnly: Overrides existing fields with external values, keeping missing fields unchanged
 //   - Union: Performs both override and extend operations (override first, then extend)
+//   - Ablation: Removes each optional field of the hardcoded config one at a time, skipping
+//     fields the API marks as required. External fixtures are not loaded in this mode.
 //
 // Returns:
 //   - effectiveObjs: A slice of typed Kubernetes objects ([]T) resulting from the merge operation.
@@ -273,12 +277,37 @@ const (
 //   - External fixtures are loaded from "./fixtures/{TestExternalFixtureFile}"
 //   - The function uses k8s.io/apimachinery/pkg/util/json for Kubernetes-compatible JSON handling
 //   - All merge operations preserve Kubernetes object semantics and type safety
-func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (effectiveObjs []T, effectiveObjsJson []byte, err error) {
-	fmt.Println("=== GENERATE EFFECTIVE CONFIG START ===")
+//   - The entry is read by reflection and passed on to Generate; it is kept for the
+//     rewritten tests that already call it. New code should call Generate with options.
+//   - When CTEST_INCLUDE_BASELINE=true, the unmodified hardcoded config is returned as
+//     case 0 (see CaseLabel), so every rewritten test also runs the baseline.
+//   - References to ConfigMaps, Secrets, PVCs, ServiceAccounts and PriorityClasses of
+//     the fixtures are kept (see WithFixtureDependencies); rewritten tests create them
+//     with MaterializeDependencies.
+//   - opts are applied after the ones above, e.g. to select other fixtures.
+func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode, opts ...Option) (effectiveObjs []T, effectiveObjsJson []byte, err error) {
+	item, err := hardcodedConfigItemFromEntry(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	options := []Option{WithMode(mode), WithFixtureDependencies()}
+	if BaselineEnabled() {
+		options = append(options, WithIncludeOriginal(true))
+	}
+	return Generate[T](item, append(options, opts...)...)
+}
+
+// hardcodedConfigItemFromEntry converts an untyped HardcodedConfig entry into a
+// ctestglobals.HardcodedConfigItem. The entry's fields are looked up by name, so
+// any struct with the same shape (such as an element of ctestglobals.HardcodedConfig)
+// is accepted.
+func hardcodedConfigItemFromEntry(entry interface{}) (ctestglobals.HardcodedConfigItem, error) {
+	var item ctestglobals.HardcodedConfigItem
+
 	v := reflect.ValueOf(entry)
 	if !v.IsValid() {
 		fmt.Println(ctestglobals.DebugPrefix(), "entry is nil or invalid")
-		return nil, nil, errors.New("entry is nil or invalid")
+		return item, errors.New("entry is nil or invalid")
 	}
 	// If pointer, dereference
 	if v.Kind() == reflect.Ptr {
@@ -286,21 +315,72 @@ func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (eff
 	}
 	if v.Kind() != reflect.Struct {
 		fmt.Println(ctestglobals.DebugPrefix(), "entry must be a struct or pointer to struct")
-		return nil, nil, fmt.Errorf("entry must be a struct or pointer to struct; got %T", entry)
+		return item, fmt.Errorf("entry must be a struct or pointer to struct; got %T", entry)
 	}
 
 	// Find HardcodedConfig field
 	fieldVal := v.FieldByName("HardcodedConfig")
 	if !fieldVal.IsValid() {
 		fmt.Println(ctestglobals.DebugPrefix(), "entry does not have HardcodedConfig field")
-		return nil, nil, errors.New("entry does not have HardcodedConfig field")
+		return item, errors.New("entry does not have HardcodedConfig field")
 	}
 	if !fieldVal.CanInterface() {
 		fmt.Println(ctestglobals.DebugPrefix(), "cannot access HardcodedConfig field (unexported?)")
-		return nil, nil, errors.New("cannot access HardcodedConfig field (unexported?)")
+		return item, errors.New("cannot access HardcodedConfig field (unexported?)")
+	}
+	item.HardcodedConfig = fieldVal.Interface()
+
+	if f := v.FieldByName("FixtureFileName"); f.IsValid() && f.Kind() == reflect.String {
+		item.FixtureFileName = f.String()
+	}
+	if f := v.FieldByName("Field"); f.IsValid() && f.Kind() == reflect.String {
+		item.Field = f.String()
+	}
+	if f := v.FieldByName("TestInfo"); f.IsValid() && f.Kind() == reflect.Slice {
+		for i := 0; i < f.Len(); i++ {
+			if f.Index(i).Kind() == reflect.String {
+				item.TestInfo = append(item.TestInfo, f.Index(i).String())
+			}
+		}
+	}
+
+	k8sObjects := v.FieldByName("K8sObjects")
+	if k8sObjects.IsValid() && k8sObjects.Kind() == reflect.Slice {
+		for i := 0; i < k8sObjects.Len(); i++ {
+			if k8sObjects.Index(i).Kind() == reflect.String {
+				item.K8sObjects = append(item.K8sObjects, k8sObjects.Index(i).String())
+			}
+		}
+	} else {
+		fmt.Printf(ctestglobals.DebugPrefix()+" [DEBUG] K8sObjects is not a slice or invalid (Kind: %v, IsValid: %v)\n",
+			k8sObjects.Kind(), k8sObjects.IsValid())
 	}
 
-	hardcoded := fieldVal.Interface()
+	return item, nil
+}
+
+// Generate combines the hardcoded configuration of item with external fixture data and
+// returns the effective configurations as typed objects plus a JSON array of them.
+//
+// It is the typed entry point behind GenerateEffectiveConfigReturnType. Behavior is
+// controlled with options; without any, it behaves like
+// GenerateEffectiveConfigReturnType[T](item, ExtendOnly) except that references
+// to fixture objects are repaired like any other dangling reference:
+//
+//	configObjs, configJson, err := ctest.Generate[v1.PodSpec](item,
+//	    ctest.WithMode(ctest.Union),
+//	    ctest.WithMaxConfigs(10),
+//	    ctest.WithSeed(42),
+//	    ctest.WithProtectedPaths("restartPolicy", "containers.name"),
+//	)
+//
+// Results identical to the hardcoded configuration are filtered out; when nothing
+// differs, Generate returns nil slices and no error.
+func Generate[T any](item ctestglobals.HardcodedConfigItem, opts ...Option) (effectiveObjs []T, effectiveObjsJson []byte, err error) {
+	fmt.Println("=== GENERATE EFFECTIVE CONFIG START ===")
+	o := newGenerateOptions(opts...)
+
+	hardcoded := item.HardcodedConfig
 	if hardcoded == nil {
 		fmt.Println(ctestglobals.DebugPrefix(), "HardcodedConfig is nil")
 		return nil, nil, errors.New("HardcodedConfig is nil")
@@ -313,75 +393,63 @@ func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (eff
 		return nil, nil, fmt.Errorf("failed to marshal HardcodedConfig to JSON: %w", err)
 	}
 
-	// Get the field name and k8s objects
-	hardcodedConfigField := v.FieldByName("Field")
-	k8sObjects := v.FieldByName("K8sObjects")
-
 	fmt.Println(ctestglobals.DebugPrefix(), "K8sObjects: ")
-	fmt.Println(k8sObjects)
+	fmt.Println(item.K8sObjects)
 
-	// Convert slice to comma-separated string
+	// Drop empty entries from K8sObjects
 	var objectsList []string
-	if k8sObjects.IsValid() && k8sObjects.Kind() == reflect.Slice {
-		if k8sObjects.IsNil() {
-			fmt.Println(ctestglobals.DebugPrefix(), "[DEBUG] K8sObjects is nil, using empty list")
-		} else {
-			hasEmptyStrings := false
-			for i := 0; i < k8sObjects.Len(); i++ {
-				item := k8sObjects.Index(i)
-				if item.Kind() == reflect.String {
-					str := item.String()
-					if str == "" {
-						hasEmptyStrings = true
-						fmt.Println(ctestglobals.DebugPrefix(), "[DEBUG] Found empty string at index %d in K8sObjects\n", i)
-					} else {
-						objectsList = append(objectsList, str)
-					}
-				}
-			}
-
-			if hasEmptyStrings {
-				fmt.Println(ctestglobals.DebugPrefix(), "[WARNING] K8sObjects contains empty strings which were filtered out")
-			}
-
-			if k8sObjects.Len() > 0 && len(objectsList) == 0 {
-				fmt.Println(ctestglobals.DebugPrefix(), "[WARNING] All strings in K8sObjects were empty after filtering")
+	if item.K8sObjects == nil {
+		fmt.Println(ctestglobals.DebugPrefix(), "[DEBUG] K8sObjects is nil, using empty list")
+	} else {
+		hasEmptyStrings := false
+		for i, str := range item.K8sObjects {
+			if str == "" {
+				hasEmptyStrings = true
+				fmt.Printf(ctestglobals.DebugPrefix()+" [DEBUG] Found empty string at index %d in K8sObjects\n", i)
+			} else {
+				objectsList = append(objectsList, str)
 			}
 		}
-	} else {
-		fmt.Printf(ctestglobals.DebugPrefix(), "[DEBUG] K8sObjects is not a slice or invalid (Kind: %v, IsValid: %v)\n",
-			k8sObjects.Kind(), k8sObjects.IsValid())
-	}
 
-	fmt.Printf(ctestglobals.DebugPrefix(), "[DEBUG] Loading fixtures for types: %v (count: %d)\n", objectsList, len(objectsList))
-
-	fixtures, err := fixtures.LoadFixturesAsJSON(
-		ctestglobals.TestExternalFixtureFile,
-		objectsList...,
-	)
+		if hasEmptyStrings {
+			fmt.Println(ctestglobals.DebugPrefix(), "[WARNING] K8sObjects contains empty strings which were filtered out")
+		}
 
-	if err != nil {
-		fmt.Println(ctestglobals.DebugPrefix(), "load all fixtures failed")
-		log.Fatalf("load all fixtures failed: %v", err)
-	}
-	externalFieldValues, err := utils.GetFieldValuesFromFixtures(fixtures, hardcodedConfigField.String())
-	if err != nil {
-		fmt.Println(ctestglobals.DebugPrefix(), "err:", err)
+		if len(item.K8sObjects) > 0 && len(objectsList) == 0 {
+			fmt.Println(ctestglobals.DebugPrefix(), "[WARNING] All strings in K8sObjects were empty after filtering")
+		}
 	}
 
 	// Process the results based on mode
 	var jsonResults [][]byte
-	if len(fixtures) != 0 {
-		switch mode {
-		case ExtendOnly:
-			// fmt.Printf(ctestglobals.DebugPrefix(), "Calling ExtendOnly with %d external values\n", len(externalFieldValues))
-			jsonResults, err = extendOnly(originalRawJSON, externalFieldValues)
-		case OverrideOnly:
-			jsonResults, err = overrideOnly(originalRawJSON, externalFieldValues, KeepMissingOriginal)
-		case Union:
-			jsonResults, err = union(originalRawJSON, externalFieldValues)
-		default:
-			return nil, nil, fmt.Errorf("unknown Mode: %v", mode)
+	if o.mode == Ablation {
+		// Ablation only removes fields from the hardcoded config, so fixtures are not needed
+		objType := reflect.TypeOf((*T)(nil)).Elem()
+		fmt.Println(ctestglobals.DebugPrefix(), "Ablating optional fields of type:", objType)
+		jsonResults, err = ablate(originalRawJSON, objType)
+	} else {
+		loadedFixtures, loadErr := o.loadFixtures(objectsList)
+		if loadErr != nil {
+			fmt.Println(ctestglobals.DebugPrefix(), "load all fixtures failed")
+			return nil, nil, fmt.Errorf("load all fixtures failed: %w", loadErr)
+		}
+		var externalFieldValues []stdjson.RawMessage
+		externalFieldValues, err = utils.GetFieldValuesFromFixtures(loadedFixtures, item.Field)
+		if err != nil {
+			fmt.Println(ctestglobals.DebugPrefix(), "err:", err)
+		}
+
+		if len(loadedFixtures) != 0 {
+			switch o.mode {
+			case ExtendOnly:
+				jsonResults, err = extendOnly(originalRawJSON, externalFieldValues)
+			case OverrideOnly:
+				jsonResults, err = overrideOnly(originalRawJSON, externalFieldValues, KeepMissingOriginal)
+			case Union:
+				jsonResults, err = union(originalRawJSON, externalFieldValues)
+			default:
+				return nil, nil, fmt.Errorf("unknown Mode: %v", o.mode)
+			}
 		}
 	}
 
@@ -394,9 +462,43 @@ func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (eff
 		jsonResults = [][]byte{originalRawJSON}
 	}
 
+	// Put protected fields back to their hardcoded values
+	if len(o.protectedPaths) > 0 {
+		jsonResults, err = restoreProtectedPaths(originalRawJSON, jsonResults, o.protectedPaths)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to restore protected paths: %w", err)
+		}
+	}
+
+	// Fix up references to objects that do not exist in the test
+	knownRefs := o.knownRefs
+	if o.fixtureDependencies && o.reconcile != ReconcileOff {
+		store, err := o.loadFixtures(nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load fixtures for dependencies: %w", err)
+		}
+		knownRefs, err = fixtureReferences(store)
+		if err != nil {
+			return nil, nil, err
+		}
+		for kind, names := range o.knownRefs {
+			for name := range names {
+				knownRefs.add(kind, name)
+			}
+		}
+	}
+	var reports []ReconcileReport
+	jsonResults, reports, err = reconcileResults(originalRawJSON, jsonResults, o.reconcile, knownRefs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reconcile references: %w", err)
+	}
+	printReconcileReports(reports)
+	if o.reconcileReport != nil {
+		*o.reconcileReport = reports
+	}
+
 	// Convert each JSON result to type T and filter out duplicates
 	effectiveObjs = make([]T, 0, len(jsonResults))
-	var effectiveObjectsJSON []stdjson.RawMessage
 
 	// Convert original hardcoded to T for comparison
 	var originalObj T
@@ -404,15 +506,11 @@ func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (eff
 		return nil, nil, fmt.Errorf("failed to unmarshal original config to type %T: %w", originalObj, err)
 	}
 
-	// fmt.Printf(ctestglobals.DebugPrefix(), "Original hardcoded config (as %T): %+v\n", originalObj, originalObj)
-
 	// Compare JSON strings by normalizing them (remove whitespace)
 	normalizedOriginalJSON := normalizeJSON(originalRawJSON)
-	fmt.Printf(ctestglobals.DebugPrefix(), "Normalized original JSON: %s\n", normalizedOriginalJSON)
+	fmt.Printf(ctestglobals.DebugPrefix()+" Normalized original JSON: %s\n", normalizedOriginalJSON)
 
 	for i, jsonData := range jsonResults {
-		// log.Printf("\n=== Processing Result %d/%d ===", i+1, len(jsonResults))
-
 		// Create a new zero value of type T
 		var target T
 
@@ -429,24 +527,28 @@ func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (eff
 		isDeepEqual := reflect.DeepEqual(target, originalObj)
 
 		if isIdenticalToOriginal || isDeepEqual {
-			// fmt.Printf(ctestglobals.DebugPrefix(), "⚠️  Result %d is identical to original hardcoded config, filtering out\n", i+1)
-			// fmt.Printf(ctestglobals.DebugPrefix(), "   JSON identical: %v, DeepEqual: %v\n", isIdenticalToOriginal, isDeepEqual)
 			continue // Skip this result
 		}
 
+		if o.validate != nil {
+			if err := o.validate(target); err != nil {
+				fmt.Printf(ctestglobals.DebugPrefix()+" ⚠️  Result %d failed validation, dropping: %v\n", i+1, err)
+				continue
+			}
+		}
+
 		// Add to results if not identical
 		effectiveObjs = append(effectiveObjs, target)
 
-		// Convert the typed object back to JSON
-		objJSON, err := stdjson.Marshal(target)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to marshal effective object %d to JSON: %w", i, err)
-		}
-		effectiveObjectsJSON = append(effectiveObjectsJSON, objJSON)
+		fmt.Printf(ctestglobals.DebugPrefix()+" ✅ Added Result %d as unique effective object\n", i+1)
+		fmt.Printf(ctestglobals.DebugPrefix()+" Result value: %+v\n", target)
+	}
 
-		fmt.Println(ctestglobals.DebugPrefix(), "✅ Added Result %d as unique effective object\n", i+1)
-		log.Printf(ctestglobals.DebugPrefix(), "Successfully converted to type %T", target)
-		fmt.Println(ctestglobals.DebugPrefix(), "Result value: %+v\n", target)
+	effectiveObjs = sampleConfigs(effectiveObjs, o)
+
+	if o.includeOriginal {
+		fmt.Println(ctestglobals.DebugPrefix(), "Including the unmodified hardcoded config as case 0 (baseline)")
+		effectiveObjs = append([]T{originalObj}, effectiveObjs...)
 	}
 
 	// Check if we have any unique results after filtering
@@ -455,17 +557,12 @@ func GenerateEffectiveConfigReturnType[T any](entry interface{}, mode Mode) (eff
 		return nil, nil, nil
 	}
 
-	fmt.Println(ctestglobals.DebugPrefix(), "✅ Generated %d unique effective object(s) after filtering\n", len(effectiveObjs))
+	fmt.Printf(ctestglobals.DebugPrefix()+" ✅ Generated %d unique effective object(s) after filtering\n", len(effectiveObjs))
 
 	// Marshal ALL effective objects as JSON array for effectiveObjsJson
-	if len(effectiveObjectsJSON) > 0 {
-		effectiveObjsJson, err = stdjson.Marshal(effectiveObjectsJSON)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to marshal effective objects to JSON array: %w", err)
-		}
-	} else {
-		// This shouldn't happen since we checked len(effectiveObjs) > 0
-		effectiveObjsJson = originalRawJSON
+	effectiveObjsJson, err = stdjson.Marshal(effectiveObjs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal effective objects to JSON array: %w", err)
 	}
 
 	fmt.Println("=== GENERATE EFFECTIVE CONFIG COMPLETE ===")
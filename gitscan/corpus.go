@@ -0,0 +1,210 @@
+package gitscan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	ctest "k8s.io/kubernetes/test/ctest"
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// Corpus is a manifest of the repositories fixtures are generated from:
+//
+//	sources:
+//	- label: bank-of-anthos
+//	  path: ../repos/bank-of-anthos
+//	  rev: v0.6.7
+//	  include: ["kubernetes-manifests/**"]
+//	- label: argo-rollouts
+//	  path: /src/argo-rollouts
+//	  exclude: ["test/**", "**/testdata/**"]
+type Corpus struct {
+	Sources []CorpusSource `json:"sources"`
+}
+
+// CorpusSource is one repository of a corpus.
+type CorpusSource struct {
+	// Label names the source in the provenance file; it is unique in the corpus.
+	Label string `json:"label"`
+	// Path is the local repository, relative to the manifest.
+	Path string `json:"path"`
+	// Rev scans the committed files of the git repository at this commit, tag or
+	// branch instead of the working tree, see GenerateFixturesFromGit.
+	Rev string `json:"rev,omitempty"`
+	// Include and Exclude are globs matched against the slash-separated path of
+	// a YAML file relative to Path, see walker.Select.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// LoadCorpus reads the corpus manifest at path.
+func LoadCorpus(path string) (*Corpus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Corpus
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse corpus %s: %w", path, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for i := range c.Sources {
+		if s := &c.Sources[i]; s.Path != "" && !filepath.IsAbs(s.Path) {
+			s.Path = filepath.Join(dir, s.Path)
+		}
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid corpus %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Corpus) validate() error {
+	if len(c.Sources) == 0 {
+		return fmt.Errorf("no sources")
+	}
+	labels := make(map[string]bool)
+	for i, s := range c.Sources {
+		switch {
+		case s.Label == "":
+			return fmt.Errorf("source %d has no label", i+1)
+		case labels[s.Label]:
+			return fmt.Errorf("label %q is used twice", s.Label)
+		case s.Path == "":
+			return fmt.Errorf("source %q has no path", s.Label)
+		}
+		labels[s.Label] = true
+		if err := walker.CheckGlobs(append(append([]string{}, s.Include...), s.Exclude...)); err != nil {
+			return fmt.Errorf("source %q: %w", s.Label, err)
+		}
+	}
+	return nil
+}
+
+// CorpusOptions configures GenerateCorpusFixtures.
+type CorpusOptions struct {
+	// Only regenerates the sources with these labels; the others keep the
+	// objects recorded in the provenance file unless their revision changed.
+	// Empty regenerates all sources.
+	Only []string
+	// Incremental only parses the files of git sources changed since the commit
+	// recorded for them.
+	Incremental bool
+}
+
+// SourceSummary is what GenerateCorpusFixtures did with one source. Kept is set
+// when the recorded objects of the source were kept instead of scanning it.
+type SourceSummary struct {
+	Label   string
+	Kept    bool
+	Commit  string
+	Files   int
+	Parsed  int
+	Reused  int
+	Objects int
+}
+
+// GenerateCorpusFixtures replaces the fixture file with the Kubernetes objects
+// of all sources of c, and records each source under its label in the
+// provenance file. The labels live only there: the fixtures themselves do not
+// say which source they came from. A source that is not regenerated keeps the
+// objects recorded for it, as long as its path and revision did not change;
+// changed globs need the source to be regenerated.
+func GenerateCorpusFixtures(c *Corpus, opts CorpusOptions) ([]SourceSummary, error) {
+	only := make(map[string]bool)
+	for _, label := range opts.Only {
+		if c.source(label) == nil {
+			return nil, fmt.Errorf("no source %q in the corpus", label)
+		}
+		only[label] = true
+	}
+	recorded, err := ctest.LoadProvenance(ctest.ProvenanceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &ctest.Provenance{}
+	var allObjects []ctest.K8sObject
+	var summaries []SourceSummary
+	for _, cs := range c.Sources {
+		prev := recorded.Labeled(cs.Label)
+		if prev != nil && prev.Repo != cs.Path {
+			prev = nil
+		}
+		// prev is reused for the unchanged blobs of another revision, but its
+		// objects are only kept for the same one
+		if len(only) > 0 && !only[cs.Label] && prev != nil && prev.Rev == cs.Rev {
+			objs := prev.Decode()
+			merged.Sources = append(merged.Sources, prev)
+			allObjects = append(allObjects, objs...)
+			summaries = append(summaries, SourceSummary{Label: cs.Label, Kept: true, Commit: prev.Commit, Files: len(prev.Files), Objects: len(objs)})
+			fmt.Printf("📦 Kept %d objects of %s\n", len(objs), cs.Label)
+			continue
+		}
+
+		source, objs, summary, err := scanSource(cs, prev, opts.Incremental)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", cs.Label, err)
+		}
+		source.Label = cs.Label
+		merged.Sources = append(merged.Sources, source)
+		allObjects = append(allObjects, objs...)
+		summaries = append(summaries, summary)
+	}
+	if err := ctest.WriteFixtures(merged, allObjects); err != nil {
+		return summaries, err
+	}
+	return summaries, nil
+}
+
+// scanSource scans one source of a corpus. An incremental scan of a git source
+// starts from prev.
+func scanSource(cs CorpusSource, prev *ctest.Source, incremental bool) (*ctest.Source, []ctest.K8sObject, SourceSummary, error) {
+	keep := func(rel string) bool { return walker.Select(rel, cs.Include, cs.Exclude) }
+	summary := SourceSummary{Label: cs.Label}
+	if cs.Rev == "" {
+		source, objs, err := ctest.ScanDir(cs.Path, keep)
+		if err != nil {
+			return nil, nil, summary, err
+		}
+		summary.Files, summary.Parsed, summary.Objects = len(source.Files), len(source.Files), len(objs)
+		fmt.Printf("📁 Scanned %s (%s): %d YAML files, %d objects\n", cs.Path, cs.Label, summary.Files, summary.Objects)
+		return source, objs, summary, nil
+	}
+	if !incremental {
+		prev = nil
+	}
+	source, objs, s, err := scan(cs.Path, cs.Rev, prev, keep)
+	if err != nil {
+		return nil, nil, summary, err
+	}
+	summary.Commit, summary.Files, summary.Parsed, summary.Reused, summary.Objects = s.Commit, s.Files, s.Parsed, s.Reused, s.Objects
+	return source, objs, summary, nil
+}
+
+func (c *Corpus) source(label string) *CorpusSource {
+	for i := range c.Sources {
+		if c.Sources[i].Label == label {
+			return &c.Sources[i]
+		}
+	}
+	return nil
+}
+
+// SplitLabels splits a comma-separated list of source labels.
+func SplitLabels(s string) []string {
+	var labels []string
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
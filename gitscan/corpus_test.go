@@ -0,0 +1,113 @@
+package gitscan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	ctest "k8s.io/kubernetes/test/ctest"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateCorpusFixtures(t *testing.T) {
+	// The fixture store writes ./fixtures/, keep it away from the real one
+	work := t.TempDir()
+	t.Chdir(work)
+	if err := os.Mkdir("fixtures", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, filepath.Join(work, "repos"), map[string]string{
+		"shop/k8s/web.yaml":      deploymentYAML,
+		"shop/k8s/dev/svc.yaml":  serviceYAML,
+		"shop/docs/example.yaml": configMapYAML,
+		"bank/manifests/cm.yaml": configMapYAML,
+		"corpus/corpus.yaml": `sources:
+- label: shop
+  path: ../shop
+  include: ["k8s/**"]
+  exclude: ["**/dev/**"]
+- label: bank
+  path: ../bank
+- label: votes
+  path: ../votes
+  rev: HEAD
+`,
+	})
+	votes := filepath.Join(work, "repos", "votes")
+	r, err := git.PlainInit(votes, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, r, votes, map[string]string{"vote.yaml": serviceYAML})
+
+	corpus, err := LoadCorpus(filepath.Join(work, "repos", "corpus", "corpus.yaml"))
+	if err != nil {
+		t.Fatalf("LoadCorpus failed: %v", err)
+	}
+	summaries, err := GenerateCorpusFixtures(corpus, CorpusOptions{})
+	if err != nil {
+		t.Fatalf("GenerateCorpusFixtures failed: %v", err)
+	}
+	for i, want := range []int{1, 1, 1} {
+		if summaries[i].Objects != want || summaries[i].Kept {
+			t.Errorf("summary %d = %+v, want %d objects", i, summaries[i], want)
+		}
+	}
+	provenance, err := ctest.LoadProvenance(ctest.ProvenanceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := provenance.Labeled("votes"); s == nil || s.Commit == "" || s.Objects() != 1 {
+		t.Errorf("votes provenance = %+v", s)
+	}
+
+	// Regenerating shop keeps what was recorded for bank, even without its files
+	writeFiles(t, filepath.Join(work, "repos"), map[string]string{"shop/k8s/api.yaml": deploymentYAML})
+	if err := os.RemoveAll(filepath.Join(work, "repos", "bank")); err != nil {
+		t.Fatal(err)
+	}
+	summaries, err = GenerateCorpusFixtures(corpus, CorpusOptions{Only: []string{"shop"}})
+	if err != nil {
+		t.Fatalf("GenerateCorpusFixtures of shop failed: %v", err)
+	}
+	if summaries[0].Objects != 2 || summaries[0].Kept || !summaries[1].Kept || summaries[1].Objects != 1 || !summaries[2].Kept {
+		t.Errorf("unexpected summaries %+v", summaries)
+	}
+	if provenance, _ = ctest.LoadProvenance(ctest.ProvenanceFile); len(provenance.Sources) != 3 {
+		t.Errorf("provenance has %d sources, want 3", len(provenance.Sources))
+	}
+
+	// A new revision of votes is scanned even when it is not selected, reusing
+	// the objects of its unchanged files
+	next := commitFiles(t, r, votes, map[string]string{"poll.yaml": configMapYAML})
+	corpus.Sources[2].Rev = next.String()
+	summaries, err = GenerateCorpusFixtures(corpus, CorpusOptions{Only: []string{"shop"}, Incremental: true})
+	if err != nil {
+		t.Fatalf("GenerateCorpusFixtures of a new votes revision failed: %v", err)
+	}
+	if s := summaries[2]; s.Kept || s.Commit != next.String() || s.Objects != 2 || s.Reused != 1 || s.Parsed != 1 {
+		t.Errorf("unexpected votes summary %+v", s)
+	}
+
+	if _, err := GenerateCorpusFixtures(corpus, CorpusOptions{Only: []string{"nope"}}); err == nil {
+		t.Errorf("expected an error for an unknown label")
+	}
+	corpus.Sources = append(corpus.Sources, CorpusSource{Label: "bank", Path: "x"})
+	if err := corpus.validate(); err == nil {
+		t.Errorf("expected an error for a duplicate label")
+	}
+}
@@ -0,0 +1,68 @@
+package gitscan
+
+import (
+	"flag"
+	"testing"
+)
+
+var (
+	repoDir     string
+	repoRev     string
+	incremental bool
+	corpusPath  string
+	corpusOnly  string
+)
+
+func init() {
+	flag.StringVar(&repoDir, "repo", "", "path to the git repository")
+	flag.StringVar(&repoRev, "rev", "", "commit, tag or branch to scan (default: HEAD)")
+	flag.BoolVar(&incremental, "incremental", false, "only parse the files changed since the recorded commit")
+	flag.StringVar(&corpusPath, "corpus", "", "corpus manifest listing the repositories to scan, instead of -repo")
+	flag.StringVar(&corpusOnly, "only", "", "comma-separated labels of the corpus sources to regenerate, keeping the others")
+}
+
+// TestGenerateFixtures is the driver of make gen-fixtures for git scans and of
+// make gen-corpus-fixtures; the working tree of a repository is scanned by the
+// TestGenerateFixtures of the ctest package.
+func TestGenerateFixtures(t *testing.T) {
+	flag.Parse()
+
+	// The fixture store writes ./fixtures/, relative to test/ctest
+	t.Chdir("..")
+
+	if corpusPath != "" {
+		generateCorpusFixtures(t)
+		return
+	}
+	if repoDir == "" {
+		t.Fatal("missing -repo or -corpus flag")
+	}
+	summary, err := GenerateFixturesFromGit(repoDir, Options{Rev: repoRev, Incremental: incremental})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Commit %s (since %s): %d files, %d parsed, %d reused, %d objects",
+		summary.Commit, summary.Since, summary.Files, summary.Parsed, summary.Reused, summary.Objects)
+}
+
+func generateCorpusFixtures(t *testing.T) {
+	corpus, err := LoadCorpus(corpusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries, err := GenerateCorpusFixtures(corpus, CorpusOptions{Only: SplitLabels(corpusOnly), Incremental: incremental})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("===================================")
+	t.Logf("Corpus Summary (%s)", corpusPath)
+	for _, s := range summaries {
+		state := "scanned"
+		if s.Kept {
+			state = "kept"
+		}
+		t.Logf("%-24s %-8s %4d objects from %4d files %s", s.Label, state, s.Objects, s.Files, shortSHA(s.Commit))
+	}
+	t.Log("===================================")
+}
@@ -0,0 +1,150 @@
+// Package gitscan generates fixtures from the committed files of git
+// repositories, and from the repositories of a corpus manifest. It is kept out
+// of the ctest package, which every rewritten test imports, because it needs
+// github.com/go-git/go-git/v5; Kubernetes does not vendor it, add it to the
+// Kubernetes go.mod before building cmd/ctest or running the fixture
+// generation:
+//
+//	go get github.com/go-git/go-git/v5
+package gitscan
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	ctest "k8s.io/kubernetes/test/ctest"
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// Options configures GenerateFixturesFromGit.
+type Options struct {
+	// Rev is the commit, tag or branch to scan, as git rev-parse takes it; empty
+	// means HEAD.
+	Rev string
+	// Incremental only parses the files changed since the commit recorded in the
+	// provenance file, and reuses the recorded objects of the others.
+	Incremental bool
+}
+
+// Summary counts the files of a git scan. Since is the commit of the
+// previous scan an incremental scan started from, empty for a full scan.
+type Summary struct {
+	Commit  string
+	Since   string
+	Files   int
+	Parsed  int
+	Reused  int
+	Objects int
+}
+
+// GenerateFixturesFromGit replaces the fixture file with the Kubernetes objects
+// of the YAML files of the git repository repo at opts.Rev. The repository can
+// be a clone or a bare repository; only committed files are read, and the
+// .gitignore files of the commit apply like .ctestignore files. The commit is
+// recorded in the provenance file, see ctest.ProvenanceFile.
+func GenerateFixturesFromGit(repo string, opts Options) (*Summary, error) {
+	repo, err := filepath.Abs(repo)
+	if err != nil {
+		return nil, err
+	}
+	var prev *ctest.Source
+	if opts.Incremental {
+		p, err := ctest.LoadProvenance(ctest.ProvenanceFile)
+		if err != nil {
+			return nil, err
+		}
+		prev = p.Source(repo)
+	}
+	source, objects, summary, err := scan(repo, opts.Rev, prev, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctest.WriteFixtures(&ctest.Provenance{Sources: []*ctest.Source{source}}, objects); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// scan parses the YAML files of repo at rev for which keep, when set, returns
+// true for their slash-separated path. The objects of the files whose blob is
+// the same as in prev are decoded from prev instead.
+func scan(repo, rev string, prev *ctest.Source, keep func(rel string) bool) (*ctest.Source, []ctest.K8sObject, *Summary, error) {
+	r, err := git.PlainOpen(repo)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open git repository %s: %w", repo, err)
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to resolve %s in %s: %w", rev, repo, err)
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read the tree of %s: %w", hash, err)
+	}
+
+	source := &ctest.Source{Repo: repo, Rev: rev, Commit: hash.String(), ScannedAt: time.Now()}
+	summary := &Summary{Commit: source.Commit}
+	known := make(map[string]ctest.SourceFile)
+	if prev != nil && prev.Commit != "" {
+		summary.Since = prev.Commit
+		for _, f := range prev.Files {
+			known[f.Path] = f
+		}
+	}
+
+	w := &walker.Walker{Rules: walker.Fixtures, FS: treeFS{tree}, GitIgnore: true, SkipErrors: true}
+	files, err := w.Files(".", ctest.YAMLSuffixes...)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to collect YAML files: %w", err)
+	}
+	var allObjects []ctest.K8sObject
+	for _, p := range files {
+		if keep != nil && !keep(p) {
+			continue
+		}
+		entry, err := tree.FindEntry(p)
+		if err != nil {
+			continue
+		}
+		var objs []ctest.K8sObject
+		if f, ok := known[p]; ok && f.Blob == entry.Hash.String() {
+			objs = source.DecodeFile(f)
+			summary.Reused++
+		} else {
+			file, err := tree.TreeEntryFile(entry)
+			if err != nil {
+				continue
+			}
+			content, err := file.Contents()
+			if err != nil {
+				continue
+			}
+			objs = ctest.ParseYAML(filepath.Join(repo, filepath.FromSlash(p)), []byte(content))
+			summary.Parsed++
+		}
+		source.AddFile(p, entry.Hash.String(), objs)
+		allObjects = append(allObjects, objs...)
+	}
+	summary.Files = len(source.Files)
+	summary.Objects = len(allObjects)
+	fmt.Printf("📁 Scanned %s at %s: %d YAML files, %d parsed, %d reused\n", repo, shortSHA(source.Commit), summary.Files, summary.Parsed, summary.Reused)
+	return source, allObjects, summary, nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
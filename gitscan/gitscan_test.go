@@ -0,0 +1,101 @@
+package gitscan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	deploymentYAML = "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"
+	serviceYAML    = "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"
+	configMapYAML  = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n"
+)
+
+// commitFiles writes files into the work tree of r, commits all of them and
+// returns the commit.
+func commitFiles(t *testing.T, r *git.Repository, dir string, files map[string]string) plumbing.Hash {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "ctest", Email: "ctest@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestScanGit(t *testing.T) {
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, r, dir, map[string]string{
+		"deploy/web.yaml":           deploymentYAML,
+		"deploy/svc.yml":            serviceYAML,
+		"chart/templates/cm.yaml":   configMapYAML,
+		".github/workflows/ci.yaml": configMapYAML,
+		"generated/cm.yaml":         configMapYAML,
+	})
+	// generated/ is committed but ignored
+	commitFiles(t, r, dir, map[string]string{".gitignore": "generated/\n"})
+	if _, err := r.CreateTag("v1", first, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, objects, summary, err := scan(dir, "", nil, nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if source.Commit != head.Hash().String() || summary.Files != 2 || summary.Parsed != 2 || len(objects) != 2 {
+		t.Fatalf("unexpected scan of HEAD: %+v", summary)
+	}
+
+	// The tag is before .gitignore
+	if _, objects, _, err = scan(dir, "v1", nil, nil); err != nil || len(objects) != 3 {
+		t.Fatalf("scan of v1 = %d objects, %v, want 3", len(objects), err)
+	}
+
+	// Changed files are parsed again, the others come from the last scan, and an
+	// uncommitted file is not seen
+	second := commitFiles(t, r, dir, map[string]string{"deploy/svc.yml": serviceYAML + "---\n" + configMapYAML})
+	if err := os.WriteFile(filepath.Join(dir, "deploy", "new.yaml"), []byte(configMapYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, objects, summary, err = scan(dir, "", source, nil)
+	if err != nil {
+		t.Fatalf("incremental scan failed: %v", err)
+	}
+	if summary.Since != head.Hash().String() || summary.Commit != second.String() || summary.Parsed != 1 || summary.Reused != 1 || len(objects) != 3 {
+		t.Fatalf("unexpected incremental scan: %+v", summary)
+	}
+	if source.Objects() != 3 || source.Files[0].Blob == "" {
+		t.Errorf("unexpected source %+v", source)
+	}
+}
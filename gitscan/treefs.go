@@ -0,0 +1,149 @@
+package gitscan
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// treeFS is the file tree of a commit as an fs.FS, to walk it like a directory.
+// Symbolic links and submodules are left out.
+type treeFS struct {
+	tree *object.Tree
+}
+
+func (t treeFS) Open(name string) (fs.File, error) {
+	info, err := t.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &treeFile{info: info}, nil
+	}
+	file, err := t.tree.File(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treeFile{info: info, reader: reader}, nil
+}
+
+func (t treeFS) Stat(name string) (fs.FileInfo, error) {
+	return t.stat("stat", name)
+}
+
+func (t treeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir := t.tree
+	if name != "." {
+		if _, err := t.stat("readdir", name); err != nil {
+			return nil, err
+		}
+		sub, err := t.tree.Tree(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+		}
+		dir = sub
+	}
+	var entries []fs.DirEntry
+	for i := range dir.Entries {
+		if e := &dir.Entries[i]; supported(e.Mode) {
+			entries = append(entries, treeDirEntry{dir: treeFS{dir}, entry: e})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (t treeFS) stat(op, name string) (*treeInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &treeInfo{name: ".", mode: filemode.Dir}, nil
+	}
+	entry, err := t.tree.FindEntry(name)
+	if err != nil || !supported(entry.Mode) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return t.info(path.Base(name), entry)
+}
+
+func (t treeFS) info(name string, entry *object.TreeEntry) (*treeInfo, error) {
+	info := &treeInfo{name: name, mode: entry.Mode}
+	if entry.Mode == filemode.Dir {
+		return info, nil
+	}
+	file, err := t.tree.TreeEntryFile(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info.size = file.Size
+	return info, nil
+}
+
+func supported(mode filemode.FileMode) bool {
+	return mode == filemode.Dir || mode.IsRegular() || mode == filemode.Executable
+}
+
+type treeDirEntry struct {
+	dir   treeFS
+	entry *object.TreeEntry
+}
+
+func (e treeDirEntry) Name() string               { return e.entry.Name }
+func (e treeDirEntry) IsDir() bool                { return e.entry.Mode == filemode.Dir }
+func (e treeDirEntry) Type() fs.FileMode          { return e.mode().Type() }
+func (e treeDirEntry) Info() (fs.FileInfo, error) { return e.dir.info(e.entry.Name, e.entry) }
+
+func (e treeDirEntry) mode() fs.FileMode {
+	if e.IsDir() {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+type treeInfo struct {
+	name string
+	mode filemode.FileMode
+	size int64
+}
+
+func (i *treeInfo) Name() string       { return i.name }
+func (i *treeInfo) Size() int64        { return i.size }
+func (i *treeInfo) ModTime() time.Time { return time.Time{} }
+func (i *treeInfo) IsDir() bool        { return i.mode == filemode.Dir }
+func (i *treeInfo) Sys() interface{}   { return nil }
+
+func (i *treeInfo) Mode() fs.FileMode {
+	mode, _ := i.mode.ToOSFileMode()
+	return mode
+}
+
+type treeFile struct {
+	info   *treeInfo
+	reader io.ReadCloser
+}
+
+func (f *treeFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *treeFile) Read(p []byte) (int, error) {
+	if f.reader == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+	return f.reader.Read(p)
+}
+
+func (f *treeFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+	return f.reader.Close()
+}
@@ -0,0 +1,253 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+	fixtures "k8s.io/kubernetes/test/ctest/fixtures"
+)
+
+// Option configures Generate.
+type Option func(*generateOptions)
+
+type generateOptions struct {
+	mode            Mode
+	fixtureFile     string
+	fixtureData     map[string]stdjson.RawMessage
+	maxConfigs      int
+	seed            int64
+	seeded          bool
+	protectedPaths  []string
+	validate        func(obj interface{}) error
+	includeOriginal bool
+	reconcile       ReconcilePolicy
+	knownRefs       knownReferences
+	reconcileReport *[]ReconcileReport
+
+	fixtureDependencies bool
+}
+
+func newGenerateOptions(opts ...Option) *generateOptions {
+	o := &generateOptions{
+		mode:        ExtendOnly,
+		fixtureFile: ctestglobals.TestExternalFixtureFile,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMode selects how the hardcoded config and fixture values are combined.
+func WithMode(mode Mode) Option {
+	return func(o *generateOptions) {
+		o.mode = mode
+	}
+}
+
+// WithFixtureFile loads fixtures from another file embedded in the fixtures
+// package instead of ctestglobals.TestExternalFixtureFile.
+func WithFixtureFile(fileName string) Option {
+	return func(o *generateOptions) {
+		o.fixtureFile = fileName
+	}
+}
+
+// WithFixtureData uses already loaded fixtures (top-level key -> JSON array) instead
+// of reading an embedded fixture file.
+func WithFixtureData(data map[string]stdjson.RawMessage) Option {
+	return func(o *generateOptions) {
+		o.fixtureData = data
+	}
+}
+
+// WithMaxConfigs limits the number of generated configs. Zero or a negative value
+// means no limit.
+func WithMaxConfigs(n int) Option {
+	return func(o *generateOptions) {
+		o.maxConfigs = n
+	}
+}
+
+// WithSeed makes WithMaxConfigs pick a random, reproducible subset of the generated
+// configs instead of the first ones.
+func WithSeed(seed int64) Option {
+	return func(o *generateOptions) {
+		o.seed = seed
+		o.seeded = true
+	}
+}
+
+// WithProtectedPaths keeps the given fields at their hardcoded values in every
+// generated config. Paths are dot-separated JSON field names, as accepted by
+// utils.GetFieldValuesFromFixtures; arrays are stepped into element by element,
+// so "containers.name" protects the name of every container. A numeric segment
+// selects a single element, e.g. "containers.0.name".
+func WithProtectedPaths(paths ...string) Option {
+	return func(o *generateOptions) {
+		o.protectedPaths = append(o.protectedPaths, paths...)
+	}
+}
+
+// WithValidation drops generated configs for which fn returns an error.
+func WithValidation[T any](fn func(T) error) Option {
+	return func(o *generateOptions) {
+		o.validate = func(obj interface{}) error {
+			typed, ok := obj.(T)
+			if !ok {
+				return fmt.Errorf("validation expects %T, got %T", *new(T), obj)
+			}
+			return fn(typed)
+		}
+	}
+}
+
+// WithIncludeOriginal prepends the unmodified hardcoded config to the results as
+// case 0 (the baseline). Generate then never returns an empty result.
+func WithIncludeOriginal(include bool) Option {
+	return func(o *generateOptions) {
+		o.includeOriginal = include
+	}
+}
+
+// WithReconcile sets how configs with dangling references (volume mounts without a
+// volume, envFrom of a ConfigMap that does not exist in the test, ...) are handled
+// after merging. The default is ReconcileRepair.
+func WithReconcile(policy ReconcilePolicy) Option {
+	return func(o *generateOptions) {
+		o.reconcile = policy
+	}
+}
+
+// WithKnownReferences marks objects as available to the test, so references to
+// them are not treated as dangling. kind is one of the Ref* kinds; plural and
+// lower-case forms such as "configmaps" are accepted.
+func WithKnownReferences(kind string, names ...string) Option {
+	return func(o *generateOptions) {
+		if o.knownRefs == nil {
+			o.knownRefs = knownReferences{}
+		}
+		for _, name := range names {
+			o.knownRefs.add(parseReferenceKind(kind), name)
+		}
+	}
+}
+
+// WithReconcileReport stores what the reconciler did into dst.
+func WithReconcileReport(dst *[]ReconcileReport) Option {
+	return func(o *generateOptions) {
+		o.reconcileReport = dst
+	}
+}
+
+func (o *generateOptions) loadFixtures(objects []string) (map[string]stdjson.RawMessage, error) {
+	objects, err := ctestglobals.ResolveFixtureKeys(objects)
+	if err != nil {
+		return nil, err
+	}
+	if o.fixtureData == nil {
+		fmt.Printf(ctestglobals.DebugPrefix()+" [DEBUG] Loading fixtures for types: %v (count: %d)\n", objects, len(objects))
+		return fixtures.LoadFixturesAsJSON(o.fixtureFile, objects...)
+	}
+
+	if len(objects) == 0 {
+		return o.fixtureData, nil
+	}
+	selected := make(map[string]stdjson.RawMessage, len(objects))
+	for _, obj := range objects {
+		if v, ok := o.fixtureData[obj]; ok {
+			selected[obj] = v
+		}
+	}
+	return selected, nil
+}
+
+// sampleConfigs applies WithMaxConfigs/WithSeed to the generated configs.
+func sampleConfigs[T any](objs []T, o *generateOptions) []T {
+	if o.maxConfigs <= 0 || len(objs) <= o.maxConfigs {
+		return objs
+	}
+	if o.seeded {
+		r := rand.New(rand.NewSource(o.seed))
+		r.Shuffle(len(objs), func(i, j int) {
+			objs[i], objs[j] = objs[j], objs[i]
+		})
+	}
+	fmt.Printf(ctestglobals.DebugPrefix()+" Keeping %d of %d generated configs\n", o.maxConfigs, len(objs))
+	return objs[:o.maxConfigs]
+}
+
+// restoreProtectedPaths copies the values at the protected paths from baseJSON into
+// every result. A protected field missing from the base is removed from the result.
+func restoreProtectedPaths(baseJSON []byte, results [][]byte, paths []string) ([][]byte, error) {
+	var baseData interface{}
+	if err := stdjson.Unmarshal(baseJSON, &baseData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal base JSON: %w", err)
+	}
+
+	restored := make([][]byte, 0, len(results))
+	for i, resultJSON := range results {
+		var resultData interface{}
+		if err := stdjson.Unmarshal(resultJSON, &resultData); err != nil {
+			return nil, fmt.Errorf("result %d: %w", i, err)
+		}
+		for _, p := range paths {
+			resultData = restorePath(baseData, resultData, strings.Split(p, "."))
+		}
+		out, err := stdjson.MarshalIndent(resultData, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		restored = append(restored, out)
+	}
+	return restored, nil
+}
+
+func restorePath(base, result interface{}, parts []string) interface{} {
+	if len(parts) == 0 {
+		return base
+	}
+
+	switch res := result.(type) {
+	case map[string]interface{}:
+		baseMap, _ := base.(map[string]interface{})
+		key := parts[0]
+		baseValue, inBase := baseMap[key]
+		if len(parts) == 1 {
+			if inBase {
+				res[key] = baseValue
+			} else {
+				delete(res, key)
+			}
+			return res
+		}
+		if resValue, ok := res[key]; ok {
+			res[key] = restorePath(baseValue, resValue, parts[1:])
+		} else if inBase {
+			// The whole subtree is gone from the result, bring back the hardcoded one
+			res[key] = baseValue
+		}
+		return res
+
+	case []interface{}:
+		baseArr, _ := base.([]interface{})
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx < len(res) && idx < len(baseArr) {
+				res[idx] = restorePath(baseArr[idx], res[idx], parts[1:])
+			}
+			return res
+		}
+		for i := range res {
+			if i < len(baseArr) {
+				res[i] = restorePath(baseArr[i], res[i], parts)
+			}
+		}
+		return res
+	}
+
+	return result
+}
@@ -0,0 +1,97 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+func testContainerItem() ctestglobals.HardcodedConfigItem {
+	return ctestglobals.HardcodedConfigItem{
+		FixtureFileName: "test_fixture.json",
+		TestInfo:        []string{"options test"},
+		Field:           "containers",
+		K8sObjects:      []string{"pods"},
+		HardcodedConfig: []v1.Container{{Name: "test-container", Image: "busybox"}},
+	}
+}
+
+func testContainerFixtures(t *testing.T) map[string]stdjson.RawMessage {
+	pods := []v1.Pod{
+		{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "a", Image: "nginx", ImagePullPolicy: v1.PullAlways}}}},
+		{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "b", Image: "redis", WorkingDir: "/data"}}}},
+		{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "c", Image: "envoy", Args: []string{"-c"}}}}},
+	}
+	raw, err := stdjson.Marshal(pods)
+	if err != nil {
+		t.Fatalf("failed to marshal fixture pods: %v", err)
+	}
+	return map[string]stdjson.RawMessage{"pods": raw}
+}
+
+func TestGenerateWithOptions(t *testing.T) {
+	fixtureData := testContainerFixtures(t)
+
+	configs, configJSON, err := Generate[[]v1.Container](testContainerItem(),
+		WithMode(Union),
+		WithFixtureData(fixtureData),
+		WithProtectedPaths("name"),
+	)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(configs) != 3 {
+		t.Fatalf("expected 3 configs, got %d: %s", len(configs), configJSON)
+	}
+	for i, c := range configs {
+		if c[0].Name != "test-container" {
+			t.Errorf("config %d: protected name changed to %q", i, c[0].Name)
+		}
+	}
+
+	limited, _, err := Generate[[]v1.Container](testContainerItem(),
+		WithMode(Union),
+		WithFixtureData(fixtureData),
+		WithMaxConfigs(2),
+		WithSeed(7),
+	)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("expected 2 configs with WithMaxConfigs(2), got %d", len(limited))
+	}
+
+	validated, _, err := Generate[[]v1.Container](testContainerItem(),
+		WithMode(Union),
+		WithFixtureData(fixtureData),
+		WithValidation(func(c []v1.Container) error {
+			if c[0].ImagePullPolicy == v1.PullAlways {
+				return errors.New("pull policy Always is not allowed")
+			}
+			return nil
+		}),
+		WithIncludeOriginal(true),
+	)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(validated) != 3 {
+		t.Fatalf("expected original + 2 validated configs, got %d", len(validated))
+	}
+	if validated[0][0].Name != "test-container" || validated[0][0].Image != "busybox" {
+		t.Errorf("first config is not the original: %+v", validated[0])
+	}
+}
+
+func TestGenerateEffectiveConfigReturnTypeRejectsInvalidEntry(t *testing.T) {
+	if _, _, err := GenerateEffectiveConfigReturnType[v1.PodSpec](nil, ExtendOnly); err == nil {
+		t.Error("expected error for nil entry")
+	}
+	if _, _, err := GenerateEffectiveConfigReturnType[v1.PodSpec](struct{ Field string }{}, ExtendOnly); err == nil {
+		t.Error("expected error for entry without HardcodedConfig")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -98,6 +99,9 @@ func ProcessObjects(objects []K8sObject) {
 		case *storagev1.StorageClass:
 			fixtures.AddStorageClass(obj)
 
+		case *schedulingv1.PriorityClass:
+			fixtures.AddPriorityClass(obj)
+
 		case *apiextv1.CustomResourceDefinition:
 			fixtures.AddCustomResourceDefinition(obj)
 
@@ -0,0 +1,164 @@
+package ctest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+	"k8s.io/kubernetes/test/ctest/fixtures"
+)
+
+// ProvenanceFile records where the objects of the fixture file come from. It is
+// next to the fixture file, relative to test/ctest like it.
+var ProvenanceFile = "./fixtures/" + strings.TrimSuffix(ctestglobals.TestExternalFixtureFile, ".json") + ".provenance.json"
+
+// Provenance lists the repositories the fixture file was generated from, with
+// the objects each of their YAML files gave.
+type Provenance struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Sources     []*Source `json:"sources"`
+}
+
+// Source is one scanned repository.
+type Source struct {
+	// Label names the source of a corpus, see gitscan.Corpus.
+	Label string `json:"label,omitempty"`
+	// Repo is the absolute path of the repository.
+	Repo string `json:"repo"`
+	// Rev is the revision that was asked for and Commit the SHA it resolved to;
+	// both are empty when the working tree was scanned.
+	Rev       string    `json:"rev,omitempty"`
+	Commit    string    `json:"commit,omitempty"`
+	ScannedAt time.Time `json:"scannedAt"`
+	// Files are all scanned YAML files, with or without objects.
+	Files []SourceFile `json:"files"`
+}
+
+// SourceFile is one scanned YAML file.
+type SourceFile struct {
+	// Path is slash-separated, relative to the repository.
+	Path string `json:"path"`
+	// Blob is the git blob hash of a file scanned at a commit; an incremental
+	// scan reuses the objects of a file whose blob did not change.
+	Blob    string         `json:"blob,omitempty"`
+	Objects []SourceObject `json:"objects,omitempty"`
+}
+
+// SourceObject is an object of the included kinds, with its YAML document.
+type SourceObject struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	YAML string `json:"yaml"`
+}
+
+// LoadProvenance reads the provenance file at path. A missing file has no
+// sources.
+func LoadProvenance(path string) (*Provenance, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Provenance{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Save writes the provenance file to path.
+func (p *Provenance) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write provenance: %w", err)
+	}
+	return nil
+}
+
+// Source returns the source of the repository repo, nil if it was not scanned.
+func (p *Provenance) Source(repo string) *Source {
+	if abs, err := filepath.Abs(repo); err == nil {
+		repo = abs
+	}
+	for _, s := range p.Sources {
+		if s.Repo == repo {
+			return s
+		}
+	}
+	return nil
+}
+
+// Labeled returns the source with the label, nil if there is none.
+func (p *Provenance) Labeled(label string) *Source {
+	for _, s := range p.Sources {
+		if s.Label == label {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddFile records the objects parsed from the file rel of the source.
+func (s *Source) AddFile(rel, blob string, objects []K8sObject) {
+	f := SourceFile{Path: rel, Blob: blob}
+	for _, o := range objects {
+		f.Objects = append(f.Objects, SourceObject{Kind: o.Kind, Name: o.Name, YAML: o.RawYAML})
+	}
+	s.Files = append(s.Files, f)
+}
+
+// DecodeFile decodes the recorded objects of f again.
+func (s *Source) DecodeFile(f SourceFile) []K8sObject {
+	var objects []K8sObject
+	for _, o := range f.Objects {
+		objects = append(objects, ParseYAML(filepath.Join(s.Repo, filepath.FromSlash(f.Path)), []byte(o.YAML))...)
+	}
+	return objects
+}
+
+// Decode decodes all recorded objects of the source again.
+func (s *Source) Decode() []K8sObject {
+	var objects []K8sObject
+	for _, f := range s.Files {
+		objects = append(objects, s.DecodeFile(f)...)
+	}
+	return objects
+}
+
+// Objects returns the number of objects of the source.
+func (s *Source) Objects() int {
+	n := 0
+	for _, f := range s.Files {
+		n += len(f.Objects)
+	}
+	return n
+}
+
+// WriteFixtures replaces the fixture file with objects and the provenance file
+// with p.
+func WriteFixtures(p *Provenance, objects []K8sObject) error {
+	if len(objects) == 0 {
+		var repos []string
+		for _, s := range p.Sources {
+			repos = append(repos, s.Repo)
+		}
+		return fmt.Errorf("no valid kubernetes objects found in %s", strings.Join(repos, ", "))
+	}
+	if err := fixtures.ClearFixtures(); err != nil {
+		return err
+	}
+	ProcessObjects(objects)
+	p.GeneratedAt = time.Now()
+	return p.Save(ProvenanceFile)
+}
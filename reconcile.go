@@ -0,0 +1,442 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// ReconcilePolicy decides what happens to an effective config whose merged fixture
+// values reference objects that do not exist in the test (dangling references).
+type ReconcilePolicy int
+
+const (
+	// ReconcileRepair fixes dangling references: volume mounts get a matching
+	// emptyDir volume, other references are stripped.
+	ReconcileRepair ReconcilePolicy = iota
+	// ReconcileDrop removes configs that contain any dangling reference.
+	ReconcileDrop
+	// ReconcileOff keeps configs as merged.
+	ReconcileOff
+)
+
+// Reference kinds checked by the reconciler.
+const (
+	RefVolume                = "Volume"
+	RefConfigMap             = "ConfigMap"
+	RefSecret                = "Secret"
+	RefPersistentVolumeClaim = "PersistentVolumeClaim"
+	RefServiceAccount        = "ServiceAccount"
+	RefPriorityClass         = "PriorityClass"
+)
+
+// builtinReferences are always available in a cluster and never dangling.
+var builtinReferences = map[string]map[string]bool{
+	RefServiceAccount: {"default": true},
+	RefPriorityClass:  {"system-node-critical": true, "system-cluster-critical": true},
+}
+
+// ReconcileAction records one dangling reference found in an effective config and
+// what was done about it.
+type ReconcileAction struct {
+	Path   string
+	Kind   string
+	Name   string
+	Action string
+}
+
+func (a ReconcileAction) String() string {
+	return fmt.Sprintf("%s %s %q at %s", a.Action, a.Kind, a.Name, a.Path)
+}
+
+// ReconcileReport lists the actions taken for one generated config. Index is the
+// position of the config in the merge results, before duplicates are filtered.
+type ReconcileReport struct {
+	Index   int
+	Actions []ReconcileAction
+	Dropped bool
+}
+
+// knownReferences is the set of objects a config may reference: everything the
+// hardcoded config already referenced, plus names registered by the caller.
+type knownReferences map[string]map[string]bool
+
+func (k knownReferences) add(kind, name string) {
+	if name == "" {
+		return
+	}
+	if k[kind] == nil {
+		k[kind] = make(map[string]bool)
+	}
+	k[kind][name] = true
+}
+
+func (k knownReferences) has(kind, name string) bool {
+	return k[kind][name] || builtinReferences[kind][name]
+}
+
+// reconcileResults checks every merged result against the references of the
+// hardcoded config and applies the policy. It returns the results to keep and a
+// report per result that had dangling references.
+func reconcileResults(baseJSON []byte, results [][]byte, policy ReconcilePolicy, extra knownReferences) ([][]byte, []ReconcileReport, error) {
+	if policy == ReconcileOff {
+		return results, nil, nil
+	}
+
+	var baseData interface{}
+	if err := stdjson.Unmarshal(baseJSON, &baseData); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal base JSON: %w", err)
+	}
+
+	known := knownReferences{}
+	collectReferences(baseData, known)
+	for kind, names := range extra {
+		for name := range names {
+			known.add(kind, name)
+		}
+	}
+
+	kept := make([][]byte, 0, len(results))
+	var reports []ReconcileReport
+
+	for i, resultJSON := range results {
+		var resultData interface{}
+		if err := stdjson.Unmarshal(resultJSON, &resultData); err != nil {
+			return nil, nil, fmt.Errorf("result %d: %w", i, err)
+		}
+
+		r := &reconciler{known: known, repair: policy == ReconcileRepair}
+		resultData = r.walk(resultData, "")
+		if len(r.actions) == 0 {
+			kept = append(kept, resultJSON)
+			continue
+		}
+
+		report := ReconcileReport{Index: i, Actions: r.actions}
+		if policy == ReconcileDrop {
+			report.Dropped = true
+			log.Printf("  [RECONCILE DROP] result %d: %d dangling reference(s)", i, len(r.actions))
+		} else {
+			out, err := stdjson.MarshalIndent(resultData, "", "  ")
+			if err != nil {
+				return nil, nil, err
+			}
+			kept = append(kept, out)
+		}
+		for _, a := range r.actions {
+			log.Printf("  [RECONCILE] result %d: %s", i, a)
+		}
+		reports = append(reports, report)
+	}
+
+	return kept, reports, nil
+}
+
+// printReconcileReports logs a summary of what the reconciler did.
+func printReconcileReports(reports []ReconcileReport) {
+	for _, report := range reports {
+		verb := "repaired"
+		if report.Dropped {
+			verb = "dropped"
+		}
+		fmt.Printf(ctestglobals.DebugPrefix()+" Reconciler %s result %d (%d dangling reference(s))\n", verb, report.Index+1, len(report.Actions))
+		for _, a := range report.Actions {
+			fmt.Println("   -", a)
+		}
+	}
+}
+
+// collectReferences records every reference made by data. Volume mounts only count
+// when the pod spec itself does not declare the volume, since those volumes are
+// provided by the test code around the hardcoded config.
+func collectReferences(data interface{}, known knownReferences) {
+	r := &reconciler{known: known, collect: true}
+	r.walk(data, "")
+}
+
+// reconciler walks a generic JSON tree looking for references. In collect mode it
+// records them into known; otherwise it reports (and with repair, fixes) the ones
+// missing from known. References listed in renames are rewritten to the new name
+// and always satisfied.
+type reconciler struct {
+	known   knownReferences
+	collect bool
+	repair  bool
+	renames map[string]map[string]string
+	actions []ReconcileAction
+}
+
+// check reports whether the reference is satisfied. In collect mode every
+// reference is recorded and satisfied.
+func (r *reconciler) check(kind, name, path, action string) bool {
+	if name == "" {
+		return true
+	}
+	if r.collect {
+		r.known.add(kind, name)
+		return true
+	}
+	if r.known.has(kind, name) {
+		return true
+	}
+	r.actions = append(r.actions, ReconcileAction{Path: path, Kind: kind, Name: name, Action: action})
+	return false
+}
+
+// checkField is check for a reference stored under key in m.
+func (r *reconciler) checkField(m map[string]interface{}, key, kind, path, action string) bool {
+	name, _ := m[key].(string)
+	if newName, ok := r.renames[kind][name]; ok {
+		m[key] = newName
+		return true
+	}
+	return r.check(kind, name, path, action)
+}
+
+func (r *reconciler) walk(node interface{}, path string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if _, ok := n["containers"].([]interface{}); ok {
+			r.reconcilePodSpec(n, path)
+		}
+		if env, ok := n["env"].([]interface{}); ok {
+			setOrDelete(n, "env", r.reconcileEnv(env, joinPath(path, "env")))
+		}
+		if envFrom, ok := n["envFrom"].([]interface{}); ok {
+			setOrDelete(n, "envFrom", r.reconcileEnvFrom(envFrom, joinPath(path, "envFrom")))
+		}
+		for key, value := range n {
+			n[key] = r.walk(value, joinPath(path, key))
+		}
+		return n
+	case []interface{}:
+		for i := range n {
+			n[i] = r.walk(n[i], fmt.Sprintf("%s[%d]", path, i))
+		}
+		return n
+	}
+	return node
+}
+
+func (r *reconciler) reconcilePodSpec(spec map[string]interface{}, path string) {
+	for _, key := range []string{"serviceAccountName", "serviceAccount"} {
+		if _, ok := spec[key].(string); ok && !r.checkField(spec, key, RefServiceAccount, joinPath(path, key), "stripped") {
+			delete(spec, key)
+		}
+	}
+	if _, ok := spec["priorityClassName"].(string); ok && !r.checkField(spec, "priorityClassName", RefPriorityClass, joinPath(path, "priorityClassName"), "stripped") {
+		delete(spec, "priorityClassName")
+	}
+
+	if secrets, ok := spec["imagePullSecrets"].([]interface{}); ok {
+		var kept []interface{}
+		for i, s := range secrets {
+			secret, ok := s.(map[string]interface{})
+			if !ok || r.checkField(secret, "name", RefSecret, fmt.Sprintf("%s[%d]", joinPath(path, "imagePullSecrets"), i), "stripped") {
+				kept = append(kept, s)
+			}
+		}
+		setOrDelete(spec, "imagePullSecrets", kept)
+	}
+
+	// Volumes: sources pointing at missing objects become emptyDir volumes so that
+	// mounts of the same name keep working.
+	declared := make(map[string]bool)
+	volumes, _ := spec["volumes"].([]interface{})
+	for i, v := range volumes {
+		vol, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		declared[stringAt(vol, "name")] = true
+		volPath := fmt.Sprintf("%s[%d]", joinPath(path, "volumes"), i)
+		if !r.volumeSourceExists(vol, volPath) {
+			volumes[i] = map[string]interface{}{
+				"name":     vol["name"],
+				"emptyDir": map[string]interface{}{},
+			}
+		}
+	}
+
+	for _, containersKey := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers, _ := spec[containersKey].([]interface{})
+		for ci, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			containerPath := fmt.Sprintf("%s[%d]", joinPath(path, containersKey), ci)
+
+			if mounts, ok := container["volumeMounts"].([]interface{}); ok {
+				var kept []interface{}
+				for mi, m := range mounts {
+					name := stringAt(m, "name")
+					if declared[name] {
+						kept = append(kept, m)
+						continue
+					}
+					mountPath := fmt.Sprintf("%s[%d]", joinPath(containerPath, "volumeMounts"), mi)
+					if r.collect || r.known.has(RefVolume, name) {
+						r.check(RefVolume, name, mountPath, "")
+						kept = append(kept, m)
+						continue
+					}
+					if r.repair {
+						r.check(RefVolume, name, mountPath, "added emptyDir volume for")
+						volumes = append(volumes, map[string]interface{}{
+							"name":     name,
+							"emptyDir": map[string]interface{}{},
+						})
+						declared[name] = true
+						kept = append(kept, m)
+					} else {
+						r.check(RefVolume, name, mountPath, "dangling")
+					}
+				}
+				setOrDelete(container, "volumeMounts", kept)
+			}
+
+			// Block devices cannot be backed by emptyDir, so they are stripped
+			if devices, ok := container["volumeDevices"].([]interface{}); ok {
+				var kept []interface{}
+				for di, d := range devices {
+					name := stringAt(d, "name")
+					if declared[name] || r.check(RefVolume, name, fmt.Sprintf("%s[%d]", joinPath(containerPath, "volumeDevices"), di), "stripped") {
+						kept = append(kept, d)
+					}
+				}
+				setOrDelete(container, "volumeDevices", kept)
+			}
+		}
+	}
+
+	if len(volumes) > 0 {
+		spec["volumes"] = volumes
+	}
+}
+
+// volumeSourceExists checks the objects a volume is backed by.
+func (r *reconciler) volumeSourceExists(vol map[string]interface{}, path string) bool {
+	const action = "replaced with emptyDir"
+	ok := true
+	if cm, found := vol["configMap"].(map[string]interface{}); found && !isOptional(cm) {
+		ok = r.checkField(cm, "name", RefConfigMap, joinPath(path, "configMap"), action) && ok
+	}
+	if secret, found := vol["secret"].(map[string]interface{}); found && !isOptional(secret) {
+		ok = r.checkField(secret, "secretName", RefSecret, joinPath(path, "secret"), action) && ok
+	}
+	if pvc, found := vol["persistentVolumeClaim"].(map[string]interface{}); found {
+		ok = r.checkField(pvc, "claimName", RefPersistentVolumeClaim, joinPath(path, "persistentVolumeClaim"), action) && ok
+	}
+	if projected, found := vol["projected"].(map[string]interface{}); found {
+		sources, _ := projected["sources"].([]interface{})
+		for i, s := range sources {
+			sourcePath := fmt.Sprintf("%s[%d]", joinPath(path, "projected.sources"), i)
+			if cm, found := mapAt(s, "configMap"); found && !isOptional(cm) {
+				ok = r.checkField(cm, "name", RefConfigMap, joinPath(sourcePath, "configMap"), action) && ok
+			}
+			if secret, found := mapAt(s, "secret"); found && !isOptional(secret) {
+				ok = r.checkField(secret, "name", RefSecret, joinPath(sourcePath, "secret"), action) && ok
+			}
+		}
+	}
+	return ok
+}
+
+func (r *reconciler) reconcileEnv(env []interface{}, path string) []interface{} {
+	var kept []interface{}
+	for i, e := range env {
+		valueFrom, found := mapAt(e, "valueFrom")
+		if !found {
+			kept = append(kept, e)
+			continue
+		}
+		entryPath := fmt.Sprintf("%s[%d].valueFrom", path, i)
+		ok := true
+		if ref, found := valueFrom["configMapKeyRef"].(map[string]interface{}); found && !isOptional(ref) {
+			ok = r.checkField(ref, "name", RefConfigMap, joinPath(entryPath, "configMapKeyRef"), "stripped")
+		}
+		if ref, found := valueFrom["secretKeyRef"].(map[string]interface{}); found && !isOptional(ref) {
+			ok = r.checkField(ref, "name", RefSecret, joinPath(entryPath, "secretKeyRef"), "stripped") && ok
+		}
+		if ok {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func (r *reconciler) reconcileEnvFrom(envFrom []interface{}, path string) []interface{} {
+	var kept []interface{}
+	for i, e := range envFrom {
+		entryPath := fmt.Sprintf("%s[%d]", path, i)
+		ok := true
+		if ref, found := mapAt(e, "configMapRef"); found && !isOptional(ref) {
+			ok = r.checkField(ref, "name", RefConfigMap, joinPath(entryPath, "configMapRef"), "stripped")
+		}
+		if ref, found := mapAt(e, "secretRef"); found && !isOptional(ref) {
+			ok = r.checkField(ref, "name", RefSecret, joinPath(entryPath, "secretRef"), "stripped") && ok
+		}
+		if ok {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func stringAt(node interface{}, key string) string {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	s, _ := m[key].(string)
+	return s
+}
+
+func mapAt(node interface{}, key string) (map[string]interface{}, bool) {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	child, ok := m[key].(map[string]interface{})
+	return child, ok
+}
+
+// isOptional reports whether a configMap/secret reference is marked optional, in
+// which case a missing object is not an error.
+func isOptional(ref map[string]interface{}) bool {
+	optional, _ := ref["optional"].(bool)
+	return optional
+}
+
+// setOrDelete stores a filtered list back, removing the key when nothing is left
+// so the field falls back to its default.
+func setOrDelete(m map[string]interface{}, key string, list []interface{}) {
+	if len(list) == 0 {
+		delete(m, key)
+		return
+	}
+	m[key] = list
+}
+
+// parseReferenceKind maps user input such as "configmaps" or "ConfigMap" to a
+// reference kind.
+func parseReferenceKind(kind string) string {
+	for _, k := range []string{RefVolume, RefConfigMap, RefSecret, RefPersistentVolumeClaim, RefServiceAccount, RefPriorityClass} {
+		lower := strings.ToLower(k)
+		if strings.EqualFold(kind, k) || strings.EqualFold(kind, lower+"s") || strings.EqualFold(kind, lower+"es") {
+			return k
+		}
+	}
+	return kind
+}
@@ -0,0 +1,69 @@
+package ctest
+
+import (
+	stdjson "encoding/json"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileResults(t *testing.T) {
+	base := v1.PodSpec{
+		Containers: []v1.Container{{
+			Name:         "app",
+			Image:        "busybox",
+			VolumeMounts: []v1.VolumeMount{{Name: "provided-by-test", MountPath: "/data"}},
+		}},
+		ServiceAccountName: "test-sa",
+	}
+	merged := base.DeepCopy()
+	merged.Containers[0].VolumeMounts = append(merged.Containers[0].VolumeMounts,
+		v1.VolumeMount{Name: "cache", MountPath: "/cache"})
+	merged.Containers[0].EnvFrom = []v1.EnvFromSource{
+		{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-config"}}},
+		{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "known-secret"}}},
+	}
+	merged.ServiceAccountName = "fixture-sa"
+
+	baseJSON, _ := stdjson.Marshal(base)
+	mergedJSON, _ := stdjson.Marshal(merged)
+
+	known := knownReferences{}
+	known.add(RefSecret, "known-secret")
+
+	results, reports, err := reconcileResults(baseJSON, [][]byte{mergedJSON}, ReconcileRepair, known)
+	if err != nil {
+		t.Fatalf("reconcileResults failed: %v", err)
+	}
+	if len(results) != 1 || len(reports) != 1 {
+		t.Fatalf("expected 1 repaired result and report, got %d results, %d reports", len(results), len(reports))
+	}
+	if len(reports[0].Actions) != 3 {
+		t.Errorf("expected 3 actions (cache volume, app-config, fixture-sa), got %v", reports[0].Actions)
+	}
+
+	var repaired v1.PodSpec
+	if err := stdjson.Unmarshal(results[0], &repaired); err != nil {
+		t.Fatalf("failed to unmarshal repaired spec: %v", err)
+	}
+	if len(repaired.Volumes) != 1 || repaired.Volumes[0].Name != "cache" || repaired.Volumes[0].EmptyDir == nil {
+		t.Errorf("expected an emptyDir volume named cache, got %+v", repaired.Volumes)
+	}
+	if len(repaired.Containers[0].EnvFrom) != 1 || repaired.Containers[0].EnvFrom[0].SecretRef == nil {
+		t.Errorf("expected only the known secret envFrom to remain, got %+v", repaired.Containers[0].EnvFrom)
+	}
+	if repaired.ServiceAccountName != "" {
+		t.Errorf("expected unknown service account to be stripped, got %q", repaired.ServiceAccountName)
+	}
+	if len(repaired.Containers[0].VolumeMounts) != 2 {
+		t.Errorf("expected both mounts to be kept, got %+v", repaired.Containers[0].VolumeMounts)
+	}
+
+	dropped, reports, err := reconcileResults(baseJSON, [][]byte{mergedJSON, baseJSON}, ReconcileDrop, known)
+	if err != nil {
+		t.Fatalf("reconcileResults failed: %v", err)
+	}
+	if len(dropped) != 1 || len(reports) != 1 || !reports[0].Dropped {
+		t.Errorf("expected the dangling config to be dropped, got %d results, reports %+v", len(dropped), reports)
+	}
+}
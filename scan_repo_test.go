@@ -3,8 +3,6 @@ package ctest
 import (
 	"flag"
 	"testing"
-
-	"k8s.io/kubernetes/test/ctest/fixtures"
 )
 
 var repoDir string
@@ -20,29 +18,7 @@ func TestGenerateFixtures(t *testing.T) {
 		t.Fatal("missing -repo flag")
 	}
 
-	// reset fixture store
-	if err := fixtures.ClearFixtures(); err != nil {
+	if _, err := GenerateFixtures(repoDir); err != nil {
 		t.Fatal(err)
 	}
-
-	files, err := collectYAMLFiles(repoDir)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	var allObjects []K8sObject
-
-	for _, f := range files {
-		objs, err := parseYAMLFile(f)
-		if err != nil {
-			continue
-		}
-		allObjects = append(allObjects, objs...)
-	}
-
-	if len(allObjects) == 0 {
-		t.Fatal("no valid kubernetes objects found")
-	}
-
-	ProcessObjects(allObjects)
 }
@@ -1,54 +1,64 @@
 package ctest
 
 import (
-	"io/fs"
-	//"os"
+	"fmt"
 	"path/filepath"
-	"strings"
+	"time"
 
-	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+	"k8s.io/kubernetes/test/ctest/walker"
 )
 
-func shouldSkipPath(path string) bool {
-	lower := strings.ToLower(path)
+// YAMLSuffixes are the names of the files scanned for fixtures.
+var YAMLSuffixes = []string{".yaml", ".yml"}
 
-	// skip templates directory
-	if strings.Contains(lower, string(filepath.Separator)+"templates"+string(filepath.Separator)) {
-		return true
-	}
+// collectYAMLFiles returns the YAML files under repo, leaving out what
+// walker.Fixtures and the .ctestignore files of repo ignore.
+func collectYAMLFiles(repo string) ([]string, error) {
+	w := &walker.Walker{Rules: walker.Fixtures, SkipErrors: true}
+	return w.Files(repo, YAMLSuffixes...)
+}
 
-	for _, w := range ctestglobals.WeirdPaths {
-		if strings.Contains(lower, strings.ToLower(w)) {
-			return true
-		}
+// GenerateFixtures replaces the fixture file with the Kubernetes objects of the
+// YAML files under repo and returns how many objects were found. The files are
+// recorded in the provenance file, see ProvenanceFile. The gitscan package
+// scans git repositories at a commit instead.
+func GenerateFixtures(repo string) (int, error) {
+	source, objects, err := ScanDir(repo, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := WriteFixtures(&Provenance{Sources: []*Source{source}}, objects); err != nil {
+		return 0, err
 	}
-	return false
+	return len(objects), nil
 }
 
-func collectYAMLFiles(repo string) ([]string, error) {
-	var files []string
-
-	err := filepath.WalkDir(repo, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // soft-fail
-		}
-
-		if shouldSkipPath(path) {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+// ScanDir parses the YAML files under repo for which keep, when set, returns
+// true for their slash-separated path relative to repo.
+func ScanDir(repo string, keep func(rel string) bool) (*Source, []K8sObject, error) {
+	repo, err := filepath.Abs(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := collectYAMLFiles(repo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to collect YAML files: %w", err)
+	}
 
-		if d.IsDir() {
-			return nil
+	source := &Source{Repo: repo, ScannedAt: time.Now()}
+	var allObjects []K8sObject
+	for _, f := range files {
+		rel, _ := filepath.Rel(repo, f)
+		rel = filepath.ToSlash(rel)
+		if keep != nil && !keep(rel) {
+			continue
 		}
-
-		if strings.HasSuffix(d.Name(), ".yaml") || strings.HasSuffix(d.Name(), ".yml") {
-			files = append(files, path)
+		objs, err := parseYAMLFile(f)
+		if err != nil {
+			continue
 		}
-		return nil
-	})
-
-	return files, err
+		source.AddFile(rel, "", objs)
+		allObjects = append(allObjects, objs...)
+	}
+	return source, allObjects, nil
 }
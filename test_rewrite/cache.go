@@ -0,0 +1,167 @@
+package testrewrite
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache modes, see RewriteOptions.CacheMode.
+const (
+	// CacheOn answers requests from the cache and stores new replies.
+	CacheOn = "on"
+	// CacheOff neither reads nor writes the cache.
+	CacheOff = "off"
+	// CacheRefresh asks the model again and replaces the cached replies.
+	CacheRefresh = "refresh"
+)
+
+// ResponseCache stores model replies on disk, content-addressed by the model,
+// the prompt templates and the conversation, which holds the content of the
+// rewritten file. Re-running an unchanged input returns the previous reply
+// without asking the model.
+type ResponseCache struct {
+	dir string
+}
+
+// cacheEntry is the file saved per reply, <dir>/<key[:2]>/<key>.json.
+type cacheEntry struct {
+	Model     string    `json:"model"`
+	Prompt    string    `json:"prompt"`
+	Reply     string    `json:"reply"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OpenResponseCache opens the cache in dir, creating it when needed.
+func OpenResponseCache(dir string) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create response cache: %w", err)
+	}
+	return &ResponseCache{dir: dir}, nil
+}
+
+// CacheKey identifies the reply of model to messages rendered from the prompt
+// templates prompt ("version@hash").
+func CacheKey(model, prompt string, messages []Message) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", model, prompt)
+	for _, m := range messages {
+		fmt.Fprintf(h, "%s\x00%s\x00", m.Role, m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached reply for key. A hit renews the entry for Prune.
+func (c *ResponseCache) Get(key string) (string, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return entry.Reply, true
+}
+
+// Put stores reply under key.
+func (c *ResponseCache) Put(key, model, prompt, reply string) error {
+	data, err := json.MarshalIndent(cacheEntry{Model: model, Prompt: prompt, Reply: reply, CreatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Write and rename so that concurrent workers never read half an entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Prune removes the entries not used within olderThan; 0 removes all of them.
+// It returns the number of removed entries.
+func (c *ResponseCache) Prune(olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if olderThan > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune response cache: %w", err)
+	}
+	return removed, nil
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// cachingClient answers from the cache before asking the wrapped client. Only
+// successful replies are stored.
+type cachingClient struct {
+	LLMClient
+	cache *ResponseCache
+	// prompt is the "version@hash" of the prompt templates
+	prompt string
+	mode   string
+	// readOnly keeps new replies out of the cache, for dry runs
+	readOnly bool
+	logf     func(format string, args ...interface{})
+}
+
+func (c *cachingClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	key := CacheKey(c.Model(), c.prompt, messages)
+	if c.mode == CacheOn {
+		if reply, ok := c.cache.Get(key); ok {
+			chatRecorderFrom(ctx).add(ChatStats{Cached: 1})
+			return reply, nil
+		}
+	}
+	reply, err := c.LLMClient.Chat(ctx, messages)
+	if err != nil {
+		return reply, err
+	}
+	if c.readOnly {
+		return reply, nil
+	}
+	if err := c.cache.Put(key, c.Model(), c.prompt, reply); err != nil {
+		c.logf("failed to cache reply: %v", err)
+	}
+	return reply, nil
+}
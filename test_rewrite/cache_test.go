@@ -0,0 +1,109 @@
+package testrewrite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingClient counts the requests that reach the model.
+type countingClient struct {
+	LLMClient
+	calls int
+}
+
+func (c *countingClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	c.calls++
+	return c.LLMClient.Chat(ctx, messages)
+}
+
+func TestResponseCache(t *testing.T) {
+	cache, err := OpenResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenResponseCache failed: %v", err)
+	}
+	messages := []Message{{Role: "user", Content: "rewrite a_test.go"}}
+	key := CacheKey("m", "v1@abc", messages)
+	for _, other := range []string{
+		CacheKey("other", "v1@abc", messages),
+		CacheKey("m", "v2@abc", messages),
+		CacheKey("m", "v1@abc", []Message{{Role: "user", Content: "rewrite b_test.go"}}),
+	} {
+		if other == key {
+			t.Errorf("different inputs have the same key %s", key)
+		}
+	}
+
+	if _, ok := cache.Get(key); ok {
+		t.Fatalf("empty cache returned a reply")
+	}
+	if err := cache.Put(key, "m", "v1@abc", "package foo"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if reply, ok := cache.Get(key); !ok || reply != "package foo" {
+		t.Errorf("Get = %q, %v", reply, ok)
+	}
+
+	// Entries used recently survive, old ones are pruned
+	if n, err := cache.Prune(time.Hour); err != nil || n != 0 {
+		t.Errorf("Prune(1h) = %d, %v", n, err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(cache.path(key), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := cache.Prune(time.Hour); err != nil || n != 1 {
+		t.Errorf("Prune(1h) = %d, %v", n, err)
+	}
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("pruned entry is still cached")
+	}
+}
+
+func TestRewriteFilesUsesCache(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	for name, content := range map[string]string{
+		"go.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte("NONE"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := &countingClient{LLMClient: NewFakeClient(fakeDir, "fake")}
+	opts := RewriteOptions{CacheDir: t.TempDir(), Logf: t.Logf}
+
+	for _, run := range []struct {
+		mode      string
+		calls     int
+		cacheHits int
+	}{
+		{CacheOn, 1, 0},
+		{CacheOn, 1, 1},
+		{CacheRefresh, 2, 0},
+		{CacheOff, 3, 0},
+	} {
+		// A fresh journal every run, as with OVERWRITE_REWRITTEN=true
+		opts.JournalPath = filepath.Join(t.TempDir(), "journal.json")
+		opts.CacheMode = run.mode
+		summary, err := RewriteFiles(context.Background(), client, []string{file}, opts)
+		if err != nil {
+			t.Fatalf("RewriteFiles failed: %v", err)
+		}
+		if client.calls != run.calls || summary.CacheHits != run.cacheHits || summary.Skipped != 1 {
+			t.Errorf("%s: %d calls, %d cache hits, summary %+v", run.mode, client.calls, summary.CacheHits, summary)
+		}
+	}
+
+	opts.CacheMode = "sometimes"
+	if _, err := RewriteFiles(context.Background(), client, []string{file}, opts); err == nil {
+		t.Errorf("expected an error for an unknown cache mode")
+	}
+}
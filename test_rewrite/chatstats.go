@@ -0,0 +1,74 @@
+package testrewrite
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ChatStats are the metrics of the LLM requests made for one file.
+type ChatStats struct {
+	Requests     int
+	PromptTokens int
+	// Tokens is the number of generated tokens
+	Tokens   int
+	Duration time.Duration
+	// Cached is the number of replies served from the ResponseCache
+	Cached int
+	// DoneReason is why the last reply ended: "stop", "length" (the reply was
+	// cut off at the token limit) or "interrupted"
+	DoneReason string
+}
+
+// ChatRecorder collects the ChatStats of the requests made with its context,
+// see WithChatStats.
+type ChatRecorder struct {
+	mu         sync.Mutex
+	stats      ChatStats
+	onProgress func(tokens int)
+}
+
+type chatRecorderKey struct{}
+
+// WithChatStats returns a context whose LLM requests are recorded in the returned
+// recorder. progress, if not nil, is called with the number of tokens received
+// so far while a reply is streamed.
+func WithChatStats(ctx context.Context, progress func(tokens int)) (context.Context, *ChatRecorder) {
+	rec := &ChatRecorder{onProgress: progress}
+	return context.WithValue(ctx, chatRecorderKey{}, rec), rec
+}
+
+// Stats returns the metrics recorded so far.
+func (r *ChatRecorder) Stats() ChatStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// chatRecorderFrom returns the recorder of ctx; its methods accept nil.
+func chatRecorderFrom(ctx context.Context) *ChatRecorder {
+	rec, _ := ctx.Value(chatRecorderKey{}).(*ChatRecorder)
+	return rec
+}
+
+func (r *ChatRecorder) add(s ChatStats) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Requests += s.Requests
+	r.stats.PromptTokens += s.PromptTokens
+	r.stats.Tokens += s.Tokens
+	r.stats.Duration += s.Duration
+	r.stats.Cached += s.Cached
+	if s.DoneReason != "" {
+		r.stats.DoneReason = s.DoneReason
+	}
+}
+
+func (r *ChatRecorder) progress(tokens int) {
+	if r != nil && r.onProgress != nil {
+		r.onProgress(tokens)
+	}
+}
@@ -0,0 +1,447 @@
+package testrewrite
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hardcodedConfigFuncPrefix is the prefix of the function every rewritten file
+// collects its hardcoded configs in.
+const hardcodedConfigFuncPrefix = "getHardCodedConfigInfo"
+
+// TestChunk is one test entry point of a file together with the declarations it
+// references, as a self-contained Go source.
+type TestChunk struct {
+	Name   string
+	Source string
+}
+
+// chunkSize returns REWRITE_CHUNK_SIZE, the file size in bytes above which files
+// are split per test (default 40000, 0 disables chunking).
+func chunkSize() int {
+	if v, err := strconv.Atoi(os.Getenv("REWRITE_CHUNK_SIZE")); err == nil && v >= 0 {
+		return v
+	}
+	return 40000
+}
+
+// BuildPrompts returns the prompts for a file: a single one when the file is small
+// enough, otherwise one per test entry point. With filter, tests without anything
+// to rewrite (see FindCandidates) are left out.
+func (p *PromptSet) BuildPrompts(path, content string, maxSize int, filter bool) ([]string, error) {
+	var chunks []TestChunk
+	if maxSize > 0 && len(content) > maxSize {
+		chunks, _ = SplitTests(path, []byte(content))
+	}
+	if len(chunks) <= 1 {
+		prompt, err := p.Build(path, content)
+		if err != nil {
+			return nil, err
+		}
+		return []string{prompt}, nil
+	}
+	prompts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if filter && prefilter(path, chunk.Source) != "" {
+			continue
+		}
+		prompt, err := p.Build(path, chunk.Source)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+// SplitTests finds the test entry points of a file (func TestXxx, ginkgo.It,
+// framework.ConformanceIt, f.It, ...) and returns one chunk per entry point. A
+// chunk keeps the package clause, the imports, the enclosing top-level declaration
+// without the other entry points, and the top-level declarations it references.
+func SplitTests(path string, src []byte) ([]TestChunk, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	tf := fset.File(f.Pos())
+	offset := func(p token.Pos) int { return tf.Offset(p) }
+
+	// Source ranges and names of every top-level declaration
+	type declInfo struct {
+		decl       ast.Decl
+		start, end int
+		names      []string
+	}
+	var decls []declInfo
+	declByName := make(map[string]int)
+	methodsByType := make(map[string][]int)
+	for _, d := range f.Decls {
+		info := declInfo{decl: d, start: offset(declStart(d)), end: offset(d.End())}
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv != nil && len(fn.Recv.List) > 0 {
+			// Methods travel with their type
+			recv := strings.TrimPrefix(recvTypeName(fn.Recv.List[0].Type), "*")
+			methodsByType[recv] = append(methodsByType[recv], len(decls))
+		}
+		info.names = declNames(d)
+		for _, n := range info.names {
+			if n != "_" {
+				declByName[n] = len(decls)
+			}
+		}
+		decls = append(decls, info)
+	}
+
+	var header strings.Builder
+	header.WriteString("package " + f.Name.Name + "\n\n")
+	for _, d := range f.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			header.Write(src[offset(gen.Pos()):offset(gen.End())])
+			header.WriteString("\n\n")
+		}
+	}
+
+	var chunks []TestChunk
+	for di, info := range decls {
+		entries := entryPoints(info.decl)
+		for ei, entry := range entries {
+			// Cut the other entry points out of the enclosing declaration
+			var cuts [][2]int
+			for oi, other := range entries {
+				if oi != ei && other.stmt != nil {
+					cuts = append(cuts, [2]int{offset(other.stmt.Pos()), offset(other.stmt.End())})
+				}
+			}
+			body := cutRanges(src[info.start:info.end], info.start, cuts)
+
+			// Declarations referenced by the entry point, transitively
+			needed := map[int]bool{di: true}
+			var queue []int
+			enqueue := func(names []string) {
+				for _, name := range names {
+					if idx, ok := declByName[name]; ok && !needed[idx] {
+						needed[idx] = true
+						queue = append(queue, idx)
+					}
+					for _, idx := range methodsByType[name] {
+						if !needed[idx] {
+							needed[idx] = true
+							queue = append(queue, idx)
+						}
+					}
+				}
+			}
+			enqueue(referencedNames(info.decl, cuts, offset))
+			for len(queue) > 0 {
+				idx := queue[0]
+				queue = queue[1:]
+				if len(entryPoints(decls[idx].decl)) > 0 {
+					// Never pull in other tests as helpers
+					delete(needed, idx)
+					continue
+				}
+				enqueue(referencedNames(decls[idx].decl, nil, offset))
+			}
+
+			var helpers []int
+			for idx := range needed {
+				if idx != di {
+					helpers = append(helpers, idx)
+				}
+			}
+			sort.Ints(helpers)
+
+			var b strings.Builder
+			b.WriteString(header.String())
+			for _, idx := range helpers {
+				b.Write(src[decls[idx].start:decls[idx].end])
+				b.WriteString("\n\n")
+			}
+			b.Write(body)
+			b.WriteString("\n")
+			chunks = append(chunks, TestChunk{Name: entry.name, Source: b.String()})
+		}
+	}
+	return chunks, nil
+}
+
+type entryPoint struct {
+	name string
+	// stmt is the statement holding an It call, nil for func TestXxx
+	stmt ast.Stmt
+}
+
+// entryPoints returns the tests declared by a top-level declaration.
+func entryPoints(decl ast.Decl) []entryPoint {
+	if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && isGoTestFunc(fn) {
+		return []entryPoint{{name: fn.Name.Name}}
+	}
+
+	var entries []entryPoint
+	ast.Inspect(decl, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.ExprStmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok || !isItCall(call) {
+			return true
+		}
+		name := "It"
+		if len(call.Args) > 0 {
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				name, _ = strconv.Unquote(lit.Value)
+			}
+		}
+		entries = append(entries, entryPoint{name: name, stmt: stmt})
+		// Its do not nest
+		return false
+	})
+	return entries
+}
+
+// isItCall recognizes ginkgo.It, framework.It, framework.ConformanceIt, f.It and a
+// dot-imported It.
+func isItCall(call *ast.CallExpr) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name == "It"
+	case *ast.SelectorExpr:
+		return fun.Sel.Name == "It" || fun.Sel.Name == "ConformanceIt"
+	}
+	return false
+}
+
+func declStart(d ast.Decl) token.Pos {
+	switch decl := d.(type) {
+	case *ast.FuncDecl:
+		if decl.Doc != nil {
+			return decl.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if decl.Doc != nil {
+			return decl.Doc.Pos()
+		}
+	}
+	return d.Pos()
+}
+
+func declNames(d ast.Decl) []string {
+	switch decl := d.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv != nil {
+			return nil
+		}
+		return []string{decl.Name.Name}
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range decl.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// referencedNames returns the identifiers used in decl outside the cut ranges.
+func referencedNames(decl ast.Decl, cuts [][2]int, offset func(token.Pos) int) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		for _, c := range cuts {
+			if offset(n.Pos()) >= c[0] && offset(n.End()) <= c[1] {
+				return false
+			}
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			add(id.Name)
+		}
+		return true
+	})
+	return names
+}
+
+func cutRanges(src []byte, base int, cuts [][2]int) []byte {
+	sort.Slice(cuts, func(a, b int) bool { return cuts[a][0] < cuts[b][0] })
+	var out []byte
+	pos := base
+	for _, c := range cuts {
+		out = append(out, src[pos-base:c[0]-base]...)
+		pos = c[1]
+	}
+	return append(out, src[pos-base:]...)
+}
+
+// MergeRewrites combines the rewritten chunks of one file into a single file. The
+// entries of the getHardCodedConfigInfo<FileName> functions are collected into
+// one function, other declarations are kept once, imports are merged. Parts that
+// are "NONE" are ignored.
+func MergeRewrites(newFile string, parts []string) (string, error) {
+	var pkgName string
+	var importSpecs, declTexts []string
+	imported := make(map[string]bool)
+	declared := make(map[string]bool)
+	renames := make(map[string]string)
+
+	configIndex := -1
+	var configName string
+	var configRbrace int
+	var extraElts []string
+
+	for i, part := range parts {
+		if strings.TrimSpace(part) == "NONE" {
+			continue
+		}
+		src := []byte(part)
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, fmt.Sprintf("%s#%d", newFile, i), src, parser.ParseComments)
+		if err != nil {
+			return "", fmt.Errorf("chunk %d: %w", i, err)
+		}
+		tf := fset.File(f.Pos())
+		text := func(from, to token.Pos) string { return string(src[tf.Offset(from):tf.Offset(to)]) }
+		if pkgName == "" {
+			pkgName = f.Name.Name
+		}
+
+		for _, imp := range f.Imports {
+			spec := text(imp.Pos(), imp.End())
+			if !imported[spec] {
+				imported[spec] = true
+				importSpecs = append(importSpecs, spec)
+			}
+		}
+
+		for _, d := range f.Decls {
+			if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				continue
+			}
+			if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && strings.HasPrefix(fn.Name.Name, hardcodedConfigFuncPrefix) {
+				lit := returnedCompositeLit(fn)
+				if lit == nil {
+					return "", fmt.Errorf("chunk %d: %s does not return a composite literal", i, fn.Name.Name)
+				}
+				if configIndex < 0 {
+					configIndex = len(declTexts)
+					configName = fn.Name.Name
+					configRbrace = tf.Offset(lit.Rbrace) - tf.Offset(declStart(fn))
+					declTexts = append(declTexts, text(declStart(fn), fn.End()))
+					continue
+				}
+				for _, elt := range lit.Elts {
+					extraElts = append(extraElts, text(elt.Pos(), elt.End()))
+				}
+				if fn.Name.Name != configName {
+					renames[fn.Name.Name] = configName
+				}
+				continue
+			}
+
+			// Every ginkgo chunk has its own var _ = SIGDescribe(...), blank names
+			// and init functions are never duplicates
+			var names []string
+			for _, n := range declNames(d) {
+				if n != "_" && n != "init" {
+					names = append(names, n)
+				}
+			}
+			duplicate := len(names) > 0
+			for _, n := range names {
+				if !declared[n] {
+					duplicate = false
+				}
+			}
+			if duplicate {
+				continue
+			}
+			for _, n := range names {
+				declared[n] = true
+			}
+			declTexts = append(declTexts, text(declStart(d), d.End()))
+		}
+	}
+	if pkgName == "" {
+		return "NONE", nil
+	}
+
+	if configIndex >= 0 && len(extraElts) > 0 {
+		configText := declTexts[configIndex]
+		head := strings.TrimRight(configText[:configRbrace], " \t\n")
+		if !strings.HasSuffix(head, ",") && !strings.HasSuffix(head, "{") {
+			head += ","
+		}
+		declTexts[configIndex] = head + "\n" + strings.Join(extraElts, ",\n") + ",\n" + configText[configRbrace:]
+	}
+
+	var b strings.Builder
+	b.WriteString("package " + pkgName + "\n\n")
+	if len(importSpecs) > 0 {
+		b.WriteString("import (\n\t" + strings.Join(importSpecs, "\n\t") + "\n)\n\n")
+	}
+	b.WriteString(strings.Join(declTexts, "\n\n"))
+	b.WriteString("\n")
+	out := []byte(b.String())
+
+	if len(renames) > 0 {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, newFile, out, parser.ParseComments)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse merged file: %w", err)
+		}
+		renameIdents(f, renames)
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, f); err != nil {
+			return "", fmt.Errorf("failed to print merged file: %w", err)
+		}
+		out = buf.Bytes()
+	}
+	if formatted, err := format.Source(out); err == nil {
+		out = formatted
+	}
+	return string(out), nil
+}
+
+// returnedCompositeLit finds the literal returned by a getHardCodedConfigInfo
+// function.
+func returnedCompositeLit(fn *ast.FuncDecl) *ast.CompositeLit {
+	if fn.Body == nil {
+		return nil
+	}
+	for i := len(fn.Body.List) - 1; i >= 0; i-- {
+		ret, ok := fn.Body.List[i].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			continue
+		}
+		lit, _ := ret.Results[0].(*ast.CompositeLit)
+		return lit
+	}
+	return nil
+}
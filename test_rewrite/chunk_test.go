@@ -0,0 +1,153 @@
+package testrewrite
+
+import (
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const chunkTestSource = `package node
+
+import (
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+)
+
+type podBuilder struct{ name string }
+
+func (b *podBuilder) build() string { return b.name }
+
+func newProbePod() string { return (&podBuilder{name: "probe"}).build() }
+
+func newSysctlPod() string { return "sysctl" }
+
+var _ = SIGDescribe("Pods", func() {
+	f := framework.NewDefaultFramework("pods")
+
+	ginkgo.It("uses a probe", func() {
+		_ = newProbePod()
+	})
+
+	framework.ConformanceIt("sets sysctls", func() {
+		_ = newSysctlPod()
+		_ = f
+	})
+})
+
+func TestStandalone(t *testing.T) {}
+`
+
+func TestSplitTests(t *testing.T) {
+	chunks, err := SplitTests("pods_test.go", []byte(chunkTestSource))
+	if err != nil {
+		t.Fatalf("SplitTests failed: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+
+	probe := chunks[0].Source
+	if chunks[0].Name != "uses a probe" {
+		t.Errorf("unexpected chunk name %q", chunks[0].Name)
+	}
+	for _, want := range []string{"func newProbePod()", "type podBuilder", "func (b *podBuilder) build()", `framework.NewDefaultFramework("pods")`} {
+		if !strings.Contains(probe, want) {
+			t.Errorf("probe chunk misses %q:\n%s", want, probe)
+		}
+	}
+	for _, unwanted := range []string{"newSysctlPod", "ConformanceIt", "TestStandalone"} {
+		if strings.Contains(probe, unwanted) {
+			t.Errorf("probe chunk contains %q:\n%s", unwanted, probe)
+		}
+	}
+
+	for _, c := range chunks {
+		if _, err := parser.ParseFile(token.NewFileSet(), "chunk.go", c.Source, 0); err != nil {
+			t.Errorf("chunk %q does not parse: %v\n%s", c.Name, err, c.Source)
+		}
+	}
+}
+
+func TestMergeRewrites(t *testing.T) {
+	part1 := `package node
+
+import ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+
+func helper() {}
+
+// getHardCodedConfigInfoPods collects the hardcoded configs.
+func getHardCodedConfigInfoPods() ctestglobals.HardcodedConfig {
+	return ctestglobals.HardcodedConfig{
+		{TestInfo: []string{"probe"}},
+	}
+}
+`
+	part2 := `package node
+
+import (
+	"fmt"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+func helper() {}
+
+func getHardCodedConfigInfoPodsTest() ctestglobals.HardcodedConfig {
+	return ctestglobals.HardcodedConfig{{TestInfo: []string{"sysctl"}}}
+}
+
+func use() { fmt.Println(getHardCodedConfigInfoPodsTest()) }
+`
+	merged, err := MergeRewrites("ctest_pods_test.go", []string{part1, "NONE", part2})
+	if err != nil {
+		t.Fatalf("MergeRewrites failed: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "merged.go", merged, parser.ParseComments); err != nil {
+		t.Fatalf("merged file does not parse: %v\n%s", err, merged)
+	}
+	if n := strings.Count(merged, "func getHardCodedConfigInfo"); n != 1 {
+		t.Errorf("expected one config function, got %d:\n%s", n, merged)
+	}
+	if n := strings.Count(merged, "func helper()"); n != 1 {
+		t.Errorf("expected helper once, got %d:\n%s", n, merged)
+	}
+	for _, want := range []string{`"probe"`, `"sysctl"`, "fmt.Println(getHardCodedConfigInfoPods())", "collects the hardcoded configs", `"fmt"`} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("merged file misses %q:\n%s", want, merged)
+		}
+	}
+}
+
+func TestMergeRewritesGinkgo(t *testing.T) {
+	chunks, err := SplitTests("pods_test.go", []byte(chunkTestSource))
+	if err != nil {
+		t.Fatalf("SplitTests failed: %v", err)
+	}
+	// Each rewritten chunk keeps its own var _ = SIGDescribe(...) and adds its
+	// config entries
+	var parts []string
+	for _, c := range chunks {
+		parts = append(parts, c.Source+"\nfunc getHardCodedConfigInfoPods() []string {\n\treturn []string{"+strconv.Quote(c.Name)+"}\n}\n")
+	}
+	merged, err := MergeRewrites("ctest_pods_test.go", parts)
+	if err != nil {
+		t.Fatalf("MergeRewrites failed: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "merged.go", merged, parser.ParseComments); err != nil {
+		t.Fatalf("merged file does not parse: %v\n%s", err, merged)
+	}
+	if n := strings.Count(merged, `SIGDescribe("Pods"`); n != 2 {
+		t.Errorf("expected the Describe of both ginkgo chunks, got %d:\n%s", n, merged)
+	}
+	for _, want := range []string{`ginkgo.It("uses a probe"`, `framework.ConformanceIt("sets sysctls"`, "func TestStandalone", `"TestStandalone",`} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("merged file misses %q:\n%s", want, merged)
+		}
+	}
+	if n := strings.Count(merged, "func newProbePod()"); n != 1 {
+		t.Errorf("expected newProbePod once, got %d:\n%s", n, merged)
+	}
+}
@@ -0,0 +1,455 @@
+package testrewrite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// ErrNotRecognized is returned by RewriteDeterministic for files holding config
+// the mechanical rewrite does not know how to lift; those go to the model.
+var ErrNotRecognized = errors.New("no deterministic rewrite pattern matches")
+
+// probeFields are the container fields whose v1.Probe literal can be lifted, by
+// the Field name of the hardcoded config entry.
+var probeFields = map[string]string{
+	"LivenessProbe":  "livenessProbe",
+	"ReadinessProbe": "readinessProbe",
+	"StartupProbe":   "startupProbe",
+}
+
+// deterministicStamp is the header of files rewritten without a prompt.
+const deterministicStamp = stampPrefix + " deterministic, no model"
+
+// reservedNames are declared by the code wrapped around a rewritten test; a test
+// using them from an outer scope cannot be wrapped without changing its meaning.
+var reservedNames = map[string]bool{
+	"configs": true, "item": true, "found": true, "configObjs": true,
+	"configJson": true, "configObj": true, "i": true, "err": true,
+}
+
+// deterministicEnabled reads REWRITE_DETERMINISTIC (default true).
+func deterministicEnabled() bool {
+	if v, err := strconv.ParseBool(os.Getenv("REWRITE_DETERMINISTIC")); err == nil {
+		return v
+	}
+	return true
+}
+
+// lift is a config literal of one test moved into getHardCodedConfigInfo<File>.
+type lift struct {
+	testInfo string
+	field    string
+	// typeName is the type argument of GenerateEffectiveConfigReturnType
+	typeName string
+	// expr is replaced by configObj (or &configObj), lit is what is stored
+	expr ast.Expr
+	lit  *ast.CompositeLit
+	// body is the function body wrapped in the loop over the generated configs
+	body *ast.BlockStmt
+	// fail is the call reporting a setup error, framework.Failf or t.Fatalf
+	fail string
+}
+
+// RewriteDeterministic performs the rewrite shown in the prompt examples without
+// a model, for the patterns it recognizes: a test (func TestXxx, ginkgo.It,
+// framework.ConformanceIt, ...) whose only config is a v1.PodSpec literal, or a
+// v1.Probe literal set as liveness, readiness or startup probe, built from
+// constants and package-level names. The literal is moved into
+// getHardCodedConfigInfo<File>, and the test body runs once per config returned
+// by ctest.GenerateEffectiveConfigReturnType.
+//
+// Files with any config literal or test case table outside such a pattern are
+// left to the model: RewriteDeterministic returns ErrNotRecognized.
+func RewriteDeterministic(path string, src []byte) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	tf := fset.File(f.Pos())
+	offset := func(p token.Pos) int { return tf.Offset(p) }
+
+	apiPackages := make(map[string]bool)
+	frameworkName := ""
+	for _, imp := range f.Imports {
+		importPath, _ := strconv.Unquote(imp.Path.Value)
+		name := importPath[strings.LastIndex(importPath, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		switch {
+		case strings.HasPrefix(importPath, "k8s.io/api/"):
+			apiPackages[name] = true
+		case importPath == "k8s.io/kubernetes/test/e2e/framework":
+			frameworkName = name
+		}
+	}
+
+	type keptDecl struct {
+		decl  ast.Decl
+		lifts []lift
+		cuts  [][2]int
+	}
+	var kept []keptDecl
+	var lifts []lift
+	testInfos := make(map[string]int)
+	for _, decl := range f.Decls {
+		entries := entryPoints(decl)
+		if len(entries) == 0 {
+			continue
+		}
+		k := keptDecl{decl: decl}
+		for _, entry := range entries {
+			body, fail := entryBody(decl, entry, frameworkName)
+			if body == nil {
+				k.cuts = append(k.cuts, [2]int{offset(entry.stmt.Pos()), offset(entry.stmt.End())})
+				continue
+			}
+			found, ok := findLifts(body, apiPackages)
+			if !ok || len(found) > 1 {
+				return "", ErrNotRecognized
+			}
+			if len(found) == 0 {
+				if entry.stmt != nil {
+					k.cuts = append(k.cuts, [2]int{offset(entry.stmt.Pos()), offset(entry.stmt.End())})
+				}
+				continue
+			}
+			l := found[0]
+			if fail == "" || !isClosed(l.lit, decl) || usesReservedNames(body) {
+				return "", ErrNotRecognized
+			}
+			l.body, l.fail = body, fail
+			l.testInfo = entry.name
+			if n := testInfos[entry.name]; n > 0 {
+				l.testInfo = fmt.Sprintf("%s (%d)", entry.name, n+1)
+			}
+			testInfos[entry.name]++
+			k.lifts = append(k.lifts, l)
+			lifts = append(lifts, l)
+		}
+		if len(k.lifts) > 0 {
+			kept = append(kept, k)
+		}
+	}
+	if len(lifts) == 0 || !allCandidatesLifted(f, apiPackages, lifts) {
+		return "", ErrNotRecognized
+	}
+
+	configFunc := hardcodedConfigFuncPrefix + fileSuffix(path)
+
+	var b strings.Builder
+	b.WriteString("package " + f.Name.Name + "\n\n")
+	for _, d := range f.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			b.Write(src[offset(gen.Pos()):offset(gen.End())])
+			b.WriteString("\n\n")
+		}
+	}
+	for _, k := range kept {
+		start, end := offset(declStart(k.decl)), offset(k.decl.End())
+		var edits []textEdit
+		for _, c := range k.cuts {
+			edits = append(edits, textEdit{c[0], c[1], ""})
+		}
+		if fn, ok := k.decl.(*ast.FuncDecl); ok && !strings.HasPrefix(fn.Name.Name, "TestCtest") {
+			edits = append(edits, textEdit{offset(fn.Name.Pos()), offset(fn.Name.End()), "TestCtest" + strings.TrimPrefix(fn.Name.Name, "Test")})
+		}
+		for _, l := range k.lifts {
+			replacement := "configObj"
+			if _, ok := l.expr.(*ast.UnaryExpr); ok {
+				replacement = "&configObj"
+			}
+			lbrace, rbrace := offset(l.body.Lbrace)+1, offset(l.body.Rbrace)
+			edits = append(edits,
+				textEdit{lbrace, lbrace, "\n" + loopPrologue(l, configFunc)},
+				textEdit{offset(l.expr.Pos()), offset(l.expr.End()), replacement},
+				textEdit{rbrace, rbrace, "\n" + loopEpilogue},
+			)
+		}
+		b.WriteString(applyEdits(src[start:end], start, edits))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("func " + configFunc + "() ctestglobals.HardcodedConfig {\n\treturn ctestglobals.HardcodedConfig{\n")
+	for _, l := range lifts {
+		fmt.Fprintf(&b, "\t\t{\n\t\t\tFixtureFileName: \"test_fixture.json\",\n\t\t\tTestInfo: []string{%s},\n\t\t\tField: %q,\n\t\t\tK8sObjects: ctestglobals.PodSpecIncludeObjects,\n\t\t\tHardcodedConfig: %s,\n\t\t},\n",
+			strconv.Quote(l.testInfo), l.field, src[offset(l.lit.Pos()):offset(l.lit.End())])
+	}
+	b.WriteString("\t}\n}\n")
+
+	return fixDeterministicImports(path, b.String())
+}
+
+// entryBody returns the body of a test and the call its setup failures are
+// reported with, or "" when there is no way to fail the test.
+func entryBody(decl ast.Decl, entry entryPoint, frameworkName string) (*ast.BlockStmt, string) {
+	if entry.stmt == nil {
+		fn := decl.(*ast.FuncDecl)
+		params := fn.Type.Params.List
+		if len(params) != 1 || len(params[0].Names) != 1 || params[0].Names[0].Name == "_" {
+			return fn.Body, ""
+		}
+		return fn.Body, params[0].Names[0].Name + ".Fatalf"
+	}
+	call := entry.stmt.(*ast.ExprStmt).X.(*ast.CallExpr)
+	if len(call.Args) == 0 {
+		return nil, ""
+	}
+	lit, ok := call.Args[len(call.Args)-1].(*ast.FuncLit)
+	if !ok {
+		return nil, ""
+	}
+	if frameworkName == "" {
+		return lit.Body, ""
+	}
+	return lit.Body, frameworkName + ".Failf"
+}
+
+// findLifts returns the outermost liftable config literals of body. It reports
+// false when body holds a probe literal the rewrite cannot name a Field for.
+func findLifts(body *ast.BlockStmt, apiPackages map[string]bool) ([]lift, bool) {
+	var lifts []lift
+	ok := true
+	ast.Inspect(body, func(n ast.Node) bool {
+		if !ok {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.KeyValueExpr:
+			key, isIdent := x.Key.(*ast.Ident)
+			if !isIdent {
+				return true
+			}
+			field, isProbe := probeFields[key.Name]
+			if lit, typeName := liftableLit(x.Value, apiPackages); isProbe && lit != nil && strings.HasSuffix(typeName, ".Probe") {
+				lifts = append(lifts, lift{field: field, typeName: typeName, expr: x.Value, lit: lit})
+				return false
+			}
+		case ast.Expr:
+			lit, typeName := liftableLit(x, apiPackages)
+			if lit == nil {
+				return true
+			}
+			if strings.HasSuffix(typeName, ".Probe") {
+				// A probe that is not the value of a probe field
+				ok = false
+				return false
+			}
+			lifts = append(lifts, lift{field: "spec", typeName: typeName, expr: x, lit: lit})
+			return false
+		}
+		return true
+	})
+	return lifts, ok
+}
+
+// liftableLit matches pkg.PodSpec{...}, pkg.Probe{...} and their address for a
+// k8s.io/api package pkg.
+func liftableLit(expr ast.Expr, apiPackages map[string]bool) (*ast.CompositeLit, string) {
+	if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		expr = u.X
+	}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, ""
+	}
+	sel, ok := lit.Type.(*ast.SelectorExpr)
+	if !ok {
+		return nil, ""
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || !apiPackages[pkg.Name] || (sel.Sel.Name != "PodSpec" && sel.Sel.Name != "Probe") {
+		return nil, ""
+	}
+	return lit, pkg.Name + "." + sel.Sel.Name
+}
+
+// isClosed reports whether lit only uses constants, package-level names and
+// names it declares itself, so that it still compiles once moved out of decl.
+func isClosed(lit *ast.CompositeLit, decl ast.Decl) bool {
+	return !anyIdent(lit, func(id *ast.Ident) bool {
+		d := declNode(id)
+		return d != nil && d.Pos() >= decl.Pos() && d.Pos() < decl.End() &&
+			(d.Pos() < lit.Pos() || d.Pos() >= lit.End())
+	})
+}
+
+// usesReservedNames reports whether body refers to one of reservedNames declared
+// outside of it.
+func usesReservedNames(body *ast.BlockStmt) bool {
+	return anyIdent(body, func(id *ast.Ident) bool {
+		if !reservedNames[id.Name] {
+			return false
+		}
+		d := declNode(id)
+		return d == nil || d.Pos() < body.Pos() || d.Pos() >= body.End()
+	})
+}
+
+// anyIdent reports whether pred holds for an identifier referring to a variable,
+// constant, type or function in node. Selected names (x.Sel) and field names of
+// composite literals are skipped.
+func anyIdent(node ast.Node, pred func(*ast.Ident) bool) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.KeyValueExpr:
+			if _, ok := x.Key.(*ast.Ident); ok {
+				found = anyIdent(x.Value, pred)
+				return false
+			}
+		case *ast.SelectorExpr:
+			found = anyIdent(x.X, pred)
+			return false
+		case *ast.Ident:
+			found = pred(x)
+		}
+		return true
+	})
+	return found
+}
+
+// declNode returns the node declaring id as resolved by the parser, nil for
+// names declared in other files or predeclared ones.
+func declNode(id *ast.Ident) ast.Node {
+	if id.Obj == nil {
+		return nil
+	}
+	d, _ := id.Obj.Decl.(ast.Node)
+	return d
+}
+
+// allCandidatesLifted reports whether every config literal of f is part of a
+// lifted literal or holds one (the pod around a pod spec), and f has no test
+// case tables.
+func allCandidatesLifted(f *ast.File, apiPackages map[string]bool, lifts []lift) bool {
+	covered := func(n ast.Node) bool {
+		for _, l := range lifts {
+			inside := n.Pos() >= l.lit.Pos() && n.End() <= l.lit.End()
+			around := n.Pos() <= l.lit.Pos() && n.End() >= l.lit.End()
+			if inside || around {
+				return true
+			}
+		}
+		return false
+	}
+	all := true
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CompositeLit:
+			if apiTypeName(x.Type, apiPackages) != "" && !covered(x) {
+				all = false
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range x.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && i < len(x.Rhs) && isTestTable(id.Name, x.Rhs[i]) {
+					all = false
+				}
+			}
+		case *ast.ValueSpec:
+			for i, id := range x.Names {
+				if i < len(x.Values) && isTestTable(id.Name, x.Values[i]) {
+					all = false
+				}
+			}
+		}
+		return all
+	})
+	return all
+}
+
+// loopPrologue is the code put before the original test body, as in the prompt
+// examples.
+func loopPrologue(l lift, configFunc string) string {
+	return fmt.Sprintf(`fmt.Println(ctestglobals.StartSeparator)
+configs := %s()
+item, found := ctestutils.GetItemByExactTestInfo(configs, %s)
+if !found {
+	fmt.Println(ctestglobals.DebugPrefix(), "Failed to find config item by TestInfo")
+	%s("Get default hardcoded config failed.")
+}
+fmt.Println(ctestglobals.DebugPrefix(), "get default configs:", item)
+fmt.Println(ctestglobals.StartExtendModeSeparator)
+configObjs, configJson, err := ctest.GenerateEffectiveConfigReturnType[%s](item, ctest.ExtendOnly)
+if err != nil {
+	fmt.Println(ctestglobals.DebugPrefix(), "Failed to get matched fixtures:", err)
+	%s("Failed to get matched fixtures: %%v", err)
+}
+if configObjs != nil {
+	fmt.Println(ctestglobals.DebugPrefix(), "New Json Test Configs:", string(configJson))
+	fmt.Println(ctestglobals.DebugPrefix(), "Num of Test Cases:", len(configObjs))
+	for i, configObj := range configObjs {
+		fmt.Printf("Running %%d th test cases.\n", i)
+		fmt.Println(configObj)
+`, configFunc, strconv.Quote(l.testInfo), l.fail, l.typeName, l.fail)
+}
+
+const loopEpilogue = `	}
+} else {
+	fmt.Println(ctestglobals.DebugPrefix(), "Skipping test execution. No new config objs found.")
+}
+fmt.Println(ctestglobals.EndSeparator)
+`
+
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits applies non-overlapping edits to src, which starts at offset base of
+// the file.
+func applyEdits(src []byte, base int, edits []textEdit) string {
+	sort.SliceStable(edits, func(a, b int) bool { return edits[a].start < edits[b].start })
+	var b strings.Builder
+	pos := base
+	for _, e := range edits {
+		b.Write(src[pos-base : e.start-base])
+		b.WriteString(e.text)
+		pos = e.end
+	}
+	b.Write(src[pos-base:])
+	return b.String()
+}
+
+// fixDeterministicImports adds the imports of the generated code and removes the
+// ones the dropped declarations used.
+func fixDeterministicImports(path, src string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("deterministic rewrite of %s does not parse: %w", path, err)
+	}
+	astutil.AddImport(fset, f, "fmt")
+	for _, name := range []string{"ctest", "ctestglobals"} {
+		astutil.AddNamedImport(fset, f, name, ctestImports[name])
+	}
+	astutil.AddNamedImport(fset, f, "ctestutils", "k8s.io/kubernetes/test/ctest/utils")
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f); err != nil {
+		return "", fmt.Errorf("failed to print deterministic rewrite of %s: %w", path, err)
+	}
+	out, err := imports.Process(walker.RewriteOf(path), buf.Bytes(), &imports.Options{Comments: true, TabIndent: true, TabWidth: 8})
+	if err != nil {
+		return "", fmt.Errorf("failed to fix imports of %s: %w", path, err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,123 @@
+package testrewrite
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const deterministicTestSource = `package node
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/test/e2e/framework"
+	imageutils "k8s.io/kubernetes/test/utils/image"
+
+	"github.com/onsi/ginkgo/v2"
+)
+
+var _ = SIGDescribe("Pods", func() {
+	f := framework.NewDefaultFramework("pods")
+
+	ginkgo.It("runs a pod", func(ctx context.Context) {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: f.Namespace.Name},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "c", Image: imageutils.GetE2EImage(imageutils.BusyBox)}},
+				// keep the pod around
+				RestartPolicy: v1.RestartPolicyNever,
+			},
+		}
+		_ = pod
+	})
+
+	ginkgo.It("does nothing with config", func() {
+		_ = f
+	})
+})
+
+func TestProbe(t *testing.T) {
+	c := v1.Container{
+		Name:          "c",
+		LivenessProbe: &v1.Probe{InitialDelaySeconds: 15},
+	}
+	_ = c
+}
+`
+
+func TestRewriteDeterministic(t *testing.T) {
+	out, err := RewriteDeterministic("pods_test.go", []byte(deterministicTestSource))
+	if err != nil {
+		t.Fatalf("RewriteDeterministic failed: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "ctest_pods_test.go", out, 0); err != nil {
+		t.Fatalf("rewritten file does not parse: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"func getHardCodedConfigInfoPods() ctestglobals.HardcodedConfig",
+		`TestInfo:        []string{"runs a pod"}`,
+		`Field:           "livenessProbe"`,
+		"ctest.GenerateEffectiveConfigReturnType[v1.PodSpec](item, ctest.ExtendOnly)",
+		"ctest.GenerateEffectiveConfigReturnType[v1.Probe](item, ctest.ExtendOnly)",
+		"Spec:       configObj,",
+		"LivenessProbe: &configObj,",
+		`framework.Failf("Get default hardcoded config failed.")`,
+		`t.Fatalf("Get default hardcoded config failed.")`,
+		"func TestCtestProbe(t *testing.T)",
+		"// keep the pod around",
+		`ctestutils "k8s.io/kubernetes/test/ctest/utils"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rewritten file misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "does nothing with config") {
+		t.Errorf("test without config was kept:\n%s", out)
+	}
+}
+
+func TestRewriteDeterministicNotRecognized(t *testing.T) {
+	for name, src := range map[string]string{
+		"local variable in literal": `package node
+
+import v1 "k8s.io/api/core/v1"
+
+func TestPod(t *testing.T) {
+	name := "c"
+	spec := v1.PodSpec{Containers: []v1.Container{{Name: name}}}
+	_ = spec
+}
+`,
+		"test case table": `package node
+
+import v1 "k8s.io/api/core/v1"
+
+func TestPod(t *testing.T) {
+	spec := v1.PodSpec{}
+	testCases := []struct{ name string }{{"a"}}
+	_, _ = spec, testCases
+}
+`,
+		"config outside a test": `package node
+
+import v1 "k8s.io/api/core/v1"
+
+func newContainer() v1.Container { return v1.Container{Name: "c"} }
+
+func TestPod(t *testing.T) {
+	spec := v1.PodSpec{}
+	_ = spec
+}
+`,
+	} {
+		if _, err := RewriteDeterministic("pods_test.go", []byte(src)); !errors.Is(err, ErrNotRecognized) {
+			t.Errorf("%s: expected ErrNotRecognized, got %v", name, err)
+		}
+	}
+}
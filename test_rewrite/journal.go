@@ -0,0 +1,175 @@
+package testrewrite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/activation"
+)
+
+// Rewrite statuses recorded in the journal.
+const (
+	StatusRewritten = "rewritten"
+	StatusNone      = "none"
+	StatusFailed    = "failed"
+	StatusInvalid   = "invalid"
+)
+
+// JournalEntry records the outcome of rewriting one file.
+type JournalEntry struct {
+	File       string `json:"file"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	Model      string `json:"model"`
+	PromptHash string `json:"promptHash"`
+	// Prompt is the version and template hash of the prompt, see PromptSet
+	Prompt string `json:"prompt,omitempty"`
+	// Requests, Tokens, DoneReason and DurationMs are the LLM metrics of the
+	// last attempt, see ChatStats
+	Requests   int    `json:"requests,omitempty"`
+	Tokens     int    `json:"tokens,omitempty"`
+	DoneReason string `json:"doneReason,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	// Output is where the rewrite was written: the ctest_ file, or its copy in
+	// an overlay directory
+	Output string `json:"output,omitempty"`
+	// OutputHash is the ContentHash of the rewritten file as it was written, to
+	// tell hand edits apart
+	OutputHash string    `json:"outputHash,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Done reports whether the file needs no further work for the given model and
+// prompt when its rewrite goes to output. A rewrite is only done while output
+// still has the content that was written. Failed files are retried on the next
+// run.
+func (e JournalEntry) Done(model, promptHash, output string) bool {
+	if e.Model != model || e.PromptHash != promptHash || e.Output != output {
+		return false
+	}
+	switch e.Status {
+	case StatusNone:
+		return true
+	case StatusRewritten:
+		data, err := os.ReadFile(output)
+		return err == nil && ContentHash(data) == e.OutputHash
+	}
+	return false
+}
+
+// Journal is the progress of a rewrite run, saved after every file so that an
+// interrupted run resumes where it stopped.
+type Journal struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]JournalEntry
+}
+
+// OpenJournal loads the journal at path, or starts an empty one if it does not
+// exist. An empty path keeps the journal in memory only.
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path, entries: make(map[string]JournalEntry)}
+	if path == "" {
+		return j, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	var entries []JournalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse journal %s: %w", path, err)
+	}
+	for _, e := range entries {
+		j.entries[e.File] = e
+	}
+	return j, nil
+}
+
+// Get returns the entry of file.
+func (j *Journal) Get(file string) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e, ok := j.entries[file]
+	return e, ok
+}
+
+// Record stores the entry and saves the journal.
+func (j *Journal) Record(e JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e.UpdatedAt = time.Now()
+	j.entries[e.File] = e
+	return j.save()
+}
+
+// Put stores the entry as is, keeping its UpdatedAt, and saves the journal.
+func (j *Journal) Put(e JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[e.File] = e
+	return j.save()
+}
+
+// Delete forgets the entry of file and saves the journal, so that the next run
+// rewrites the file again.
+func (j *Journal) Delete(file string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.entries[file]; !ok {
+		return nil
+	}
+	delete(j.entries, file)
+	return j.save()
+}
+
+// save writes the journal atomically, so a crash never leaves it half written.
+func (j *Journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	entries := make([]JournalEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].File < entries[b].File })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// PromptHash identifies the prompt a file was rewritten with.
+func PromptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// ContentHash identifies the content of a rewritten file, see
+// JournalEntry.OutputHash. The ctest build tag is left out, so switching the
+// package with the activation package is not taken for a hand edit.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(activation.WithoutTag(data, activation.CtestTag))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+package testrewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// K8sObjectsProblem is a K8sObjects entry that is not a fixture key.
+type K8sObjectsProblem struct {
+	Pos   token.Position
+	Entry string
+	// FixtureKey is what the entry resolves to, empty when it matches no kind.
+	FixtureKey string
+}
+
+func (p K8sObjectsProblem) String() string {
+	if p.FixtureKey == "" {
+		return fmt.Sprintf("%s: unknown K8sObjects entry %q", p.Pos, p.Entry)
+	}
+	return fmt.Sprintf("%s: K8sObjects entry %q should be %q", p.Pos, p.Entry, p.FixtureKey)
+}
+
+// CheckK8sObjects parses every ctest_*.go file under root and reports the
+// K8sObjects entries that are not fixture keys (see ctestglobals.Kinds).
+func CheckK8sObjects(root string) ([]K8sObjectsProblem, error) {
+	var problems []K8sObjectsProblem
+	err := walker.New(walker.Rewrites).Walk(root, func(path string, d fs.DirEntry) error {
+		if !walker.IsRewrite(path) || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found, err := CheckK8sObjectsSource(path, src)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, found...)
+		return nil
+	})
+	return problems, err
+}
+
+// CheckK8sObjectsSource reports the K8sObjects entries of src that are not
+// fixture keys.
+func CheckK8sObjectsSource(path string, src []byte) ([]K8sObjectsProblem, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var problems []K8sObjectsProblem
+	for _, lit := range k8sObjectsEntries(f) {
+		entry, _ := strconv.Unquote(lit.Value)
+		kind, ok := ctestglobals.LookupKind(entry)
+		if ok && kind.FixtureKey == entry {
+			continue
+		}
+		problems = append(problems, K8sObjectsProblem{Pos: fset.Position(lit.Pos()), Entry: entry, FixtureKey: kind.FixtureKey})
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Pos.Line < problems[j].Pos.Line })
+	return problems, nil
+}
+
+// fixK8sObjects replaces the K8sObjects entries of f that resolve to a kind with
+// its fixture key. It returns "old -> new" for every replacement and the entries
+// matching no kind.
+func fixK8sObjects(f *ast.File) (fixed, unknown []string) {
+	for _, lit := range k8sObjectsEntries(f) {
+		entry, _ := strconv.Unquote(lit.Value)
+		kind, ok := ctestglobals.LookupKind(entry)
+		switch {
+		case !ok:
+			unknown = append(unknown, entry)
+		case kind.FixtureKey != entry:
+			lit.Value = strconv.Quote(kind.FixtureKey)
+			fixed = append(fixed, entry+" -> "+kind.FixtureKey)
+		}
+	}
+	return fixed, unknown
+}
+
+// k8sObjectsEntries returns the string literals listed in K8sObjects fields.
+// Entries given by a variable (ctestglobals.PodSpecIncludeObjects) are left out.
+func k8sObjectsEntries(f *ast.File) []*ast.BasicLit {
+	var entries []*ast.BasicLit
+	ast.Inspect(f, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "K8sObjects" {
+			return true
+		}
+		list, ok := kv.Value.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		for _, elt := range list.Elts {
+			if lit, ok := elt.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				entries = append(entries, lit)
+			}
+		}
+		return true
+	})
+	return entries
+}
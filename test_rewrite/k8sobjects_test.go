@@ -0,0 +1,78 @@
+package testrewrite
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckK8sObjects(t *testing.T) {
+	dir := t.TempDir()
+	src := `package node
+
+import ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+
+func getHardCodedConfigInfoPods() ctestglobals.HardcodedConfig {
+	return ctestglobals.HardcodedConfig{
+		{K8sObjects: []string{"pods", "statefulsets"}},
+		{K8sObjects: ctestglobals.PodSpecIncludeObjects},
+		{K8sObjects: []string{"ingressws"}},
+	}
+}
+`
+	for name, content := range map[string]string{
+		"ctest_pods_test.go":       src,
+		"pods_test.go":             src,
+		"vendor/ctest_vendored.go": src,
+		"sub/ctest_other_test.go":  "package sub\n\nvar x = struct{ K8sObjects []string }{K8sObjects: []string{\"configMaps\"}}\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	problems, err := CheckK8sObjects(dir)
+	if err != nil {
+		t.Fatalf("CheckK8sObjects failed: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	if problems[0].Entry != "statefulsets" || problems[0].FixtureKey != "statefulSets" || problems[0].Pos.Line != 7 {
+		t.Errorf("unexpected problem %s", problems[0])
+	}
+	if problems[1].Entry != "ingressws" || problems[1].FixtureKey != "" {
+		t.Errorf("unexpected problem %s", problems[1])
+	}
+}
+
+// TestK8sObjectsInRewrittenFiles checks the K8sObjects of every ctest_ file in
+// the Kubernetes tree (K8S_ROOT, default three levels up). It walks the whole
+// tree, so it only runs when K8S_ROOT or CHECK_K8SOBJECTS=true is set, see make
+// check-k8sobjects.
+func TestK8sObjectsInRewrittenFiles(t *testing.T) {
+	k8sRoot := os.Getenv("K8S_ROOT")
+	if k8sRoot == "" && !strings.EqualFold(os.Getenv("CHECK_K8SOBJECTS"), "true") {
+		t.Skip("neither K8S_ROOT nor CHECK_K8SOBJECTS is set")
+	}
+	if k8sRoot == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			t.Fatalf("failed to get current working dir: %v", err)
+		}
+		k8sRoot = filepath.Clean(filepath.Join(cwd, "../../.."))
+	}
+
+	problems, err := CheckK8sObjects(k8sRoot)
+	if err != nil {
+		t.Fatalf("CheckK8sObjects failed: %v", err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
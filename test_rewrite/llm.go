@@ -0,0 +1,397 @@
+package testrewrite
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// Supported LLM providers.
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake"
+)
+
+// DefaultNumCtx is the context length Ollama loads the model with when
+// LLMConfig.NumCtx is 0.
+const DefaultNumCtx = 131072
+
+// Message is one chat message sent to the model.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LLMClient sends a chat conversation to a model and returns its reply. A reply
+// interrupted midway may be returned in part along with the error.
+type LLMClient interface {
+	Chat(ctx context.Context, messages []Message) (string, error)
+	Model() string
+}
+
+// HTTPStatusError is returned when the LLM server answers with a non-200 status.
+type HTTPStatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// LLMConfig selects and configures the LLM client.
+type LLMConfig struct {
+	Provider    string
+	Host        string
+	Model       string
+	APIKey      string
+	Temperature float64
+	// NumCtx is the context length Ollama loads the model with, 0 for
+	// DefaultNumCtx. OpenAI-compatible servers fix it when they start (llama.cpp
+	// -c, vLLM --max-model-len), so the openai provider rejects it.
+	NumCtx int
+	// Timeout limits a request; for streamed responses it limits the wait for
+	// the next chunk instead, so that long generations are not cut off.
+	Timeout time.Duration
+	// Stream makes the Ollama client stream the reply, see OllamaClient.Chat.
+	Stream bool
+	// FakeDir holds the canned responses of the fake provider.
+	FakeDir string
+}
+
+// LLMConfigFromEnv returns the configuration given by the environment:
+//
+//	LLM_PROVIDER        ollama (default), openai or fake
+//	LLM_HOST            server URL, OLLAMA_HOST is used as fallback
+//	LLM_MODEL           model name, OLLAMA_MODEL is used as fallback
+//	LLM_API_KEY         bearer token for OpenAI-compatible servers, OPENAI_API_KEY is used as fallback
+//	LLM_TEMPERATURE     sampling temperature (default 0)
+//	LLM_NUM_CTX         context length in tokens, Ollama only (default 131072)
+//	LLM_TIMEOUT         request timeout, or time without a new chunk when streaming (default 5m)
+//	LLM_STREAM          stream Ollama replies (default true)
+//	LLM_FAKE_DIR        directory with canned responses for the fake provider
+func LLMConfigFromEnv() LLMConfig {
+	cfg := LLMConfig{
+		Provider:    firstEnv("LLM_PROVIDER"),
+		Host:        firstEnv("LLM_HOST", "OLLAMA_HOST"),
+		Model:       firstEnv("LLM_MODEL", "OLLAMA_MODEL"),
+		APIKey:      firstEnv("LLM_API_KEY", "OPENAI_API_KEY"),
+		Temperature: 0.0,
+		Timeout:     5 * time.Minute,
+		Stream:      !strings.EqualFold(os.Getenv("LLM_STREAM"), "false"),
+		FakeDir:     firstEnv("LLM_FAKE_DIR"),
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOllama
+	}
+	if cfg.Model == "" {
+		cfg.Model = ctestglobals.OllamaModelDefault
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("LLM_TEMPERATURE"), 64); err == nil {
+		cfg.Temperature = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("LLM_NUM_CTX")); err == nil {
+		cfg.NumCtx = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LLM_TIMEOUT")); err == nil {
+		cfg.Timeout = v
+	}
+	return cfg
+}
+
+// AddFlags registers flags that override the configuration, e.g.
+// go test ./test/ctest/test_rewrite -run TestRewriteWithLLM -args -llm-provider=openai
+func (c *LLMConfig) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Provider, "llm-provider", c.Provider, "LLM provider: ollama, openai or fake")
+	fs.StringVar(&c.Host, "llm-host", c.Host, "LLM server URL")
+	fs.StringVar(&c.Model, "llm-model", c.Model, "model name")
+	fs.Float64Var(&c.Temperature, "llm-temperature", c.Temperature, "sampling temperature")
+	fs.IntVar(&c.NumCtx, "llm-num-ctx", c.NumCtx, "context length in tokens, Ollama only (0: 131072)")
+	fs.DurationVar(&c.Timeout, "llm-timeout", c.Timeout, "request timeout, or time without a new chunk when streaming")
+	fs.BoolVar(&c.Stream, "llm-stream", c.Stream, "stream Ollama replies")
+	fs.StringVar(&c.FakeDir, "llm-fake-dir", c.FakeDir, "directory with canned responses for the fake provider")
+}
+
+// NewLLMClient returns the client for the configured provider.
+func NewLLMClient(cfg LLMConfig) (LLMClient, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case ProviderOllama, "":
+		return NewOllamaClient(cfg), nil
+	case ProviderOpenAI:
+		if cfg.NumCtx != 0 {
+			return nil, fmt.Errorf("the context length of an OpenAI-compatible server is set when it starts, not per request (LLM_NUM_CTX=%d)", cfg.NumCtx)
+		}
+		return NewOpenAIClient(cfg), nil
+	case ProviderFake:
+		if cfg.FakeDir == "" {
+			return nil, fmt.Errorf("fake provider needs a response directory (LLM_FAKE_DIR)")
+		}
+		return NewFakeClient(cfg.FakeDir, cfg.Model), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
+
+// RewriteMessages returns the conversation sent for one rewrite with the built-in
+// templates: the system instructions, the few-shot examples and the prompt.
+func RewriteMessages(prompt string) ([]Message, error) {
+	return DefaultPrompts().Messages(prompt)
+}
+
+// CallLLM sends the rewrite prompt to client and returns the rewritten code, or
+// "NONE" when the model has nothing to rewrite. An interrupted reply is returned
+// in part with the error.
+func CallLLM(ctx context.Context, client LLMClient, prompt string) (string, error) {
+	messages, err := RewriteMessages(prompt)
+	if err != nil {
+		return "", err
+	}
+	out, err := client.Chat(ctx, messages)
+	if err != nil {
+		return out, err
+	}
+	if strings.TrimSpace(out) == "" || strings.TrimSpace(out) == "NONE" {
+		return "NONE", nil
+	}
+	return out, nil
+}
+
+//------------------------------------------------
+// Ollama
+//------------------------------------------------
+
+// OllamaClient talks to the Ollama /api/chat endpoint.
+type OllamaClient struct {
+	cfg        LLMConfig
+	httpClient *http.Client
+}
+
+func NewOllamaClient(cfg LLMConfig) *OllamaClient {
+	cfg.Host = normalizeHost(cfg.Host, "http://localhost:11434")
+	if cfg.NumCtx <= 0 {
+		cfg.NumCtx = DefaultNumCtx
+	}
+	timeout := cfg.Timeout
+	if cfg.Stream {
+		// chatStream enforces the timeout per chunk
+		timeout = 0
+	}
+	return &OllamaClient{cfg: cfg, httpClient: &http.Client{Timeout: timeout}}
+}
+
+func (c *OllamaClient) Model() string { return c.cfg.Model }
+
+// Chat sends messages to /api/chat. With cfg.Stream the reply is accumulated
+// from the streamed chunks, see chatStream. The request is recorded in the
+// ChatRecorder of ctx.
+func (c *OllamaClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	payload := map[string]interface{}{
+		"model":    c.cfg.Model,
+		"messages": messages,
+		"options": map[string]interface{}{
+			"temperature": c.cfg.Temperature,
+			"num_ctx":     c.cfg.NumCtx,
+		},
+		"stream": c.cfg.Stream,
+	}
+
+	url := strings.TrimRight(c.cfg.Host, "/") + "/api/chat"
+	if c.cfg.Stream {
+		return c.chatStream(ctx, url, payload)
+	}
+
+	start := time.Now()
+	respBytes, err := postJSON(ctx, c.httpClient, ProviderOllama, url, "", payload)
+	if err != nil {
+		return "", err
+	}
+
+	var ollamaResp OllamaResponse
+	if err := json.Unmarshal(respBytes, &ollamaResp); err != nil {
+		return "", fmt.Errorf("failed to parse Ollama response: %w", err)
+	}
+	chatRecorderFrom(ctx).add(ollamaResp.stats(0, time.Since(start)))
+	return ollamaResp.Message.Content, nil
+}
+
+//------------------------------------------------
+// OpenAI-compatible (llama.cpp server, vLLM, LM Studio, ...)
+//------------------------------------------------
+
+// OpenAIClient talks to an OpenAI-compatible /v1/chat/completions endpoint. It
+// does not send cfg.NumCtx, see LLMConfig.
+type OpenAIClient struct {
+	cfg        LLMConfig
+	httpClient *http.Client
+}
+
+func NewOpenAIClient(cfg LLMConfig) *OpenAIClient {
+	cfg.Host = normalizeHost(cfg.Host, "http://localhost:8080")
+	return &OpenAIClient{cfg: cfg, httpClient: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (c *OpenAIClient) Model() string { return c.cfg.Model }
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+func (c *OpenAIClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	payload := map[string]interface{}{
+		"model":       c.cfg.Model,
+		"messages":    messages,
+		"temperature": c.cfg.Temperature,
+		"stream":      false,
+	}
+
+	url := strings.TrimRight(c.cfg.Host, "/")
+	if !strings.HasSuffix(url, "/v1") {
+		url += "/v1"
+	}
+	start := time.Now()
+	respBytes, err := postJSON(ctx, c.httpClient, ProviderOpenAI, url+"/chat/completions", c.cfg.APIKey, payload)
+	if err != nil {
+		return "", err
+	}
+
+	var resp openAIResponse
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse chat completion response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("chat completion response has no choices")
+	}
+	chatRecorderFrom(ctx).add(ChatStats{Requests: 1, Duration: time.Since(start), DoneReason: resp.Choices[0].FinishReason})
+	return resp.Choices[0].Message.Content, nil
+}
+
+//------------------------------------------------
+// Fake
+//------------------------------------------------
+
+// FakeClient answers from files, for tests and for replaying recorded runs. The
+// reply to a conversation is read from <dir>/<hash>.txt, where hash is
+// FakeResponseKey of the last message, falling back to <dir>/default.txt. When
+// neither exists the reply is "NONE".
+type FakeClient struct {
+	dir   string
+	model string
+}
+
+func NewFakeClient(dir, model string) *FakeClient {
+	return &FakeClient{dir: dir, model: model}
+}
+
+func (c *FakeClient) Model() string { return c.model }
+
+func (c *FakeClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var last string
+	if len(messages) > 0 {
+		last = messages[len(messages)-1].Content
+	}
+	for _, name := range []string{FakeResponseKey(last) + ".txt", "default.txt"} {
+		data, err := os.ReadFile(filepath.Join(c.dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read fake response: %w", err)
+		}
+	}
+	return "NONE", nil
+}
+
+// FakeResponseKey returns the file name (without extension) under which the fake
+// client looks up the reply to a prompt.
+func FakeResponseKey(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+//------------------------------------------------
+// Helpers
+//------------------------------------------------
+
+func postJSON(ctx context.Context, client *http.Client, provider, url, apiKey string, payload interface{}) ([]byte, error) {
+	body, err := openJSON(ctx, client, provider, url, apiKey, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	respBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", provider, err)
+	}
+	return respBytes, nil
+}
+
+// openJSON posts payload and returns the body of a 200 response for the caller
+// to read and close.
+func openJSON(ctx context.Context, client *http.Client, provider, url, apiKey string, payload interface{}) (io.ReadCloser, error) {
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s API: %w", provider, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBytes, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPStatusError{Provider: provider, StatusCode: resp.StatusCode, Body: string(respBytes)}
+	}
+	return resp.Body, nil
+}
+
+// normalizeHost accepts OLLAMA_HOST style values such as "127.0.0.1:11434".
+func normalizeHost(host, defaultHost string) string {
+	if host == "" {
+		return defaultHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimRight(host, "/")
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
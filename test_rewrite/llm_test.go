@@ -0,0 +1,193 @@
+package testrewrite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rewriteMessages returns RewriteMessages(prompt) and fails t on an error.
+func rewriteMessages(t *testing.T, prompt string) []Message {
+	t.Helper()
+	messages, err := RewriteMessages(prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestOllamaClient(t *testing.T) {
+	var got struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+		Options  struct {
+			Temperature float64 `json:"temperature"`
+			NumCtx      int     `json:"num_ctx"`
+		} `json:"options"`
+		Stream bool `json:"stream"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"package foo"},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(LLMConfig{Host: server.URL, Model: "m", Temperature: 0.2, NumCtx: 4096})
+	out, err := CallLLM(context.Background(), client, "rewrite me")
+	if err != nil {
+		t.Fatalf("CallLLM failed: %v", err)
+	}
+	if out != "package foo" {
+		t.Errorf("unexpected reply %q", out)
+	}
+	if got.Model != "m" || got.Options.Temperature != 0.2 || got.Options.NumCtx != 4096 || got.Stream {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+	if n := len(got.Messages); n == 0 || got.Messages[n-1].Content != "rewrite me" {
+		t.Errorf("prompt is not the last message: %+v", got.Messages)
+	}
+}
+
+func TestOllamaClientStream(t *testing.T) {
+	var stalling atomic.Bool
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("expected a streaming request, got %+v, %v", req, err)
+		}
+		for _, part := range []string{"package ", "foo", "\n"} {
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":false}`+"\n", part)
+			w.(http.Flusher).Flush()
+		}
+		if stalling.Load() {
+			<-stall
+			return
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","total_duration":2000000,"prompt_eval_count":7,"eval_count":3}`)
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	var progress []int
+	ctx, rec := WithChatStats(context.Background(), func(tokens int) { progress = append(progress, tokens) })
+	client := NewOllamaClient(LLMConfig{Host: server.URL, Model: "m", Stream: true, Timeout: time.Minute})
+	out, err := client.Chat(ctx, rewriteMessages(t, "x"))
+	if err != nil || out != "package foo\n" {
+		t.Fatalf("Chat = %q, %v", out, err)
+	}
+	if !reflect.DeepEqual(progress, []int{1, 2, 3}) {
+		t.Errorf("progress = %v", progress)
+	}
+	want := ChatStats{Requests: 1, PromptTokens: 7, Tokens: 3, Duration: 2 * time.Millisecond, DoneReason: "stop"}
+	if got := rec.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+
+	stalling.Store(true)
+	impatient := NewOllamaClient(LLMConfig{Host: server.URL, Stream: true, Timeout: 50 * time.Millisecond})
+	out, err = impatient.Chat(ctx, rewriteMessages(t, "x"))
+	var stalled *StreamStalledError
+	if !errors.As(err, &stalled) || !isRetryable(err) {
+		t.Errorf("expected a retryable stall error, got %v", err)
+	}
+	if out != "package foo\n" {
+		t.Errorf("stalled stream lost the partial reply, got %q", out)
+	}
+	if got := rec.Stats(); got.Requests != 2 || got.Tokens != 6 || got.DoneReason != "interrupted" {
+		t.Errorf("interrupted request not recorded: %+v", got)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.Chat(canceled, rewriteMessages(t, "x")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOpenAIClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("unexpected Authorization header %q", auth)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"NONE"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(LLMConfig{Host: server.URL, Model: "m", APIKey: "secret"})
+	out, err := CallLLM(context.Background(), client, "rewrite me")
+	if err != nil {
+		t.Fatalf("CallLLM failed: %v", err)
+	}
+	if out != "NONE" {
+		t.Errorf("unexpected reply %q", out)
+	}
+
+	// The server decides the context length
+	if _, err := NewLLMClient(LLMConfig{Provider: ProviderOpenAI, Host: server.URL, NumCtx: 4096}); err == nil {
+		t.Errorf("expected NumCtx to be rejected for %s", ProviderOpenAI)
+	}
+}
+
+func TestLLMClientHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	for _, provider := range []string{ProviderOllama, ProviderOpenAI} {
+		client, err := NewLLMClient(LLMConfig{Provider: provider, Host: server.URL})
+		if err != nil {
+			t.Fatalf("NewLLMClient(%s) failed: %v", provider, err)
+		}
+		_, err = client.Chat(context.Background(), rewriteMessages(t, "x"))
+		var statusErr *HTTPStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("%s: expected HTTP 429 error, got %v", provider, err)
+		}
+	}
+}
+
+func TestFakeClient(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FakeResponseKey("known prompt")+".txt"), []byte("package known"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewLLMClient(LLMConfig{Provider: ProviderFake, FakeDir: dir})
+	if err != nil {
+		t.Fatalf("NewLLMClient failed: %v", err)
+	}
+	if out, err := CallLLM(context.Background(), client, "known prompt"); err != nil || out != "package known" {
+		t.Errorf("known prompt: got %q, %v", out, err)
+	}
+	if out, err := CallLLM(context.Background(), client, "other prompt"); err != nil || out != "NONE" {
+		t.Errorf("unknown prompt: got %q, %v", out, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "default.txt"), []byte("package fallback"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := CallLLM(context.Background(), client, "other prompt"); err != nil || out != "package fallback" {
+		t.Errorf("default response: got %q, %v", out, err)
+	}
+}
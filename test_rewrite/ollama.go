@@ -1,13 +1,13 @@
 package testrewrite
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
-	"net/http"
+	"io"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -21,95 +21,117 @@ type OllamaResponse struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
 	} `json:"message"`
-	Thinking      string `json:"thinking"`
-	Done          bool   `json:"done"`
-	DoneReason    string `json:"done_reason"`
-	TotalDuration int64  `json:"total_duration"`
+	Thinking        string `json:"thinking"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	TotalDuration   int64  `json:"total_duration"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	// Error is set by the server when a streamed generation fails midway
+	Error string `json:"error"`
 }
 
-// CallOllama sends the prompt to Ollama API and returns the rewritten code
-func CallOllama(prompt string) (string, error) {
-	model := os.Getenv("OLLAMA_MODEL")
-	if model == "" {
-		model = ctestglobals.OllamaModelDefault
+// stats returns the metrics of a final response. chunks and elapsed stand in for
+// eval_count and total_duration when the server leaves them out.
+func (r OllamaResponse) stats(chunks int, elapsed time.Duration) ChatStats {
+	s := ChatStats{Requests: 1, PromptTokens: r.PromptEvalCount, Tokens: r.EvalCount, Duration: time.Duration(r.TotalDuration), DoneReason: r.DoneReason}
+	if s.Tokens == 0 {
+		s.Tokens = chunks
 	}
-
-	url := "http://localhost:11434/api/chat"
-
-	payload := map[string]interface{}{
-		"model": model,
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": "You are an expert Go developer rewriting Kubernetes e2e tests for dynamic configuration. Follow user instructions strictly. Output only Go code or NONE.",
-			},
-			{"role": "user", "content": OneShotUserExample},
-			{"role": "assistant", "content": OneShotAssistantExample},
-			{"role": "user", "content": OneShotUserExample2},
-			{"role": "assistant", "content": OneShotAssistantExample2},
-			{"role": "user", "content": OneShotUserExample3},
-			{"role": "assistant", "content": OneShotAssistantExample3},
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"options": map[string]interface{}{
-			"temperature": 0.0,
-			"num_ctx":     131072,
-		},
-		"stream": false,
-	}
-
-	bodyBytes, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
-	}
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
+	if s.Duration == 0 {
+		s.Duration = elapsed
 	}
+	return s
+}
 
-	// 🚦 Rate-limit Ollama calls
-	defer time.Sleep(ollamaSleepDuration())
+// StreamStalledError is returned when a streamed reply sends no chunk within
+// the client timeout. Like a request timeout it is retried.
+type StreamStalledError struct {
+	// After is the timeout that expired
+	After time.Duration
+}
 
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(bodyBytes))
+func (e *StreamStalledError) Error() string {
+	return fmt.Sprintf("no response chunk within %s", e.After)
+}
 
-	// resp, err := http.Post(url, "application/json", bytes.NewReader(bodyBytes))
-	if err != nil {
-		return "", fmt.Errorf("failed to call Ollama API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// ✅ Handle non-200 HTTP codes
-	if resp.StatusCode != http.StatusOK {
-		errBody, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf(
-			"ollama returned HTTP %d: %s",
-			resp.StatusCode,
-			string(errBody),
-		)
+// Timeout and Temporary make StreamStalledError a net.Error, see isRetryable.
+func (e *StreamStalledError) Timeout() bool   { return true }
+func (e *StreamStalledError) Temporary() bool { return true }
+
+// chatStream posts payload with streaming on and accumulates the content of the
+// chunks, reporting the tokens received so far to the ChatRecorder of ctx. The
+// request is canceled with ctx or when no chunk arrives within cfg.Timeout; the
+// content received until then is returned with the error.
+func (c *OllamaClient) chatStream(ctx context.Context, url string, payload map[string]interface{}) (string, error) {
+	start := time.Now()
+	rec := chatRecorderFrom(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var idle *time.Timer
+	if c.cfg.Timeout > 0 {
+		idle = time.AfterFunc(c.cfg.Timeout, func() { cancel(&StreamStalledError{After: c.cfg.Timeout}) })
+		defer idle.Stop()
 	}
 
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	// fmt.Println("Ollama response:")
-	// fmt.Println(string(respBytes))
+	body, err := openJSON(ctx, c.httpClient, ProviderOllama, url, "", payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to read Ollama response: %w", err)
+		return "", streamCause(ctx, err)
 	}
+	defer body.Close()
+
+	var out strings.Builder
+	chunks := 0
+	dec := json.NewDecoder(body)
+	for {
+		var chunk OllamaResponse
+		err := dec.Decode(&chunk)
+		if err == nil && chunk.Error != "" {
+			err = errors.New(chunk.Error)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			rec.add(ChatStats{Requests: 1, Tokens: chunks, Duration: time.Since(start), DoneReason: "interrupted"})
+			return out.String(), fmt.Errorf("ollama stream interrupted after %d tokens: %w", chunks, streamCause(ctx, err))
+		}
+		if idle != nil {
+			idle.Reset(c.cfg.Timeout)
+		}
 
-	var ollamaResp OllamaResponse
-	if err := json.Unmarshal(respBytes, &ollamaResp); err != nil {
-		return "", fmt.Errorf("failed to parse Ollama response: %w", err)
+		out.WriteString(chunk.Message.Content)
+		if chunk.Message.Content != "" {
+			chunks++
+			rec.progress(chunks)
+		}
+		if chunk.Done {
+			rec.add(chunk.stats(chunks, time.Since(start)))
+			return out.String(), nil
+		}
 	}
+}
 
-	outTrim := ollamaResp.Message.Content
-	// fmt.Println("Ollama output:")
-	// fmt.Println(outTrim)
-	if outTrim == "" || outTrim == "NONE" {
-		return "NONE", nil
-	}
+// CallOllama sends the prompt to the Ollama API and returns the rewritten code.
+// Host, model and sampling options are taken from the environment, see
+// LLMConfigFromEnv; use NewLLMClient and CallLLM for other providers.
+func CallOllama(prompt string) (string, error) {
+	cfg := LLMConfigFromEnv()
+	cfg.Provider = ProviderOllama
 
-	return outTrim, nil
+	// 🚦 Rate-limit Ollama calls
+	defer time.Sleep(ollamaSleepDuration())
+
+	return CallLLM(context.Background(), NewOllamaClient(cfg), prompt)
+}
+
+// streamCause returns why ctx was canceled, which is more telling than the
+// error of the interrupted read, or err when ctx is still live.
+func streamCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	return err
 }
 
 func ollamaSleepDuration() time.Duration {
@@ -0,0 +1,105 @@
+package testrewrite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// OverlayFile is the name of the go build -overlay file kept in an overlay
+// directory. It maps the in-tree path of every rewritten file to its copy in
+// the overlay, so that the rewrites can be tested without touching the tree:
+//
+//	go test -overlay=<dir>/overlay.json ./test/e2e/...
+const OverlayFile = "overlay.json"
+
+// overlayJSON is the format read by go build -overlay.
+type overlayJSON struct {
+	Replace map[string]string
+}
+
+// overlayPath is where newFile, a ctest_ file next to its original, is written
+// in overlayDir: under its path relative to root.
+func overlayPath(overlayDir, root, newFile string) string {
+	rel, err := filepath.Rel(root, newFile)
+	if root == "" || err != nil || strings.HasPrefix(rel, "..") {
+		rel = strings.TrimPrefix(newFile, filepath.VolumeName(newFile))
+	}
+	return filepath.Join(overlayDir, rel)
+}
+
+// UpdateOverlay adds replace (in-tree path to overlay path) to the overlay file
+// of overlayDir. An empty overlay path removes the entry.
+func UpdateOverlay(overlayDir string, replace map[string]string) error {
+	path := filepath.Join(overlayDir, OverlayFile)
+	overlay := overlayJSON{Replace: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &overlay); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if overlay.Replace == nil {
+			overlay.Replace = make(map[string]string)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	for from, to := range replace {
+		if to == "" {
+			delete(overlay.Replace, from)
+			continue
+		}
+		if overlay.Replace[from], err = filepath.Abs(to); err != nil {
+			return err
+		}
+	}
+	if data, err = json.MarshalIndent(overlay, "", "  "); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(overlayDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// OverlayResults returns a result for every rewrite recorded in the overlay
+// file of overlayDir, to review them without touching the tree.
+func OverlayResults(overlayDir string) ([]FileResult, error) {
+	path := filepath.Join(overlayDir, OverlayFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var overlay overlayJSON
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var results []FileResult
+	for newFile, output := range overlay.Replace {
+		results = append(results, FileResult{File: walker.OriginalOf(newFile), NewFile: newFile, Output: output, Status: StatusRewritten})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].NewFile < results[j].NewFile })
+	return results, nil
+}
+
+// unifiedDiff returns the unified diff turning a into b.
+func unifiedDiff(aName, bName, a, b string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: aName,
+		ToFile:   bName,
+		Context:  3,
+	})
+}
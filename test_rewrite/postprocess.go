@@ -0,0 +1,387 @@
+package testrewrite
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
+)
+
+// ctestImports are the packages the rewritten tests are expected to use, by the
+// name they are referred to in the prompt.
+var ctestImports = map[string]string{
+	"ctest":        "k8s.io/kubernetes/test/ctest",
+	"ctestglobals": "k8s.io/kubernetes/test/ctest/ctestglobals",
+	"fixtures":     "k8s.io/kubernetes/test/ctest/fixtures",
+}
+
+// PostProcessReport lists what PostProcess changed in a rewritten file.
+type PostProcessReport struct {
+	Package      string
+	Renamed      []string
+	Removed      []string
+	Collisions   []string
+	AddedImports []string
+	// K8sObjects lists the entries replaced by their fixture key, UnknownK8sObjects
+	// the ones that match no kind
+	K8sObjects        []string
+	UnknownK8sObjects []string
+}
+
+// Changed reports whether PostProcess had to fix anything.
+func (r *PostProcessReport) Changed() bool {
+	return r.Package != "" || len(r.Renamed) > 0 || len(r.Removed) > 0 || len(r.Collisions) > 0 || len(r.AddedImports) > 0 ||
+		len(r.K8sObjects) > 0 || len(r.UnknownK8sObjects) > 0
+}
+
+func (r *PostProcessReport) String() string {
+	var parts []string
+	if r.Package != "" {
+		parts = append(parts, "package clause set to "+r.Package)
+	}
+	if len(r.Renamed) > 0 {
+		parts = append(parts, "renamed "+strings.Join(r.Renamed, ", "))
+	}
+	if len(r.Removed) > 0 {
+		parts = append(parts, "removed unchanged "+strings.Join(r.Removed, ", "))
+	}
+	if len(r.Collisions) > 0 {
+		parts = append(parts, "collisions "+strings.Join(r.Collisions, ", "))
+	}
+	if len(r.AddedImports) > 0 {
+		parts = append(parts, "added imports "+strings.Join(r.AddedImports, ", "))
+	}
+	if len(r.K8sObjects) > 0 {
+		parts = append(parts, "K8sObjects "+strings.Join(r.K8sObjects, ", "))
+	}
+	if len(r.UnknownK8sObjects) > 0 {
+		parts = append(parts, "unknown K8sObjects "+strings.Join(r.UnknownK8sObjects, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// PostProcess enforces the rules the prompt gives the model on a rewritten file:
+//   - the package clause is the one of the original file,
+//   - go test functions are renamed from TestXYZ to TestCtestXYZ,
+//   - functions identical to ones in the original file are deleted,
+//   - helper functions that collide with a declaration of the original package are
+//     renamed with the file name appended; other colliding declarations are
+//     reported,
+//   - K8sObjects entries are replaced by their fixture key ("configmaps" ->
+//     "configMaps"),
+//   - missing ctest imports are added and imports are fixed goimports-style.
+func PostProcess(originalFile, newFile string, src []byte) ([]byte, *PostProcessReport, error) {
+	report := &PostProcessReport{}
+
+	origSrc, err := os.ReadFile(originalFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read original file: %w", err)
+	}
+	fset := token.NewFileSet()
+	orig, err := parser.ParseFile(fset, originalFile, origSrc, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse original file: %w", err)
+	}
+	f, err := parser.ParseFile(fset, newFile, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse rewritten file: %w", err)
+	}
+
+	// Package clause
+	if f.Name.Name != orig.Name.Name {
+		report.Package = orig.Name.Name
+		f.Name.Name = orig.Name.Name
+	}
+
+	// Functions copied unchanged from the original file
+	origFuncs := make(map[string]string)
+	for _, decl := range orig.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			origFuncs[funcKey(fn)] = nodeString(fset, fn)
+		}
+	}
+	var decls []ast.Decl
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			if body, found := origFuncs[funcKey(fn)]; found && body == nodeString(fset, fn) {
+				report.Removed = append(report.Removed, funcKey(fn))
+				removeComments(f, fn)
+				continue
+			}
+		}
+		decls = append(decls, decl)
+	}
+	f.Decls = decls
+
+	// Go test functions
+	renames := make(map[string]string)
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !isGoTestFunc(fn) || strings.HasPrefix(fn.Name.Name, "TestCtest") {
+			continue
+		}
+		renames[fn.Name.Name] = "TestCtest" + strings.TrimPrefix(fn.Name.Name, "Test")
+	}
+
+	// Collisions with the rest of the package
+	pkgDecls, err := packageDecls(filepath.Dir(newFile), f.Name.Name, newFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	suffix := fileSuffix(originalFile)
+	for _, name := range topLevelNames(f) {
+		if renamed, ok := renames[name]; ok {
+			name = renamed
+		}
+		if !pkgDecls[name] {
+			continue
+		}
+		if isTopLevelFunc(f, name) && !strings.HasPrefix(name, "Test") {
+			renames[name] = name + suffix
+			continue
+		}
+		report.Collisions = append(report.Collisions, name)
+	}
+	for from, to := range renames {
+		report.Renamed = append(report.Renamed, from+" -> "+to)
+	}
+	sort.Strings(report.Renamed)
+	renameIdents(f, renames)
+
+	report.K8sObjects, report.UnknownK8sObjects = fixK8sObjects(f)
+
+	// ctest imports the model forgot
+	imported := make(map[string]bool)
+	for _, imp := range f.Imports {
+		imported[strings.Trim(imp.Path.Value, `"`)] = true
+	}
+	for _, name := range unresolvedPackages(f) {
+		path, ok := ctestImports[name]
+		if !ok || imported[path] {
+			continue
+		}
+		astutil.AddNamedImport(fset, f, name, path)
+		imported[path] = true
+		report.AddedImports = append(report.AddedImports, path)
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f); err != nil {
+		return nil, nil, fmt.Errorf("failed to print rewritten file: %w", err)
+	}
+	out, err := imports.Process(newFile, buf.Bytes(), &imports.Options{Comments: true, TabIndent: true, TabWidth: 8})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fix imports: %w", err)
+	}
+	return out, report, nil
+}
+
+func funcKey(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	return fmt.Sprintf("(%s).%s", recvTypeName(fn.Recv.List[0].Type), fn.Name.Name)
+}
+
+func recvTypeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + recvTypeName(e.X)
+	case *ast.Ident:
+		return e.Name
+	case *ast.IndexExpr:
+		return recvTypeName(e.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(e.X)
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// nodeString prints a function without its doc comment, so a copy that only lost
+// or gained a comment still counts as unchanged.
+func nodeString(fset *token.FileSet, fn *ast.FuncDecl) string {
+	stripped := *fn
+	stripped.Doc = nil
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, &stripped)
+	return buf.String()
+}
+
+// removeComments drops the comments of a deleted declaration, which the printer
+// would otherwise keep in place.
+func removeComments(f *ast.File, fn *ast.FuncDecl) {
+	start := fn.Pos()
+	if fn.Doc != nil {
+		start = fn.Doc.Pos()
+	}
+	var kept []*ast.CommentGroup
+	for _, cg := range f.Comments {
+		if cg.Pos() >= start && cg.End() <= fn.End() {
+			continue
+		}
+		kept = append(kept, cg)
+	}
+	f.Comments = kept
+}
+
+// isGoTestFunc reports whether fn looks like func TestXxx(t *testing.T).
+func isGoTestFunc(fn *ast.FuncDecl) bool {
+	name := fn.Name.Name
+	if !strings.HasPrefix(name, "Test") || len(name) == len("Test") {
+		return false
+	}
+	if r := rune(name[len("Test")]); !unicode.IsUpper(r) && r != '_' {
+		return false
+	}
+	params := fn.Type.Params.List
+	if len(params) != 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "T"
+}
+
+func isTopLevelFunc(f *ast.File, name string) bool {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelNames returns the package-level names declared by f. Methods are left
+// out, they cannot collide with package-level declarations.
+func topLevelNames(f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name != "init" && d.Name.Name != "_" {
+				names = append(names, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.Name != "_" {
+							names = append(names, n.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// packageDecls collects the package-level names declared by the other files of
+// package pkgName in dir.
+func packageDecls(dir, pkgName, skipFile string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package dir: %w", err)
+	}
+	decls := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || filepath.Clean(path) == filepath.Clean(skipFile) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil || f.Name.Name != pkgName {
+			continue
+		}
+		for _, name := range topLevelNames(f) {
+			decls[name] = true
+		}
+	}
+	return decls, nil
+}
+
+// renameIdents renames declarations and their uses within f. Selectors are left
+// alone, x.Name refers to another package or a field.
+func renameIdents(f *ast.File, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(x.X, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if to, found := renames[id.Name]; found {
+						id.Name = to
+					}
+				}
+				return true
+			})
+			return false
+		case *ast.Ident:
+			if to, found := renames[x.Name]; found {
+				x.Name = to
+			}
+		}
+		return true
+	})
+}
+
+// unresolvedPackages returns the names used as pkg.Sel that are neither imported
+// nor declared in f.
+func unresolvedPackages(f *ast.File) []string {
+	imported := make(map[string]bool)
+	for _, imp := range f.Imports {
+		if imp.Name != nil {
+			imported[imp.Name.Name] = true
+			continue
+		}
+		path := strings.Trim(imp.Path.Value, `"`)
+		imported[path[strings.LastIndex(path, "/")+1:]] = true
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if ok && id.Obj == nil && !imported[id.Name] && !seen[id.Name] {
+			seen[id.Name] = true
+			names = append(names, id.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// fileSuffix turns "pod_resize_test.go" into "PodResize", the suffix the prompt
+// asks the model to append to new helper names.
+func fileSuffix(file string) string {
+	base := strings.TrimSuffix(filepath.Base(file), ".go")
+	base = strings.TrimSuffix(base, "_test")
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(base, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
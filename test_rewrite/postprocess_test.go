@@ -0,0 +1,71 @@
+package testrewrite
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPostProcess(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "pod_resize_test.go")
+	originalSrc := `package node
+
+import "testing"
+
+// helper is shared by the tests in this file.
+func helper() int { return 1 }
+
+func newPod() string { return "pod" }
+
+func TestResize(t *testing.T) {
+	_ = helper()
+}
+`
+	if err := os.WriteFile(original, []byte(originalSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten := "package wrong\n\n" + `import "testing"
+
+// helper is shared by the tests in this file.
+func helper() int { return 1 }
+
+func newPod() string { return "ctest-pod" }
+
+func TestResize(t *testing.T) {
+	_ = helper()
+	_ = newPod()
+	_ = ctestglobals.DebugPrefix
+	_ = ctestglobals.HardcodedConfig{{K8sObjects: []string{"pods", "configmaps", "ingressws"}}}
+}
+`
+	out, report, err := PostProcess(original, filepath.Join(dir, "ctest_pod_resize_test.go"), []byte(rewritten))
+	if err != nil {
+		t.Fatalf("PostProcess failed: %v", err)
+	}
+	src := string(out)
+
+	if report.Package != "node" || !strings.HasPrefix(src, "package node") {
+		t.Errorf("package clause not fixed:\n%s", src)
+	}
+	if strings.Contains(src, "func helper()") || strings.Contains(src, "shared by the tests") {
+		t.Errorf("unchanged helper was not removed:\n%s", src)
+	}
+	if !strings.Contains(src, "func TestCtestResize(t *testing.T)") {
+		t.Errorf("test function not renamed:\n%s", src)
+	}
+	if !strings.Contains(src, "func newPodPodResize()") || !strings.Contains(src, "_ = newPodPodResize()") {
+		t.Errorf("colliding helper not renamed:\n%s", src)
+	}
+	if !strings.Contains(src, `ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"`) {
+		t.Errorf("ctestglobals import not added:\n%s", src)
+	}
+	if !strings.Contains(src, `[]string{"pods", "configMaps", "ingressws"}`) || len(report.K8sObjects) != 1 || len(report.UnknownK8sObjects) != 1 {
+		t.Errorf("K8sObjects not fixed (%s):\n%s", report, src)
+	}
+	if len(report.Removed) != 1 || len(report.Renamed) != 2 || len(report.AddedImports) != 1 {
+		t.Errorf("unexpected report: %s", report)
+	}
+}
@@ -0,0 +1,172 @@
+package testrewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SkipNoCandidates is the skip reason of files without anything to rewrite.
+const SkipNoCandidates = "no Kubernetes API config literals or test case tables"
+
+// configTypes are the Kubernetes API types whose literals hold configuration the
+// rewriter can make dynamic.
+var configTypes = map[string]bool{
+	"Pod": true, "PodSpec": true, "PodTemplateSpec": true, "PodTemplate": true,
+	"Container": true, "EphemeralContainer": true, "EphemeralContainerCommon": true,
+	"Probe": true, "ProbeHandler": true, "HTTPGetAction": true, "ExecAction": true, "TCPSocketAction": true, "GRPCAction": true,
+	"Lifecycle": true, "LifecycleHandler": true,
+	"SecurityContext": true, "PodSecurityContext": true, "Capabilities": true, "SELinuxOptions": true, "SeccompProfile": true, "Sysctl": true,
+	"ResourceRequirements": true, "ResourceList": true, "PodResourceClaim": true,
+	"Volume": true, "VolumeSource": true, "VolumeMount": true, "VolumeDevice": true,
+	"EnvVar": true, "EnvVarSource": true, "EnvFromSource": true, "ContainerPort": true,
+	"Toleration": true, "Affinity": true, "NodeAffinity": true, "PodAffinity": true, "PodAntiAffinity": true, "TopologySpreadConstraint": true,
+	"Service": true, "ServiceSpec": true, "ServicePort": true,
+	"ConfigMap": true, "Secret": true, "ServiceAccount": true,
+	"PersistentVolume": true, "PersistentVolumeSpec": true, "PersistentVolumeClaim": true, "PersistentVolumeClaimSpec": true,
+	"Deployment": true, "DeploymentSpec": true, "StatefulSet": true, "StatefulSetSpec": true,
+	"DaemonSet": true, "DaemonSetSpec": true, "ReplicaSet": true, "ReplicaSetSpec": true,
+	"Job": true, "JobSpec": true, "CronJob": true, "CronJobSpec": true,
+	"Ingress": true, "IngressSpec": true, "NetworkPolicy": true, "NetworkPolicySpec": true,
+	"ResourceQuota": true, "ResourceQuotaSpec": true, "LimitRange": true, "LimitRangeSpec": true,
+	"StorageClass": true, "PriorityClass": true,
+}
+
+// testCaseNames are the variable names used for table-driven tests.
+var testCaseNames = map[string]bool{
+	"testcases": true, "testCases": true, "tests": true, "cases": true, "tcs": true, "table": true,
+}
+
+// Candidate is a spot in a file the rewriter could turn into a dynamic config.
+type Candidate struct {
+	// Kind is "literal" for an API type literal or "testcases" for a test table
+	Kind string
+	// Type is the API type, or the variable name of the test table
+	Type string
+	Line int
+}
+
+func (c Candidate) String() string {
+	return fmt.Sprintf("%s %s at line %d", c.Kind, c.Type, c.Line)
+}
+
+// prefilterEnabled reads REWRITE_PREFILTER (default true).
+func prefilterEnabled() bool {
+	if v, err := strconv.ParseBool(os.Getenv("REWRITE_PREFILTER")); err == nil {
+		return v
+	}
+	return true
+}
+
+// FindCandidates looks for composite literals of Kubernetes API config types
+// (v1.PodSpec, v1.Container, v1.Probe, ...) and table-driven test cases in src.
+func FindCandidates(path string, src []byte) ([]Candidate, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// Names under which k8s.io/api packages are imported
+	apiPackages := make(map[string]bool)
+	for _, imp := range f.Imports {
+		importPath, _ := strconv.Unquote(imp.Path.Value)
+		if !strings.HasPrefix(importPath, "k8s.io/api/") {
+			continue
+		}
+		name := importPath[strings.LastIndex(importPath, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		apiPackages[name] = true
+	}
+
+	var candidates []Candidate
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CompositeLit:
+			if typeName := apiTypeName(x.Type, apiPackages); typeName != "" {
+				candidates = append(candidates, Candidate{Kind: "literal", Type: typeName, Line: fset.Position(x.Pos()).Line})
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range x.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && i < len(x.Rhs) && isTestTable(id.Name, x.Rhs[i]) {
+					candidates = append(candidates, Candidate{Kind: "testcases", Type: id.Name, Line: fset.Position(x.Pos()).Line})
+				}
+			}
+		case *ast.ValueSpec:
+			for i, id := range x.Names {
+				if i < len(x.Values) && isTestTable(id.Name, x.Values[i]) {
+					candidates = append(candidates, Candidate{Kind: "testcases", Type: id.Name, Line: fset.Position(x.Pos()).Line})
+				}
+			}
+		}
+		return true
+	})
+	return candidates, nil
+}
+
+// apiTypeName returns "pkg.Type" when expr is a config type of a k8s.io/api
+// package, looking through slices, maps and pointers.
+func apiTypeName(expr ast.Expr, apiPackages map[string]bool) string {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && apiPackages[pkg.Name] && configTypes[t.Sel.Name] {
+			return pkg.Name + "." + t.Sel.Name
+		}
+	case *ast.ArrayType:
+		return apiTypeName(t.Elt, apiPackages)
+	case *ast.MapType:
+		return apiTypeName(t.Value, apiPackages)
+	case *ast.StarExpr:
+		return apiTypeName(t.X, apiPackages)
+	}
+	return ""
+}
+
+// isTestTable reports whether name = value declares a table of test cases: a
+// slice or map of structs assigned to one of the usual names.
+func isTestTable(name string, value ast.Expr) bool {
+	if !testCaseNames[name] {
+		return false
+	}
+	lit, ok := value.(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	switch t := lit.Type.(type) {
+	case *ast.ArrayType:
+		return isStructType(t.Elt)
+	case *ast.MapType:
+		return isStructType(t.Value)
+	}
+	return false
+}
+
+func isStructType(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return true
+	case *ast.Ident:
+		// A named type declared for the table, e.g. []testCase
+		return types.Universe.Lookup(t.Name) == nil
+	case *ast.StarExpr:
+		return isStructType(t.X)
+	}
+	return false
+}
+
+// prefilter decides whether a file (or chunk) is worth sending to the model. It
+// returns an empty reason when it is; files that do not parse are always sent.
+func prefilter(path, src string) string {
+	candidates, err := FindCandidates(path, []byte(src))
+	if err != nil || len(candidates) > 0 {
+		return ""
+	}
+	return SkipNoCandidates
+}
@@ -0,0 +1,49 @@
+package testrewrite
+
+import "testing"
+
+func TestFindCandidates(t *testing.T) {
+	src := `package e2e
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProbe(t *testing.T) {
+	_ = metav1.ObjectMeta{Name: "x"}
+	_ = &v1.Probe{PeriodSeconds: 1}
+	_ = []v1.Container{{Name: "c"}}
+}
+
+func TestTable(t *testing.T) {
+	testcases := []struct{ in, out int }{{1, 2}}
+	tests := []string{"not", "a", "table"}
+	_, _ = testcases, tests
+}
+`
+	candidates, err := FindCandidates("probe_test.go", []byte(src))
+	if err != nil {
+		t.Fatalf("FindCandidates failed: %v", err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, c.Kind+" "+c.Type)
+	}
+	want := []string{"literal v1.Probe", "literal v1.Container", "testcases testcases"}
+	if len(got) != len(want) {
+		t.Fatalf("got candidates %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("candidate %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	plain := "package e2e\n\nimport \"fmt\"\n\nfunc helper() { fmt.Println(1) }\n"
+	if reason := prefilter("helper.go", plain); reason != SkipNoCandidates {
+		t.Errorf("expected file without candidates to be skipped, got reason %q", reason)
+	}
+}
@@ -0,0 +1,222 @@
+package testrewrite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// DefaultPromptVersion is the prompt used unless REWRITE_PROMPT_VERSION says
+// otherwise.
+const DefaultPromptVersion = "v1"
+
+// stampPrefix starts the header comment of every rewritten file.
+const stampPrefix = "// ctest rewrite:"
+
+// embeddedPrompts holds one directory per prompt version:
+//
+//	prompts/<version>/system.tmpl                  system message
+//	prompts/<version>/instructions.tmpl            rewrite instructions for one file
+//	prompts/<version>/examples/<name>.user.tmpl    few-shot request
+//	prompts/<version>/examples/<name>.assistant.tmpl  few-shot answer
+//
+// Templates use [[ ]] as delimiters, Go code is full of {{.
+//
+//go:embed prompts
+var embeddedPrompts embed.FS
+
+// PromptData is what the templates are executed with.
+type PromptData struct {
+	// FileName is the base name of the file being rewritten
+	FileName string
+	// Content is the source of the file, or of one test of it
+	Content string
+	Version string
+	// FixtureKeys are the valid K8sObjects entries, see ctestglobals.Kinds
+	FixtureKeys []string
+}
+
+// PromptSet is a loaded prompt version.
+type PromptSet struct {
+	Version string
+	// Hash identifies the exact templates, so that editing a template without
+	// bumping the version still shows in the stamp of the rewritten files.
+	Hash string
+
+	system       *template.Template
+	instructions *template.Template
+	examples     [][2]*template.Template
+}
+
+// LoadPromptSet loads the templates of version from fsys, which holds one
+// directory per version.
+func LoadPromptSet(fsys fs.FS, version string) (*PromptSet, error) {
+	p := &PromptSet{Version: version}
+	h := sha256.New()
+	parse := func(name string) (*template.Template, error) {
+		data, err := fs.ReadFile(fsys, path.Join(version, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt %s/%s: %w", version, name, err)
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", name, data)
+		t, err := template.New(name).Delims("[[", "]]").Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt %s/%s: %w", version, name, err)
+		}
+		return t, nil
+	}
+
+	var err error
+	if p.system, err = parse("system.tmpl"); err != nil {
+		return nil, err
+	}
+	if p.instructions, err = parse("instructions.tmpl"); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(fsys, path.Join(version, "examples"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list examples of prompt %s: %w", version, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".user.tmpl"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		user, err := parse(path.Join("examples", name+".user.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		assistant, err := parse(path.Join("examples", name+".assistant.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		p.examples = append(p.examples, [2]*template.Template{user, assistant})
+	}
+
+	p.Hash = hex.EncodeToString(h.Sum(nil))[:12]
+	return p, nil
+}
+
+// PromptSetFromEnv loads REWRITE_PROMPT_VERSION (default DefaultPromptVersion)
+// from REWRITE_PROMPT_DIR, or from the prompts built into the binary when it is
+// not set.
+func PromptSetFromEnv() (*PromptSet, error) {
+	version := os.Getenv("REWRITE_PROMPT_VERSION")
+	if version == "" {
+		version = DefaultPromptVersion
+	}
+	if dir := os.Getenv("REWRITE_PROMPT_DIR"); dir != "" {
+		return LoadPromptSet(os.DirFS(dir), version)
+	}
+	return LoadPromptSet(mustSub(embeddedPrompts, "prompts"), version)
+}
+
+var (
+	defaultPromptsOnce sync.Once
+	defaultPrompts     *PromptSet
+)
+
+// DefaultPrompts returns the built-in DefaultPromptVersion.
+func DefaultPrompts() *PromptSet {
+	defaultPromptsOnce.Do(func() {
+		p, err := LoadPromptSet(mustSub(embeddedPrompts, "prompts"), DefaultPromptVersion)
+		if err != nil {
+			panic(fmt.Sprintf("built-in prompt %s is broken: %v", DefaultPromptVersion, err))
+		}
+		defaultPrompts = p
+	})
+	return defaultPrompts
+}
+
+// Build renders the instructions for rewriting content, the source of path.
+func (p *PromptSet) Build(path, content string) (string, error) {
+	return p.execute(p.instructions, path, content)
+}
+
+// Messages returns the conversation sent for one rewrite: the system message,
+// the few-shot examples and the prompt.
+func (p *PromptSet) Messages(prompt string) ([]Message, error) {
+	system, err := p.execute(p.system, "", "")
+	if err != nil {
+		return nil, err
+	}
+	messages := []Message{{Role: "system", Content: system}}
+	for _, ex := range p.examples {
+		user, err := p.execute(ex[0], "", "")
+		if err != nil {
+			return nil, err
+		}
+		assistant, err := p.execute(ex[1], "", "")
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{Role: "user", Content: user}, Message{Role: "assistant", Content: assistant})
+	}
+	return append(messages, Message{Role: "user", Content: prompt}), nil
+}
+
+// Stamp is the header comment of a file rewritten with this prompt by model.
+func (p *PromptSet) Stamp(model string) string {
+	return fmt.Sprintf("%s prompt %s (sha256:%s), model %s", stampPrefix, p.Version, p.Hash, model)
+}
+
+// StampModel returns the model named in the stamp of a rewritten file,
+// "deterministic" for files rewritten without a model and "" without a stamp.
+func StampModel(src []byte) string {
+	first, _, _ := strings.Cut(string(src), "\n")
+	switch {
+	case first == deterministicStamp:
+		return "deterministic"
+	case !strings.HasPrefix(first, stampPrefix):
+		return ""
+	}
+	_, model, _ := strings.Cut(first, ", model ")
+	return strings.TrimSpace(model)
+}
+
+func (p *PromptSet) execute(t *template.Template, path, content string) (string, error) {
+	var buf bytes.Buffer
+	data := PromptData{Content: content, Version: p.Version, FixtureKeys: ctestglobals.FixtureKeys()}
+	if path != "" {
+		data.FileName = filepath.Base(path)
+	}
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s/%s: %w", p.Version, t.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// StampHeader puts stamp on top of src, replacing the stamp of an earlier rewrite.
+// The blank line keeps it from becoming the package doc comment.
+func StampHeader(src, stamp string) string {
+	for strings.HasPrefix(src, stampPrefix) {
+		_, src, _ = strings.Cut(src, "\n")
+		src = strings.TrimLeft(src, "\n")
+	}
+	return stamp + "\n\n" + src
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,111 @@
+package testrewrite
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDefaultPrompts(t *testing.T) {
+	p := DefaultPrompts()
+	prompt, err := p.Build("/k8s/test/e2e/node/pods_test.go", "package node\n")
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	for _, want := range []string{"Input file: pods_test.go", "File content:\npackage node\n", "getHardCodedConfigInfo<FileName>"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt misses %q", want)
+		}
+	}
+
+	messages, err := p.Messages(prompt)
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	// system, three few-shot pairs and the prompt
+	if len(messages) != 8 || messages[0].Role != "system" || messages[7].Content != prompt {
+		t.Fatalf("unexpected messages: %d", len(messages))
+	}
+	if !strings.Contains(messages[1].Content, `SIGDescribe("Sysctls [LinuxOnly]"`) || !strings.Contains(messages[2].Content, "HardcodedConfig{{") {
+		t.Errorf("few-shot examples are not in order or were rendered as templates")
+	}
+}
+
+func TestLoadPromptSet(t *testing.T) {
+	fsys := fstest.MapFS{
+		"v2/system.tmpl":                 {Data: []byte("system [[.Version]]")},
+		"v2/instructions.tmpl":           {Data: []byte("rewrite [[.FileName]]:\n[[.Content]]")},
+		"v2/examples/a.user.tmpl":        {Data: []byte("in")},
+		"v2/examples/a.assistant.tmpl":   {Data: []byte("out")},
+		"v2/examples/README":             {Data: []byte("not a template")},
+		"v3/system.tmpl":                 {Data: []byte("system")},
+		"v3/instructions.tmpl":           {Data: []byte("rewrite [[.Missing]]")},
+		"v1/system.tmpl":                 {Data: []byte("system")},
+		"v1/instructions.tmpl":           {Data: []byte("rewrite [[.FileName]]:\n[[.Content]]")},
+		"v1/examples/a.user.tmpl":        {Data: []byte("in")},
+		"v1/examples/a.assistant.tmpl":   {Data: []byte("changed")},
+		"broken/system.tmpl":             {Data: []byte("[[.Version")},
+		"broken/instructions.tmpl":       {Data: []byte("")},
+		"noanswer/system.tmpl":           {Data: []byte("")},
+		"noanswer/instructions.tmpl":     {Data: []byte("")},
+		"noanswer/examples/a.user.tmpl":  {Data: []byte("in")},
+		"noanswer/examples/b.other.tmpl": {Data: []byte("")},
+	}
+
+	p, err := LoadPromptSet(fsys, "v2")
+	if err != nil {
+		t.Fatalf("LoadPromptSet failed: %v", err)
+	}
+	prompt, err := p.Build("dir/a_test.go", "{{ code }}")
+	if err != nil || prompt != "rewrite a_test.go:\n{{ code }}" {
+		t.Errorf("Build = %q, %v", prompt, err)
+	}
+	messages, err := p.Messages(prompt)
+	if err != nil || len(messages) != 4 || messages[0].Content != "system v2" || messages[2].Content != "out" {
+		t.Errorf("unexpected messages %+v, %v", messages, err)
+	}
+
+	other, err := LoadPromptSet(fsys, "v1")
+	if err != nil {
+		t.Fatalf("LoadPromptSet failed: %v", err)
+	}
+	if other.Hash == p.Hash {
+		t.Errorf("different templates have the same hash %s", p.Hash)
+	}
+
+	if p3, err := LoadPromptSet(fsys, "v3"); err != nil {
+		t.Errorf("LoadPromptSet failed: %v", err)
+	} else if _, err := p3.Build("a_test.go", ""); err == nil {
+		t.Errorf("expected an error for an unknown template field")
+	}
+	for _, version := range []string{"broken", "noanswer", "v4"} {
+		if _, err := LoadPromptSet(fsys, version); err == nil {
+			t.Errorf("expected LoadPromptSet(%s) to fail", version)
+		}
+	}
+}
+
+func TestStampHeader(t *testing.T) {
+	p := DefaultPrompts()
+	src := "// Package node tests nodes.\npackage node\n"
+	stamped := StampHeader(src, p.Stamp("model-a"))
+	restamped := StampHeader(stamped, p.Stamp("model-b"))
+
+	want := p.Stamp("model-b") + "\n\n" + src
+	if restamped != want {
+		t.Errorf("StampHeader = %q, want %q", restamped, want)
+	}
+	if !strings.HasPrefix(p.Stamp("m"), "// ctest rewrite: prompt "+DefaultPromptVersion+" (sha256:"+p.Hash+")") {
+		t.Errorf("unexpected stamp %q", p.Stamp("m"))
+	}
+	for src, want := range map[string]string{
+		restamped:                            "model-b",
+		StampHeader(src, deterministicStamp): "deterministic",
+		src:                                  "",
+		p.Stamp("gpt-oss:120b-cloud") + "\n" + src: "gpt-oss:120b-cloud",
+	} {
+		if got := StampModel([]byte(src)); got != want {
+			t.Errorf("StampModel(%q) = %q, want %q", src, got, want)
+		}
+	}
+}
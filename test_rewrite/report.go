@@ -0,0 +1,317 @@
+package testrewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// mergeModes are the ctest.Mode constants a rewritten test can use.
+var mergeModes = map[string]bool{"ExtendOnly": true, "OverrideOnly": true, "Union": true, "Ablation": true}
+
+// FileReport describes one rewritten file for review.
+type FileReport struct {
+	File    string `json:"file"`
+	NewFile string `json:"newFile"`
+	// Stamp is the header comment naming the prompt and model, see PromptSet.Stamp
+	Stamp string `json:"stamp,omitempty"`
+	// OriginalTests and RewrittenTests count the test entry points (func TestXxx,
+	// ginkgo.It, ...) of both files
+	OriginalTests  int      `json:"originalTests"`
+	RewrittenTests int      `json:"rewrittenTests"`
+	Modes          []string `json:"modes"`
+	Fields         []string `json:"fields"`
+	// K8sObjects lists the entries that are not fixture keys, see CheckK8sObjects
+	K8sObjects  []string   `json:"k8sObjects,omitempty"`
+	Compiles    bool       `json:"compiles"`
+	BuildErrors []string   `json:"buildErrors,omitempty"`
+	VetWarnings []string   `json:"vetWarnings,omitempty"`
+	Diffs       []TestDiff `json:"diffs"`
+	Score       int        `json:"score"`
+}
+
+// TestDiff is the unified diff of one test between the original and the
+// rewritten file. Original is empty for tests without a counterpart.
+type TestDiff struct {
+	Test     string `json:"test"`
+	Original string `json:"original,omitempty"`
+	Diff     string `json:"diff"`
+}
+
+// ReviewReport collects the file reports of a run, lowest score first.
+type ReviewReport struct {
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Files       []FileReport `json:"files"`
+}
+
+// ReviewFile compares original with its rewrite newFile and type-checks the
+// rewrite in its package; with vet it also runs go vet.
+func ReviewFile(original, newFile string, vet bool) (*FileReport, error) {
+	return reviewOutput(original, newFile, newFile, vet)
+}
+
+// reviewOutput reviews the rewrite of original saved at output, which is
+// type-checked as newFile, its place in the package.
+//
+// Score (0-100) sorts files for review: 50 when the file compiles, 15 without
+// vet warnings, up to 25 for the share of original tests that were rewritten and
+// 10 when every entry names a Field and all K8sObjects are fixture keys.
+func reviewOutput(original, newFile, output string, vet bool) (*FileReport, error) {
+	origSrc, err := os.ReadFile(original)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original file: %w", err)
+	}
+	newSrc, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rewritten file: %w", err)
+	}
+	r := &FileReport{File: original, NewFile: newFile}
+	if first, _, _ := strings.Cut(string(newSrc), "\n"); strings.HasPrefix(first, stampPrefix) {
+		r.Stamp = first
+	}
+
+	origTests, origOrder, err := testSources(original, origSrc)
+	if err != nil {
+		return nil, err
+	}
+	newTests, newOrder, err := testSources(newFile, newSrc)
+	if err != nil {
+		return nil, err
+	}
+	r.OriginalTests, r.RewrittenTests = len(origOrder), len(newOrder)
+
+	for _, name := range newOrder {
+		origName := name
+		if strings.HasPrefix(name, "TestCtest") {
+			origName = "Test" + strings.TrimPrefix(name, "TestCtest")
+		}
+		d := TestDiff{Test: name}
+		before, ok := origTests[origName]
+		if ok {
+			d.Original = origName
+		}
+		d.Diff, err = unifiedDiff(filepath.Base(original), filepath.Base(newFile), before, newTests[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", name, err)
+		}
+		r.Diffs = append(r.Diffs, d)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, newFile, newSrc, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", newFile, err)
+	}
+	r.Modes, r.Fields = modesAndFields(f)
+	problems, err := CheckK8sObjectsSource(newFile, newSrc)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range problems {
+		r.K8sObjects = append(r.K8sObjects, p.String())
+	}
+
+	if r.BuildErrors, err = typeCheck(newFile, newSrc); err != nil {
+		return nil, err
+	}
+	r.Compiles = len(r.BuildErrors) == 0
+	if vet && r.Compiles {
+		if r.VetWarnings, err = vetFile(newFile, newSrc); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Score = r.score()
+	return r, nil
+}
+
+func (r *FileReport) score() int {
+	score := 0
+	if r.Compiles {
+		score += 50
+		if len(r.VetWarnings) == 0 {
+			score += 15
+		}
+	}
+	if r.OriginalTests > 0 {
+		score += 25 * min(r.RewrittenTests, r.OriginalTests) / r.OriginalTests
+	}
+	if len(r.Fields) > 0 && len(r.K8sObjects) == 0 {
+		score += 10
+	}
+	return score
+}
+
+// BuildReviewReport reviews the file of every result that has a rewrite on disk,
+// next to the original or in the overlay directory. Files that cannot be
+// reviewed are reported with a score of 0.
+func BuildReviewReport(results []FileResult, vet bool) *ReviewReport {
+	report := &ReviewReport{GeneratedAt: time.Now()}
+	for _, res := range results {
+		output := res.Output
+		if output == "" {
+			output = res.NewFile
+		}
+		if output == "" || !fileExists(output) {
+			continue
+		}
+		r, err := reviewOutput(res.File, res.NewFile, output, vet)
+		if err != nil {
+			r = &FileReport{File: res.File, NewFile: res.NewFile, BuildErrors: []string{err.Error()}}
+		}
+		report.Files = append(report.Files, *r)
+	}
+	sort.SliceStable(report.Files, func(i, j int) bool { return report.Files[i].Score < report.Files[j].Score })
+	return report
+}
+
+// RewrittenResults returns a result for every ctest_ test file under target
+// whose original is next to it, to review a tree rewritten by earlier runs.
+func RewrittenResults(target string) ([]FileResult, error) {
+	var results []FileResult
+	err := walker.New(walker.Rewrites).Walk(target, func(path string, d fs.DirEntry) error {
+		if !walker.IsRewrite(path) || !strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		original := walker.OriginalOf(path)
+		if fileExists(original) {
+			results = append(results, FileResult{File: original, NewFile: path, Output: path, Status: StatusRewritten})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rewritten files: %w", err)
+	}
+	return results, nil
+}
+
+// WriteFiles saves the report as <base>.json and <base>.md.
+func (r *ReviewReport) WriteFiles(base string) error {
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal review report: %w", err)
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(base+".md", []byte(r.Markdown()), 0644)
+}
+
+// Markdown renders the report: a table of all files, then the findings and test
+// diffs of each file.
+func (r *ReviewReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Rewrite review report\n\nGenerated %s, %d files, lowest score first.\n\n", r.GeneratedAt.Format(time.RFC3339), len(r.Files))
+	b.WriteString("| Score | File | Tests | Modes | Fields | Compiles | Vet |\n|---:|---|---|---|---|---|---|\n")
+	for _, f := range r.Files {
+		fmt.Fprintf(&b, "| %d | %s | %d/%d | %s | %s | %s | %d |\n",
+			f.Score, f.NewFile, f.RewrittenTests, f.OriginalTests, strings.Join(f.Modes, ", "), strings.Join(f.Fields, ", "), yesNo(f.Compiles), len(f.VetWarnings))
+	}
+
+	for _, f := range r.Files {
+		fmt.Fprintf(&b, "\n## %s (score %d)\n\nOriginal: %s\n", f.NewFile, f.Score, f.File)
+		if f.Stamp != "" {
+			fmt.Fprintf(&b, "\n`%s`\n", f.Stamp)
+		}
+		for _, section := range []struct {
+			title string
+			lines []string
+		}{{"Build errors", f.BuildErrors}, {"Vet warnings", f.VetWarnings}, {"K8sObjects", f.K8sObjects}} {
+			if len(section.lines) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n%s:\n\n", section.title)
+			for _, line := range section.lines {
+				fmt.Fprintf(&b, "- `%s`\n", line)
+			}
+		}
+		for _, d := range f.Diffs {
+			from := d.Original
+			if from == "" {
+				from = "new test"
+			}
+			fmt.Fprintf(&b, "\n<details><summary>%s (from %s)</summary>\n\n```diff\n%s```\n\n</details>\n", d.Test, from, d.Diff)
+		}
+	}
+	return b.String()
+}
+
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
+
+// testSources returns the source of every test entry point of a file by name,
+// and the names in file order.
+func testSources(path string, src []byte) (map[string]string, []string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	tf := fset.File(f.Pos())
+	text := func(n ast.Node) string { return string(src[tf.Offset(n.Pos()):tf.Offset(n.End())]) + "\n" }
+
+	sources := make(map[string]string)
+	var order []string
+	for _, decl := range f.Decls {
+		for _, entry := range entryPoints(decl) {
+			name := entry.name
+			if _, dup := sources[name]; dup {
+				name = fmt.Sprintf("%s (%d)", name, len(order)+1)
+			}
+			if entry.stmt != nil {
+				sources[name] = text(entry.stmt)
+			} else {
+				sources[name] = text(decl)
+			}
+			order = append(order, name)
+		}
+	}
+	return sources, order, nil
+}
+
+// modesAndFields returns the ctest merge modes and the Field values of the
+// hardcoded configs used in f.
+func modesAndFields(f *ast.File) (modes, fields []string) {
+	seenModes := make(map[string]bool)
+	seenFields := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			if pkg, ok := x.X.(*ast.Ident); ok && pkg.Name == "ctest" && mergeModes[x.Sel.Name] && !seenModes[x.Sel.Name] {
+				seenModes[x.Sel.Name] = true
+				modes = append(modes, x.Sel.Name)
+			}
+		case *ast.KeyValueExpr:
+			key, ok := x.Key.(*ast.Ident)
+			lit, isLit := x.Value.(*ast.BasicLit)
+			if ok && key.Name == "Field" && isLit && lit.Kind == token.STRING {
+				if field, err := strconv.Unquote(lit.Value); err == nil && !seenFields[field] {
+					seenFields[field] = true
+					fields = append(fields, field)
+				}
+			}
+		}
+		return true
+	})
+	sort.Strings(modes)
+	sort.Strings(fields)
+	return modes, fields
+}
@@ -0,0 +1,114 @@
+package testrewrite
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReviewFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":      "module example.com/foo\n\ngo 1.21\n",
+		"foo.go":      "package foo\n\nfunc Double(i int) int { return 2 * i }\n",
+		"foo_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestDouble(t *testing.T) {\n\t_ = Double(1)\n}\n\nfunc TestOther(t *testing.T) {}\n",
+		"ctest_foo_test.go": "// ctest rewrite: prompt v1 (sha256:abc), model m\n\npackage foo\n\nimport \"testing\"\n\n" +
+			"func TestCtestDouble(t *testing.T) {\n\tfor _, i := range []int{1, 2} {\n\t\t_ = Double(i)\n\t}\n}\n",
+		"ctest_bad_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestCtestBad(t *testing.T) { _ = Triple(1) }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original := filepath.Join(dir, "foo_test.go")
+
+	r, err := ReviewFile(original, filepath.Join(dir, "ctest_foo_test.go"), false)
+	if err != nil {
+		t.Fatalf("ReviewFile failed: %v", err)
+	}
+	if !r.Compiles || r.OriginalTests != 2 || r.RewrittenTests != 1 || !strings.HasPrefix(r.Stamp, stampPrefix) {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if len(r.Diffs) != 1 || r.Diffs[0].Original != "TestDouble" {
+		t.Fatalf("unexpected diffs %+v", r.Diffs)
+	}
+	for _, want := range []string{"-func TestDouble(t *testing.T) {", "+func TestCtestDouble(t *testing.T) {", "+\t\t_ = Double(i)"} {
+		if !strings.Contains(r.Diffs[0].Diff, want) {
+			t.Errorf("diff misses %q:\n%s", want, r.Diffs[0].Diff)
+		}
+	}
+	// compiles, no vet warnings, half of the tests, no Field
+	if r.Score != 50+15+12 {
+		t.Errorf("Score = %d", r.Score)
+	}
+
+	report := BuildReviewReport([]FileResult{
+		{File: original, NewFile: filepath.Join(dir, "ctest_foo_test.go")},
+		{File: original, NewFile: filepath.Join(dir, "ctest_bad_test.go")},
+		{File: original, NewFile: filepath.Join(dir, "ctest_missing_test.go")},
+		{File: original},
+	}, false)
+	if len(report.Files) != 2 || report.Files[0].Compiles || !report.Files[1].Compiles {
+		t.Fatalf("expected the broken file first, got %+v", report.Files)
+	}
+	if report.Files[0].Diffs[0].Original != "" || len(report.Files[0].BuildErrors) == 0 {
+		t.Errorf("unexpected report of the broken file %+v", report.Files[0])
+	}
+
+	base := filepath.Join(dir, "logs", "report")
+	if err := report.WriteFiles(base); err != nil {
+		t.Fatalf("WriteFiles failed: %v", err)
+	}
+	md, err := os.ReadFile(base + ".md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"| 77 | " + filepath.Join(dir, "ctest_foo_test.go") + " | 1/2 |", "TestCtestBad (from new test)", "```diff\n", "Build errors:"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("markdown misses %q:\n%s", want, md)
+		}
+	}
+	data, err := os.ReadFile(base + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ReviewReport
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded.Files) != 2 {
+		t.Errorf("unexpected JSON report: %v", err)
+	}
+}
+
+func TestModesAndFields(t *testing.T) {
+	src := `package foo
+
+func getHardCodedConfigInfoFoo() ctestglobals.HardcodedConfig {
+	return ctestglobals.HardcodedConfig{
+		{Field: "spec", K8sObjects: []string{"pods"}},
+		{Field: "securityContext"},
+		{Field: "spec"},
+	}
+}
+
+func TestCtestFoo(t *testing.T) {
+	_, _ = ctest.GenerateEffectiveConfigReturnType[v1.PodSpec](item, ctest.Union)
+	_, _ = ctest.GenerateEffectiveConfigReturnType[v1.PodSpec](item, ctest.ExtendOnly)
+	_ = other.Union
+}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "ctest_foo_test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes, fields := modesAndFields(f)
+	if !reflect.DeepEqual(modes, []string{"ExtendOnly", "Union"}) {
+		t.Errorf("modes = %v", modes)
+	}
+	if !reflect.DeepEqual(fields, []string{"securityContext", "spec"}) {
+		t.Errorf("fields = %v", fields)
+	}
+}
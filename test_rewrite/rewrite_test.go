@@ -1,17 +1,25 @@
 package testrewrite
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
 	"testing"
 	"time"
 )
 
-// TestRewriteWithLLM rewrites Go test files using Ollama
+var llmConfig = LLMConfigFromEnv()
+
+func init() {
+	llmConfig.AddFlags(flag.CommandLine)
+}
+
+// TestRewriteWithLLM rewrites Go test files using the configured LLM (Ollama by default)
 func TestRewriteWithLLM(t *testing.T) {
+	flag.Parse()
 	start := time.Now()
 
 	//---------------------------------------
@@ -40,16 +48,50 @@ func TestRewriteWithLLM(t *testing.T) {
 		absTarget = filepath.Join(k8sRoot, target)
 	}
 
-	model := os.Getenv("OLLAMA_MODEL")
-	if model == "" {
-		model = ctestglobals.OllamaModelDefault
+	client, err := NewLLMClient(llmConfig)
+	if err != nil {
+		t.Fatalf("failed to create LLM client: %v", err)
 	}
 
-	overwrite := strings.EqualFold(os.Getenv("OVERWRITE_REWRITTEN"), "true")
+	opts := RewriteOptionsFromEnv(k8sRoot)
+	opts.Logf = t.Logf
+	if opts.Prompts, err = PromptSetFromEnv(); err != nil {
+		t.Fatalf("failed to load prompt: %v", err)
+	}
 
 	t.Logf("Rewrite target: %s", absTarget)
-	t.Logf("Using Ollama model: %s", model)
-	t.Logf("Overwrite rewritten files: %v", overwrite)
+	t.Logf("Using LLM provider: %s, model: %s", llmConfig.Provider, client.Model())
+	t.Logf("Overwrite rewritten files: %v", opts.Overwrite)
+	t.Logf("Workers: %d, request interval: %s, journal: %s", opts.Workers, opts.Interval, opts.JournalPath)
+	t.Logf("Streaming: %v, timeout: %s", llmConfig.Stream, llmConfig.Timeout)
+	t.Logf("Repair attempts: %d, vet: %v", opts.Validation.RepairAttempts, opts.Validation.Vet)
+	t.Logf("Prompt: %s (sha256:%s)", opts.Prompts.Version, opts.Prompts.Hash)
+	t.Logf("Response cache: %s (%s)", opts.CacheDir, opts.CacheMode)
+	if opts.DryRun {
+		t.Logf("Dry run: nothing is written")
+	}
+	if opts.OverlayDir != "" {
+		t.Logf("Writing rewritten files to overlay %s", opts.OverlayDir)
+	}
+	if opts.Activation != "" {
+		t.Logf("Activation of rewritten packages: %s", opts.Activation)
+	}
+
+	if v := os.Getenv("REWRITE_CACHE_PRUNE"); v != "" && opts.CacheDir != "" {
+		olderThan, err := time.ParseDuration(v)
+		if err != nil {
+			t.Fatalf("invalid REWRITE_CACHE_PRUNE %q: %v", v, err)
+		}
+		cache, err := OpenResponseCache(opts.CacheDir)
+		if err != nil {
+			t.Fatalf("failed to open response cache: %v", err)
+		}
+		removed, err := cache.Prune(olderThan)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		t.Logf("Pruned %d cached responses unused for %s", removed, olderThan)
+	}
 
 	//---------------------------------------
 	// Collect files
@@ -68,100 +110,42 @@ func TestRewriteWithLLM(t *testing.T) {
 	}
 
 	//---------------------------------------
-	// Summary counters
+	// Rewrite
 	//---------------------------------------
 
-	total := len(files)
-	rewritten := 0
-	skipped := 0
-	failed := 0
-	alreadyRewritten := 0
-
-	//---------------------------------------
-	// Rewrite loop
-	//---------------------------------------
-
-	for i, file := range files {
-
-		newFile := rewrittenPath(file)
-		exists := fileExists(newFile)
-
-		tagged, err := hasOriginalBuildTag(file)
+	// Ctrl-C or REWRITE_DEADLINE stop the run; finished files stay in the journal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if v := os.Getenv("REWRITE_DEADLINE"); v != "" {
+		deadline, err := time.ParseDuration(v)
 		if err != nil {
-			t.Errorf("failed checking build tag for %s: %v", file, err)
-			failed++
-			continue
-		}
-
-		// Skip if already rewritten and not overwriting
-		if exists && !overwrite {
-			t.Logf("⏭️  Skipping already rewritten file: %s", file)
-			alreadyRewritten++
-			continue
-		}
-
-		t.Logf("[%d/%d] Rewriting %s", i+1, total, file)
-
-		contentBytes, err := os.ReadFile(file)
-		if err != nil {
-			t.Errorf("failed to read file %s: %v", file, err)
-			failed++
-			continue
+			t.Fatalf("invalid REWRITE_DEADLINE %q: %v", v, err)
 		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+		t.Logf("Deadline: %s", deadline)
+	}
 
-		prompt := BuildPrompt(file, string(contentBytes))
-		rewrittenContent, err := CallOllama(prompt)
-		// time.Sleep(30)
-		if err != nil {
-			t.Errorf("rewrite failed for %s: %v", file, err)
-			failed++
-			continue
+	summary, err := RewriteFiles(ctx, client, files, opts)
+	if err != nil {
+		t.Fatalf("rewrite failed: %v", err)
+	}
+	for _, res := range summary.Results {
+		if res.Err != nil {
+			t.Errorf("%v", res.Err)
 		}
+	}
 
-		rewrittenTrim := strings.TrimSpace(rewrittenContent)
-
-		// --- Handle override + NONE ---
-		if strings.EqualFold(rewrittenTrim, "NONE") {
-			t.Logf("⚠️  No tests need rewriting in %s", file)
-			skipped++
-
-			if overwrite && exists {
-				// Delete previous rewritten file
-				if err := os.Remove(newFile); err != nil {
-					t.Errorf("failed to remove old rewritten file %s: %v", newFile, err)
-				} else {
-					t.Logf("🗑️  Deleted previous rewritten file %s", newFile)
-				}
-
-				// Remove original build tag
-				if tagged {
-					if err := removeOriginalBuildTag(file); err != nil {
-						t.Errorf("failed to remove build tag from %s: %v", file, err)
-					} else {
-						t.Logf("🏷️ Removed build tag from original file %s", file)
-					}
-				}
+	if opts.DryRun {
+		for _, res := range summary.Results {
+			for _, action := range res.Planned {
+				t.Logf("📝 would %s", action)
+			}
+			if res.Diff != "" {
+				t.Logf("\n%s", res.Diff)
 			}
-			continue
-		}
-		// --- End override + NONE ---
-
-		// Write rewritten content
-		if err := os.WriteFile(newFile, []byte(rewrittenContent), 0644); err != nil {
-			t.Errorf("failed to write %s: %v", newFile, err)
-			failed++
-			continue
 		}
-
-		// // Add build tag to original file
-		// if err := addOriginalBuildTag(file); err != nil {
-		// 	t.Errorf("failed to tag original file %s: %v", file, err)
-		// 	failed++
-		// 	continue
-		// }
-
-		t.Logf("✅ Saved %s", newFile)
-		rewritten++
 	}
 
 	//---------------------------------------
@@ -170,75 +154,27 @@ func TestRewriteWithLLM(t *testing.T) {
 
 	t.Log("===================================")
 	t.Logf("Rewrite Summary")
-	t.Logf("Targeted files    : %d", total)
-	t.Logf("Rewritten         : %d", rewritten)
-	t.Logf("Already Rewritten : %d", alreadyRewritten)
-	t.Logf("Skipped           : %d", skipped)
-	t.Logf("Failed            : %d", failed)
+	summary.Log(t.Logf)
 	t.Logf("Elapsed time      : %s", time.Since(start))
 	t.Log("===================================")
-}
-
-//------------------------------------------------
-// Helpers
-//------------------------------------------------
-
-func rewrittenPath(original string) string {
-	return filepath.Join(
-		filepath.Dir(original),
-		"ctest_"+filepath.Base(original),
-	)
-}
-
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
-
-func hasOriginalBuildTag(path string) (bool, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return false, err
-	}
 
-	content := string(data)
-
-	return strings.HasPrefix(content, "//go:build original") ||
-		strings.HasPrefix(content, "// +build original"), nil
-}
+	//---------------------------------------
+	// Review report
+	//---------------------------------------
 
-func addOriginalBuildTag(path string) error {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return err
+	if opts.DryRun {
+		return
 	}
-
-	content := string(data)
-
-	if strings.HasPrefix(content, "//go:build original") ||
-		strings.HasPrefix(content, "// +build original") {
-		return nil
+	if opts.OverlayDir != "" {
+		t.Logf("Test the rewrites with: go test -overlay=%s ...", filepath.Join(opts.OverlayDir, OverlayFile))
 	}
-
-	tag := "//go:build original\n// +build original\n\n"
-	return os.WriteFile(path, []byte(tag+content), 0644)
-}
-
-func removeOriginalBuildTag(path string) error {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return err
+	reportBase := os.Getenv("REWRITE_REPORT")
+	if reportBase == "" {
+		reportBase = filepath.Join(k8sRoot, "test", "ctest", "logs", "rewrite_report")
 	}
-
-	content := string(data)
-	lines := strings.Split(content, "\n")
-	var newLines []string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "//go:build original") || strings.HasPrefix(line, "// +build original") {
-			continue
-		}
-		newLines = append(newLines, line)
+	report := BuildReviewReport(summary.Results, opts.Validation.Vet)
+	if err := report.WriteFiles(reportBase); err != nil {
+		t.Fatalf("failed to write review report: %v", err)
 	}
-
-	return os.WriteFile(path, []byte(strings.Join(newLines, "\n")), 0644)
+	t.Logf("Review report     : %s.{md,json} (%d files)", reportBase, len(report.Files))
 }
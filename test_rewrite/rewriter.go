@@ -0,0 +1,705 @@
+package testrewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"k8s.io/kubernetes/test/ctest/activation"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+
+	"k8s.io/kubernetes/test/ctest/walker"
+)
+
+// RewriteOptions configures a rewrite run.
+type RewriteOptions struct {
+	// Overwrite rewrites files whose ctest_ file exists but is not recorded as done
+	// for the current model and prompt in the journal. Without it an existing
+	// ctest_ file is never replaced.
+	Overwrite bool
+	// Workers is the number of files rewritten concurrently.
+	Workers int
+	// Interval is the minimum time between two LLM requests across all workers,
+	// Burst the number of requests allowed at once.
+	Interval time.Duration
+	Burst    int
+	// MaxRetries is how often a request failing with HTTP 429/5xx or a timeout is
+	// retried, waiting Backoff, 2*Backoff, ... in between.
+	MaxRetries int
+	Backoff    time.Duration
+	// Prefilter skips files and tests without Kubernetes API config literals or test
+	// case tables instead of asking the model.
+	Prefilter bool
+	// Deterministic rewrites files matching a known pattern without the model,
+	// see RewriteDeterministic.
+	Deterministic bool
+	// ChunkSize is the file size in bytes above which a file is rewritten one test
+	// at a time; 0 disables chunking.
+	ChunkSize int
+	// ProgressInterval is how often the tokens received for a streamed reply are
+	// logged; 0 disables progress messages.
+	ProgressInterval time.Duration
+	// JournalPath is where progress is saved; empty keeps it in memory.
+	JournalPath string
+	// CacheDir holds the ResponseCache; empty disables it. CacheMode is CacheOn,
+	// CacheOff or CacheRefresh.
+	CacheDir  string
+	CacheMode string
+	// DryRun rewrites and validates as usual but only reports the files it would
+	// write, delete or untag, with diffs. Neither the tree, the journal nor the
+	// cache is changed.
+	DryRun bool
+	// OverlayDir, if set, receives the rewritten files under their path relative
+	// to Root instead of the tree, with a go build -overlay file, see OverlayFile.
+	OverlayDir string
+	Root       string
+	// Activation puts the package of every written rewrite into this mode, see
+	// activation.Apply; a package that would not build is left alone. Empty only
+	// reports the collisions and missing symbols of the package as it is.
+	Activation activation.Mode
+	Validation ValidationOptions
+	// Prompts are the prompt templates; nil uses the built-in DefaultPrompts.
+	Prompts *PromptSet
+	// Logf receives progress messages, fmt.Printf style.
+	Logf func(format string, args ...interface{})
+}
+
+// RewriteOptionsFromEnv reads OVERWRITE_REWRITTEN, REWRITE_WORKERS (default 1),
+// OLLAMA_SLEEP (request interval, default 10s), REWRITE_BURST (default 1),
+// REWRITE_MAX_RETRIES (default 3), REWRITE_BACKOFF (default 10s),
+// REWRITE_PREFILTER (default true), REWRITE_DETERMINISTIC (default true),
+// REWRITE_CHUNK_SIZE (default 40000), REWRITE_PROGRESS (default 30s),
+// REWRITE_JOURNAL (default test/ctest/logs/rewrite_journal.json under k8sRoot),
+// REWRITE_CACHE (on, off or refresh, default on), REWRITE_CACHE_DIR (default
+// test/ctest/logs/rewrite_cache under k8sRoot), REWRITE_DRY_RUN (default false),
+// REWRITE_OVERLAY_DIR and REWRITE_ACTIVATION.
+func RewriteOptionsFromEnv(k8sRoot string) RewriteOptions {
+	opts := RewriteOptions{
+		Overwrite:        strings.EqualFold(os.Getenv("OVERWRITE_REWRITTEN"), "true"),
+		Workers:          1,
+		Interval:         ollamaSleepDuration(),
+		Burst:            1,
+		MaxRetries:       3,
+		Backoff:          10 * time.Second,
+		Prefilter:        prefilterEnabled(),
+		Deterministic:    deterministicEnabled(),
+		ChunkSize:        chunkSize(),
+		ProgressInterval: 30 * time.Second,
+		JournalPath:      filepath.Join(k8sRoot, "test", "ctest", "logs", "rewrite_journal.json"),
+		CacheDir:         filepath.Join(k8sRoot, "test", "ctest", "logs", "rewrite_cache"),
+		CacheMode:        CacheOn,
+		DryRun:           strings.EqualFold(os.Getenv("REWRITE_DRY_RUN"), "true"),
+		OverlayDir:       os.Getenv("REWRITE_OVERLAY_DIR"),
+		Root:             k8sRoot,
+		Activation:       activation.Mode(os.Getenv("REWRITE_ACTIVATION")),
+		Validation:       ValidationOptionsFromEnv(),
+	}
+	if v, err := strconv.Atoi(os.Getenv("REWRITE_WORKERS")); err == nil && v > 0 {
+		opts.Workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("REWRITE_BURST")); err == nil && v > 0 {
+		opts.Burst = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("REWRITE_MAX_RETRIES")); err == nil && v >= 0 {
+		opts.MaxRetries = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("REWRITE_BACKOFF")); err == nil {
+		opts.Backoff = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("REWRITE_PROGRESS")); err == nil {
+		opts.ProgressInterval = v
+	}
+	if v, ok := os.LookupEnv("REWRITE_JOURNAL"); ok {
+		opts.JournalPath = v
+	}
+	if v := os.Getenv("REWRITE_CACHE"); v != "" {
+		opts.CacheMode = strings.ToLower(v)
+	}
+	if v, ok := os.LookupEnv("REWRITE_CACHE_DIR"); ok {
+		opts.CacheDir = v
+	}
+	return opts
+}
+
+// FileResult is the outcome of one file. Status is one of the journal statuses,
+// "already-rewritten", "filtered" or "canceled".
+type FileResult struct {
+	File string
+	// NewFile is the place of the rewrite in the package, Output where it is
+	// written: NewFile, or its copy in the overlay directory.
+	NewFile  string
+	Output   string
+	Status   string
+	Attempts int
+	// SkipReason says why a filtered file was not sent to the model.
+	SkipReason string
+	// Deterministic is set when the file was rewritten without the model.
+	Deterministic bool
+	// Stats are the metrics of the LLM requests made for the file.
+	Stats ChatStats
+	// Planned lists the changes a dry run would have made, Diff the changes to
+	// Output.
+	Planned []string
+	Diff    string
+	// Hash is the ContentHash of the written Output.
+	Hash string
+	// Problems are the collisions and missing symbols of the package after the
+	// rewrite was written, see RewriteOptions.Activation.
+	Problems []activation.Problem
+	Err      error
+}
+
+const (
+	// StatusAlreadyRewritten marks files skipped because their rewrite is up to date.
+	StatusAlreadyRewritten = "already-rewritten"
+	// StatusFiltered marks files the pre-filter found nothing to rewrite in.
+	StatusFiltered = "filtered"
+	// StatusCanceled marks files not finished because the run was canceled.
+	StatusCanceled = "canceled"
+)
+
+// RewriteSummary counts the results of a run.
+type RewriteSummary struct {
+	Total            int
+	Rewritten        int
+	AlreadyRewritten int
+	Skipped          int
+	Failed           int
+	Invalid          int
+	Filtered         int
+	Deterministic    int
+	Canceled         int
+	// Problems counts the rewritten files whose package has activation problems.
+	Problems int
+	// Tokens, CacheHits and LLMTime add up the ChatStats of all files.
+	Tokens      int
+	CacheHits   int
+	LLMTime     time.Duration
+	SkipReasons map[string]int
+	Elapsed     time.Duration
+	Results     []FileResult
+}
+
+// RewriteFiles rewrites files with client using a pool of workers that share one
+// rate limiter. Progress is recorded in the journal after every file.
+func RewriteFiles(ctx context.Context, client LLMClient, files []string, opts RewriteOptions) (*RewriteSummary, error) {
+	start := time.Now()
+	if opts.Logf == nil {
+		opts.Logf = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Prompts == nil {
+		opts.Prompts = DefaultPrompts()
+	}
+	if opts.Activation != "" {
+		mode, err := activation.ParseMode(string(opts.Activation))
+		if err != nil {
+			return nil, err
+		}
+		opts.Activation = mode
+	}
+
+	journal, err := OpenJournal(opts.JournalPath)
+	if err != nil {
+		return nil, err
+	}
+	var cache *ResponseCache
+	switch opts.CacheMode {
+	case CacheOff:
+	case CacheOn, CacheRefresh, "":
+		if opts.CacheDir != "" {
+			if opts.CacheMode == "" {
+				opts.CacheMode = CacheOn
+			}
+			if cache, err = OpenResponseCache(opts.CacheDir); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown cache mode %q, expected %s, %s or %s", opts.CacheMode, CacheOn, CacheOff, CacheRefresh)
+	}
+
+	limit := rate.Inf
+	if opts.Interval > 0 {
+		limit = rate.Every(opts.Interval)
+	}
+	r := &rewriter{
+		client:  client,
+		opts:    opts,
+		journal: journal,
+		cache:   cache,
+		limiter: rate.NewLimiter(limit, max(opts.Burst, 1)),
+		total:   len(files),
+	}
+
+	results := make([]FileResult, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					// Not recorded in the journal, the next run picks the file up
+					results[i] = FileResult{File: files[i], NewFile: walker.RewriteOf(files[i]), Status: StatusCanceled, Err: err}
+					continue
+				}
+				results[i] = r.rewriteFile(ctx, i, files[i])
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary := &RewriteSummary{Total: len(files), Results: results, SkipReasons: make(map[string]int)}
+	for _, res := range results {
+		summary.Tokens += res.Stats.Tokens
+		summary.CacheHits += res.Stats.Cached
+		summary.LLMTime += res.Stats.Duration
+		switch res.Status {
+		case StatusRewritten:
+			summary.Rewritten++
+			if res.Deterministic {
+				summary.Deterministic++
+			}
+			if len(res.Problems) > 0 {
+				summary.Problems++
+			}
+		case StatusAlreadyRewritten:
+			summary.AlreadyRewritten++
+		case StatusNone:
+			summary.Skipped++
+		case StatusInvalid:
+			summary.Invalid++
+		case StatusFiltered:
+			summary.Filtered++
+			summary.SkipReasons[res.SkipReason]++
+		case StatusCanceled:
+			summary.Canceled++
+		default:
+			summary.Failed++
+		}
+	}
+	summary.Elapsed = time.Since(start)
+
+	if opts.OverlayDir != "" && !opts.DryRun {
+		replace := make(map[string]string)
+		for _, res := range results {
+			switch {
+			case res.Status == StatusRewritten:
+				replace[res.NewFile] = res.Output
+			case res.Status == StatusNone && !fileExists(res.Output):
+				replace[res.NewFile] = ""
+			}
+		}
+		if err := UpdateOverlay(opts.OverlayDir, replace); err != nil {
+			return summary, fmt.Errorf("failed to update overlay: %w", err)
+		}
+	}
+	return summary, nil
+}
+
+// Log writes the counts of the summary, one per line.
+func (s *RewriteSummary) Log(logf func(format string, args ...interface{})) {
+	logf("Targeted files    : %d", s.Total)
+	logf("Rewritten         : %d", s.Rewritten)
+	logf("  without model   : %d", s.Deterministic)
+	logf("Already Rewritten : %d", s.AlreadyRewritten)
+	logf("Skipped           : %d", s.Skipped)
+	logf("Failed            : %d", s.Failed)
+	logf("Did not build     : %d", s.Invalid)
+	logf("Filtered          : %d", s.Filtered)
+	for reason, n := range s.SkipReasons {
+		logf("  - %s: %d", reason, n)
+	}
+	logf("Canceled          : %d", s.Canceled)
+	logf("Package problems  : %d", s.Problems)
+	logf("Generated tokens  : %d in %s", s.Tokens, s.LLMTime.Round(time.Second))
+	logf("Cached responses  : %d", s.CacheHits)
+}
+
+type rewriter struct {
+	client  LLMClient
+	opts    RewriteOptions
+	journal *Journal
+	cache   *ResponseCache
+	limiter *rate.Limiter
+	total   int
+	// activating serializes the build tag changes of workers in one package
+	activating sync.Mutex
+}
+
+func (r *rewriter) rewriteFile(ctx context.Context, i int, file string) FileResult {
+	newFile := walker.RewriteOf(file)
+	res := FileResult{File: file, NewFile: newFile, Output: newFile}
+	if r.opts.OverlayDir != "" {
+		res.Output = overlayPath(r.opts.OverlayDir, r.opts.Root, newFile)
+	}
+	fail := func(status string, err error) FileResult {
+		res.Status = status
+		res.Err = err
+		r.record(res, "")
+		return res
+	}
+
+	contentBytes, err := os.ReadFile(file)
+	if err != nil {
+		return fail(StatusFailed, fmt.Errorf("failed to read file %s: %w", file, err))
+	}
+	// The tag added by activating the package is not part of the test, the
+	// prompts and their hash stay those of the untagged file
+	tagged := activation.HasTag(contentBytes, activation.OriginalTag)
+	src := activation.WithoutTag(contentBytes, activation.OriginalTag)
+	if r.opts.Prefilter {
+		if reason := prefilter(file, string(src)); reason != "" {
+			r.opts.Logf("⏭️  Skipping %s: %s", file, reason)
+			res.Status, res.SkipReason = StatusFiltered, reason
+			return res
+		}
+	}
+	prompts, err := r.opts.Prompts.BuildPrompts(file, string(src), r.opts.ChunkSize, r.opts.Prefilter)
+	if err != nil {
+		return fail(StatusFailed, err)
+	}
+	if len(prompts) == 0 {
+		r.opts.Logf("⏭️  Skipping %s: no test has anything to rewrite", file)
+		res.Status, res.SkipReason = StatusFiltered, SkipNoCandidates
+		return res
+	}
+	promptHash := PromptHash(r.opts.Prompts.Hash + "\n" + strings.Join(prompts, "\n"))
+
+	// An existing rewrite may be edited by hand, only Overwrite replaces it; the
+	// journal decides among the other files, failed ones are retried
+	exists := fileExists(res.Output)
+	if exists && !r.opts.Overwrite {
+		r.opts.Logf("⏭️  Skipping already rewritten file: %s", file)
+		res.Status = StatusAlreadyRewritten
+		return res
+	}
+	if entry, ok := r.journal.Get(file); ok && entry.Done(r.client.Model(), promptHash, res.Output) {
+		r.opts.Logf("⏭️  Skipping file already done in journal: %s", file)
+		res.Status = StatusAlreadyRewritten
+		return res
+	}
+
+	var rewrittenContent string
+	if r.opts.Deterministic {
+		rewrittenContent, res.Deterministic = r.rewriteDeterministic(file, newFile, src)
+	}
+	stamp := r.opts.Prompts.Stamp(r.client.Model())
+	if res.Deterministic {
+		r.opts.Logf("[%d/%d] Rewrote %s without the model", i+1, r.total, file)
+		stamp = deterministicStamp
+	} else {
+		retrying := &retryingClient{LLMClient: r.client, limiter: r.limiter, opts: r.opts}
+		var client LLMClient = retrying
+		if r.cache != nil {
+			client = &cachingClient{LLMClient: retrying, cache: r.cache, prompt: r.opts.Prompts.Version + "@" + r.opts.Prompts.Hash, mode: r.opts.CacheMode, readOnly: r.opts.DryRun, logf: r.opts.Logf}
+		}
+		chatCtx, rec := WithChatStats(ctx, r.progressLogger(file))
+		if len(prompts) == 1 {
+			r.opts.Logf("[%d/%d] Rewriting %s", i+1, r.total, file)
+			rewrittenContent, err = r.rewritePrompt(chatCtx, client, file, newFile, prompts[0])
+		} else {
+			r.opts.Logf("[%d/%d] Rewriting %s in %d chunks", i+1, r.total, file, len(prompts))
+			rewrittenContent, err = r.rewriteChunks(chatCtx, client, file, newFile, prompts)
+		}
+		res.Attempts = retrying.attempts
+		res.Stats = rec.Stats()
+		if res.Stats.DoneReason == "length" {
+			r.opts.Logf("⚠️  Reply for %s hit the token limit, it is probably cut off", file)
+		}
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		// Never write a file that breaks the package build
+		r.opts.Logf("❌ Rewrite of %s does not build after %d repair attempt(s):\n%s", file, r.opts.Validation.RepairAttempts, strings.Join(validationErr.Problems, "\n"))
+		res.Status, res.Err = StatusInvalid, err
+		r.record(res, promptHash)
+		return res
+	}
+	if err != nil {
+		res.Status, res.Err = StatusFailed, fmt.Errorf("rewrite failed for %s: %w", file, err)
+		if ctx.Err() != nil {
+			res.Status = StatusCanceled
+		}
+		if rewrittenContent != "" {
+			r.opts.Logf("✋ Partial reply for %s before the request failed:\n%s", file, rewrittenContent)
+		}
+		r.record(res, promptHash)
+		return res
+	}
+
+	if strings.EqualFold(strings.TrimSpace(rewrittenContent), "NONE") {
+		r.opts.Logf("⚠️  No tests need rewriting in %s", file)
+		res.Status = StatusNone
+
+		if r.opts.Overwrite && exists {
+			// Delete previous rewritten file
+			if r.opts.DryRun {
+				res.Planned = append(res.Planned, "delete "+res.Output)
+			} else if err := os.Remove(res.Output); err != nil {
+				r.opts.Logf("failed to remove old rewritten file %s: %v", res.Output, err)
+			} else {
+				r.opts.Logf("🗑️  Deleted previous rewritten file %s", res.Output)
+			}
+
+			// Without a rewrite the original has to build again; the tree is left
+			// alone when writing to an overlay
+			if tagged && r.opts.OverlayDir == "" {
+				if r.opts.DryRun {
+					res.Planned = append(res.Planned, fmt.Sprintf("remove tag %q from %s", activation.OriginalTag, file))
+				} else if _, err := activation.RemoveTag(file, activation.OriginalTag); err != nil {
+					r.opts.Logf("failed to remove build tag from %s: %v", file, err)
+				} else {
+					r.opts.Logf("🏷️ Removed build tag from original file %s", file)
+				}
+			}
+		}
+		r.record(res, promptHash)
+		return res
+	}
+
+	output := StampHeader(rewrittenContent, stamp)
+	if r.opts.DryRun {
+		var old []byte
+		if exists {
+			if old, err = os.ReadFile(res.Output); err != nil {
+				return fail(StatusFailed, fmt.Errorf("failed to read %s: %w", res.Output, err))
+			}
+		}
+		if res.Diff, err = unifiedDiff(res.Output, res.Output, string(old), output); err != nil {
+			return fail(StatusFailed, fmt.Errorf("failed to diff %s: %w", res.Output, err))
+		}
+		res.Planned = append(res.Planned, "write "+res.Output)
+		res.Status = StatusRewritten
+		return res
+	}
+	if err := os.MkdirAll(filepath.Dir(res.Output), 0755); err != nil {
+		return fail(StatusFailed, fmt.Errorf("failed to create %s: %w", filepath.Dir(res.Output), err))
+	}
+	if err := os.WriteFile(res.Output, []byte(output), 0644); err != nil {
+		res.Status, res.Err = StatusFailed, fmt.Errorf("failed to write %s: %w", res.Output, err)
+		r.record(res, promptHash)
+		return res
+	}
+
+	r.opts.Logf("✅ Saved %s", res.Output)
+	res.Status, res.Hash = StatusRewritten, ContentHash([]byte(output))
+	r.record(res, promptHash)
+	if r.opts.OverlayDir == "" {
+		res.Problems = r.activate(filepath.Dir(newFile))
+	}
+	return res
+}
+
+// activate puts the package in dir into the configured activation mode, and
+// logs the symbols that collide or are missing in it.
+func (r *rewriter) activate(dir string) []activation.Problem {
+	r.activating.Lock()
+	defer r.activating.Unlock()
+
+	var problems []activation.Problem
+	if r.opts.Activation == "" {
+		pkg, err := activation.Inspect(dir)
+		if err != nil {
+			r.opts.Logf("failed to inspect package %s: %v", dir, err)
+			return nil
+		}
+		problems = pkg.Problems
+	} else {
+		changes, planned, err := activation.Apply(dir, r.opts.Activation, false)
+		for _, c := range changes {
+			r.opts.Logf("🏷️  %s", c)
+		}
+		if err != nil {
+			r.opts.Logf("⚠️  Not switching %s to %s: %v", dir, r.opts.Activation, err)
+		}
+		problems = planned
+	}
+	for _, p := range problems {
+		r.opts.Logf("⚠️  %s: %s", dir, p)
+	}
+	return problems
+}
+
+// rewriteDeterministic tries the mechanical rewrite of file. It reports false when
+// the file has to go to the model, which includes results that do not build.
+func (r *rewriter) rewriteDeterministic(file, newFile string, src []byte) (string, bool) {
+	out, err := RewriteDeterministic(file, src)
+	if errors.Is(err, ErrNotRecognized) {
+		return "", false
+	}
+	if err != nil {
+		r.opts.Logf("⚠️  Deterministic rewrite of %s failed, asking the model: %v", file, err)
+		return "", false
+	}
+	if processed, report, err := PostProcess(file, newFile, []byte(out)); err == nil {
+		if report.Changed() {
+			fmt.Println(ctestglobals.DebugPrefix(), "Post-processed", newFile+":", report)
+		}
+		out = string(processed)
+	}
+	formatted, err := CheckRewrite(newFile, []byte(out), r.opts.Validation.Vet)
+	if err != nil {
+		r.opts.Logf("⚠️  Deterministic rewrite of %s does not build, asking the model: %v", file, err)
+		return "", false
+	}
+	return string(formatted), true
+}
+
+// rewritePrompt sends one prompt of file with the few-shot examples of the prompt
+// set to the model.
+func (r *rewriter) rewritePrompt(ctx context.Context, client LLMClient, file, newFile, prompt string) (string, error) {
+	messages, err := r.opts.Prompts.Messages(prompt)
+	if err != nil {
+		return "", err
+	}
+	return RewriteWithRepair(ctx, client, file, newFile, messages, r.opts.Validation)
+}
+
+// rewriteChunks rewrites a large file one test at a time and merges the results.
+// A chunk that does not build is left out, the other tests are still rewritten.
+func (r *rewriter) rewriteChunks(ctx context.Context, client LLMClient, file, newFile string, prompts []string) (string, error) {
+	var parts []string
+	for ci, prompt := range prompts {
+		out, err := r.rewritePrompt(ctx, client, file, newFile, prompt)
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			r.opts.Logf("❌ Chunk %d/%d of %s does not build, leaving it out:\n%s", ci+1, len(prompts), file, strings.Join(validationErr.Problems, "\n"))
+			continue
+		}
+		if err != nil {
+			return out, fmt.Errorf("chunk %d/%d: %w", ci+1, len(prompts), err)
+		}
+		parts = append(parts, out)
+	}
+
+	merged, err := MergeRewrites(newFile, parts)
+	if err != nil {
+		return "", err
+	}
+	if merged == "NONE" {
+		return merged, nil
+	}
+	formatted, err := CheckRewrite(newFile, []byte(merged), r.opts.Validation.Vet)
+	if err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+func (r *rewriter) record(res FileResult, promptHash string) {
+	if r.opts.DryRun {
+		return
+	}
+	entry := JournalEntry{
+		File:       res.File,
+		Status:     res.Status,
+		Attempts:   res.Attempts,
+		Model:      r.client.Model(),
+		PromptHash: promptHash,
+		Prompt:     r.opts.Prompts.Version + "@" + r.opts.Prompts.Hash,
+		Requests:   res.Stats.Requests,
+		Tokens:     res.Stats.Tokens,
+		DoneReason: res.Stats.DoneReason,
+		DurationMs: res.Stats.Duration.Milliseconds(),
+		Output:     res.Output,
+		OutputHash: res.Hash,
+	}
+	if res.Hash == "" && res.Status != StatusNone {
+		// Nothing was written, the previous rewrite is still there
+		if prev, ok := r.journal.Get(res.File); ok && prev.Output == res.Output {
+			entry.OutputHash = prev.OutputHash
+		}
+	}
+	if res.Err != nil {
+		entry.Error = res.Err.Error()
+	}
+	if err := r.journal.Record(entry); err != nil {
+		r.opts.Logf("failed to save rewrite journal: %v", err)
+	}
+}
+
+// progressLogger logs the tokens received for a streamed reply of file at most
+// once per ProgressInterval.
+func (r *rewriter) progressLogger(file string) func(tokens int) {
+	if r.opts.ProgressInterval <= 0 {
+		return nil
+	}
+	start := time.Now()
+	last := start
+	return func(tokens int) {
+		if now := time.Now(); now.Sub(last) >= r.opts.ProgressInterval {
+			last = now
+			r.opts.Logf("⏳ %s: %d tokens in %s", file, tokens, now.Sub(start).Round(time.Second))
+		}
+	}
+}
+
+// retryingClient waits for the shared rate limiter before every request and
+// retries requests that failed for transient reasons.
+type retryingClient struct {
+	LLMClient
+	limiter  *rate.Limiter
+	opts     RewriteOptions
+	attempts int
+}
+
+func (c *retryingClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	backoff := c.opts.Backoff
+	for retry := 0; ; retry++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return "", err
+		}
+		c.attempts++
+		out, err := c.LLMClient.Chat(ctx, messages)
+		if err == nil || !isRetryable(err) || retry >= c.opts.MaxRetries {
+			return out, err
+		}
+
+		c.opts.Logf("🔁 LLM request failed (%v), retrying in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return out, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether a request may succeed when sent again: the server
+// was overloaded (HTTP 429, 5xx) or did not answer in time.
+func isRetryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+//------------------------------------------------
+// Helpers
+//------------------------------------------------
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
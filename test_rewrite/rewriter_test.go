@@ -0,0 +1,399 @@
+package testrewrite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"k8s.io/kubernetes/test/ctest/activation"
+)
+
+func TestRewriteFilesResumesFromJournal(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a_test.go"), filepath.Join(dir, "b_test.go")}
+	for name, content := range map[string]string{
+		"go.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+		"b_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fakeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte("NONE"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RewriteOptions{
+		Workers:     2,
+		JournalPath: filepath.Join(t.TempDir(), "journal.json"),
+		Logf:        t.Logf,
+	}
+
+	summary, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), files, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if summary.Skipped != 2 {
+		t.Fatalf("expected 2 files without rewrite, got %+v", summary)
+	}
+
+	journal, err := OpenJournal(opts.JournalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	entry, ok := journal.Get(files[0])
+	if !ok || entry.Status != StatusNone || entry.Model != "fake" || entry.Attempts != 1 || entry.PromptHash == "" {
+		t.Errorf("unexpected journal entry: %+v", entry)
+	}
+
+	// A second run finds everything done in the journal
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), files, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if summary.AlreadyRewritten != 2 {
+		t.Errorf("expected the second run to resume from the journal, got %+v", summary)
+	}
+}
+
+func TestRewriteFilesKeepsExistingRewrites(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	edited := "package foo\n\nimport \"testing\"\n\n// edited by hand\nfunc TestCtestA(t *testing.T) {}\n"
+	for name, content := range map[string]string{
+		"go.mod":          "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go":       "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+		"ctest_a_test.go": edited,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeDir := t.TempDir()
+	rewrite := "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n"
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte(rewrite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Recorded with another prompt, e.g. before the template changed
+	opts := RewriteOptions{JournalPath: filepath.Join(t.TempDir(), "journal.json"), Logf: t.Logf}
+	journal, err := OpenJournal(opts.JournalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Record(JournalEntry{File: file, Status: StatusRewritten, Model: "fake", PromptHash: "old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "ctest_a_test.go")); summary.AlreadyRewritten != 1 || string(got) != edited {
+		t.Errorf("existing rewrite replaced without Overwrite, got %+v:\n%s", summary, got)
+	}
+
+	opts.Overwrite = true
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if summary.Rewritten != 1 {
+		t.Errorf("expected the stale rewrite to be replaced with Overwrite, got %+v", summary)
+	}
+}
+
+func TestRewriteFilesKeepsOutputHash(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	for name, content := range map[string]string{
+		"go.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	good, broken := t.TempDir(), t.TempDir()
+	for d, reply := range map[string]string{
+		good:   "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n",
+		broken: "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) { _ = Missing() }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(d, "default.txt"), []byte(reply), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := RewriteOptions{JournalPath: filepath.Join(t.TempDir(), "journal.json"), Logf: t.Logf}
+	summary, err := RewriteFiles(context.Background(), NewFakeClient(good, "fake"), []string{file}, opts)
+	if err != nil || summary.Rewritten != 1 {
+		t.Fatalf("RewriteFiles = %+v, %v", summary, err)
+	}
+	hash := summary.Results[0].Hash
+
+	// Another model fails to replace it, the rewrite and its hash stay
+	opts.Overwrite = true
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(broken, "other"), []string{file}, opts)
+	if err != nil || summary.Invalid != 1 {
+		t.Fatalf("RewriteFiles = %+v, %v", summary, err)
+	}
+	journal, err := OpenJournal(opts.JournalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := journal.Get(file); entry.Status != StatusInvalid || entry.OutputHash != hash {
+		t.Errorf("failed attempt dropped the hash of the rewrite: %+v", entry)
+	}
+}
+
+func TestRewriteFilesCanceled(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	if err := os.WriteFile(file, []byte("package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RewriteOptions{JournalPath: filepath.Join(t.TempDir(), "journal.json"), Logf: t.Logf}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary, err := RewriteFiles(ctx, NewFakeClient(t.TempDir(), "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if summary.Canceled != 1 || summary.Failed != 0 {
+		t.Errorf("expected the file to be canceled, got %+v", summary)
+	}
+	journal, err := OpenJournal(opts.JournalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	if entry, ok := journal.Get(file); ok {
+		t.Errorf("canceled file recorded in the journal: %+v", entry)
+	}
+}
+
+func TestRewriteFilesDryRunAndOverlay(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "pkg")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "a_test.go")
+	for name, content := range map[string]string{
+		"go.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeDir := t.TempDir()
+	rewrite := "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n"
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte(rewrite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journalPath := filepath.Join(t.TempDir(), "journal.json")
+	opts := RewriteOptions{JournalPath: journalPath, CacheDir: t.TempDir(), DryRun: true, Logf: t.Logf}
+
+	summary, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	res := summary.Results[0]
+	if res.Status != StatusRewritten || len(res.Planned) != 1 || res.Planned[0] != "write "+filepath.Join(dir, "ctest_a_test.go") {
+		t.Errorf("unexpected dry run result %+v", res)
+	}
+	if !strings.Contains(res.Diff, "+func TestCtestA(t *testing.T) {}") {
+		t.Errorf("unexpected diff:\n%s", res.Diff)
+	}
+	for _, path := range []string{res.NewFile, journalPath} {
+		if fileExists(path) {
+			t.Errorf("dry run wrote %s", path)
+		}
+	}
+	if entries, _ := os.ReadDir(opts.CacheDir); len(entries) != 0 {
+		t.Errorf("dry run filled the cache")
+	}
+
+	overlayDir := t.TempDir()
+	opts.DryRun, opts.OverlayDir, opts.Root = false, overlayDir, root
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	res = summary.Results[0]
+	if want := filepath.Join(overlayDir, "pkg", "ctest_a_test.go"); res.Output != want || !fileExists(want) || fileExists(res.NewFile) {
+		t.Fatalf("expected the rewrite in the overlay only, got %+v", res)
+	}
+	journal, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, _ := os.ReadFile(res.Output)
+	if entry, _ := journal.Get(file); entry.OutputHash != ContentHash(written) || res.Hash != entry.OutputHash {
+		t.Errorf("journal does not record the hash of the rewrite: %+v", entry)
+	}
+	data, err := os.ReadFile(filepath.Join(overlayDir, OverlayFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var overlay overlayJSON
+	if err := json.Unmarshal(data, &overlay); err != nil || overlay.Replace[res.NewFile] != res.Output {
+		t.Errorf("unexpected overlay %s, %v", data, err)
+	}
+	if report := BuildReviewReport(summary.Results, false); len(report.Files) != 1 || !report.Files[0].Compiles {
+		t.Errorf("overlay rewrite not reviewed in its package: %+v", report.Files)
+	}
+
+	// The journal entry of the overlay does not make the tree done, nor the
+	// other way round
+	opts.OverlayDir = ""
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if res = summary.Results[0]; res.Status != StatusRewritten || !fileExists(res.NewFile) {
+		t.Fatalf("expected the rewrite in the tree after an overlay run, got %+v", res)
+	}
+	overlayDir = t.TempDir()
+	opts.OverlayDir = overlayDir
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if res = summary.Results[0]; res.Status != StatusRewritten || !fileExists(res.Output) {
+		t.Fatalf("expected the rewrite in the overlay after a tree run, got %+v", res)
+	}
+	if data, err = os.ReadFile(filepath.Join(overlayDir, OverlayFile)); err != nil || !strings.Contains(string(data), "ctest_a_test.go") {
+		t.Errorf("overlay file misses the rewrite: %s, %v", data, err)
+	}
+}
+
+func TestRetryingClient(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"ok"}}`))
+	}))
+	defer server.Close()
+
+	client := &retryingClient{
+		LLMClient: NewOllamaClient(LLMConfig{Host: server.URL}),
+		limiter:   rate.NewLimiter(rate.Inf, 1),
+		opts:      RewriteOptions{MaxRetries: 3, Backoff: time.Millisecond, Logf: t.Logf},
+	}
+	out, err := client.Chat(context.Background(), rewriteMessages(t, "x"))
+	if err != nil || out != "ok" {
+		t.Fatalf("Chat = %q, %v", out, err)
+	}
+	if client.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", client.attempts)
+	}
+
+	client.opts.MaxRetries = 0
+	calls = 0
+	if _, err := client.Chat(context.Background(), rewriteMessages(t, "x")); err == nil {
+		t.Errorf("expected the error to be returned without retries")
+	}
+}
+
+func TestRewriteFilesActivation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	for name, content := range map[string]string{
+		"go.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { _ = pod() }\n\nfunc pod() int { return 1 }\n",
+		"b_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) { _ = pod() }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeDir := t.TempDir()
+	rewrite := "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) { _ = pod() }\n"
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte(rewrite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RewriteOptions{JournalPath: filepath.Join(t.TempDir(), "journal.json"), Activation: "Replace", Logf: t.Logf}
+
+	// b_test.go and the rewrite still need pod from the original
+	summary, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	res := summary.Results[0]
+	if res.Status != StatusRewritten || summary.Problems != 1 || len(res.Problems) != 1 || res.Problems[0].Name != "pod" {
+		t.Fatalf("expected the missing pod to be reported, got %+v", res)
+	}
+	if src, _ := os.ReadFile(file); activation.HasTag(src, activation.OriginalTag) {
+		t.Errorf("original disabled although the package would not build:\n%s", src)
+	}
+
+	rewrite = "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n"
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte(rewrite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b_test.go")); err != nil {
+		t.Fatal(err)
+	}
+	opts.Overwrite = true
+	summary, err = RewriteFiles(context.Background(), NewFakeClient(fakeDir, "other"), []string{file}, opts)
+	if err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if res := summary.Results[0]; res.Status != StatusRewritten || len(res.Problems) != 0 {
+		t.Errorf("expected the package to be switched to replace, got %+v", res)
+	}
+	if src, _ := os.ReadFile(file); !activation.HasTag(src, activation.OriginalTag) {
+		t.Errorf("original not disabled:\n%s", src)
+	}
+
+	opts.Activation = "both"
+	if _, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts); err == nil {
+		t.Errorf("expected an unknown activation mode to be rejected")
+	}
+}
+
+func TestRewriteFilesIgnoresOriginalTag(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a_test.go")
+	for name, content := range map[string]string{
+		"go.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeDir := t.TempDir()
+	rewrite := "package foo\n\nimport \"testing\"\n\nfunc TestCtestA(t *testing.T) {}\n"
+	if err := os.WriteFile(filepath.Join(fakeDir, "default.txt"), []byte(rewrite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RewriteOptions{JournalPath: filepath.Join(t.TempDir(), "journal.json"), Activation: "replace", Logf: t.Logf}
+	if _, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts); err != nil {
+		t.Fatalf("RewriteFiles failed: %v", err)
+	}
+	if src, _ := os.ReadFile(file); !activation.HasTag(src, activation.OriginalTag) {
+		t.Fatalf("original not disabled:\n%s", src)
+	}
+
+	// Tagging the original changes neither the prompts nor their hash
+	opts.Overwrite = true
+	summary, err := RewriteFiles(context.Background(), NewFakeClient(fakeDir, "fake"), []string{file}, opts)
+	if err != nil || summary.AlreadyRewritten != 1 {
+		t.Fatalf("expected the journal to skip the tagged original, got %+v, %v", summary, err)
+	}
+}
@@ -0,0 +1,218 @@
+package testrewrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// ValidationOptions controls how rewritten files are checked before they are saved.
+type ValidationOptions struct {
+	// RepairAttempts is how many times build errors are sent back to the model.
+	RepairAttempts int
+	// Vet also runs go vet on the package of the rewritten file.
+	Vet bool
+}
+
+// ValidationOptionsFromEnv reads REWRITE_REPAIR_ATTEMPTS (default 2) and
+// REWRITE_VET (default true).
+func ValidationOptionsFromEnv() ValidationOptions {
+	opts := ValidationOptions{RepairAttempts: 2, Vet: true}
+	if v, err := strconv.Atoi(os.Getenv("REWRITE_REPAIR_ATTEMPTS")); err == nil && v >= 0 {
+		opts.RepairAttempts = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("REWRITE_VET")); err == nil {
+		opts.Vet = v
+	}
+	return opts
+}
+
+// ValidationError lists the problems that keep a rewritten file from building.
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s does not build:\n%s", e.File, strings.Join(e.Problems, "\n"))
+}
+
+// CleanLLMOutput removes markdown code fences and any prose around them.
+func CleanLLMOutput(out string) string {
+	out = strings.TrimSpace(out)
+	if start := strings.Index(out, "```"); start >= 0 {
+		body := out[start+3:]
+		// Drop the language tag of the opening fence
+		if nl := strings.Index(body, "\n"); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.LastIndex(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		out = strings.TrimSpace(body)
+	}
+	return out + "\n"
+}
+
+// CheckRewrite formats src and type-checks the package of newFile as if src was
+// saved there, without touching the file on disk. With vet it also runs go vet.
+// It returns the formatted source, or a *ValidationError.
+func CheckRewrite(newFile string, src []byte, vet bool) ([]byte, error) {
+	absFile, err := filepath.Abs(newFile)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, &ValidationError{File: newFile, Problems: []string{err.Error()}}
+	}
+
+	problems, err := typeCheck(absFile, formatted)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) == 0 && vet {
+		if problems, err = vetFile(absFile, formatted); err != nil {
+			return nil, err
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{File: newFile, Problems: problems}
+	}
+	return formatted, nil
+}
+
+// RewriteWithRepair sends messages (built from originalFile, see
+// PromptSet.Messages) to the model to rewrite it into newFile, post-processes the
+// reply and sends the build errors back until the result builds or the attempts
+// are used up. It returns "NONE" when the model has nothing to rewrite, and the
+// partial reply of a request interrupted midway with its error.
+func RewriteWithRepair(ctx context.Context, client LLMClient, originalFile, newFile string, messages []Message, opts ValidationOptions) (string, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= opts.RepairAttempts; attempt++ {
+		reply, err := client.Chat(ctx, messages)
+		if err != nil {
+			return reply, err
+		}
+		if strings.TrimSpace(reply) == "" || strings.TrimSpace(reply) == "NONE" {
+			return "NONE", nil
+		}
+
+		src := []byte(CleanLLMOutput(reply))
+		processed, report, err := PostProcess(originalFile, newFile, src)
+		if err == nil {
+			if report.Changed() {
+				fmt.Println(ctestglobals.DebugPrefix(), "Post-processed", newFile+":", report)
+			}
+			src = processed
+		}
+
+		formatted, err := CheckRewrite(newFile, src, opts.Vet)
+		if err == nil {
+			return string(formatted), nil
+		}
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			return "", err
+		}
+		lastErr = validationErr
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: reply},
+			Message{Role: "user", Content: repairPrompt(validationErr)},
+		)
+	}
+	return "", lastErr
+}
+
+func repairPrompt(err *ValidationError) string {
+	return fmt.Sprintf(`The Go file you returned does not compile. Fix these errors and return the complete corrected file.
+Output only Go code, without markdown fences.
+
+%s`, strings.Join(err.Problems, "\n"))
+}
+
+// typeCheck loads the package of file with src overlaid and returns the errors
+// reported for file. Errors elsewhere in the package were there before the rewrite.
+func typeCheck(file string, src []byte) ([]string, error) {
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports,
+		Dir:     filepath.Dir(file),
+		Tests:   true,
+		Overlay: map[string][]byte{file: src},
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package of %s: %w", file, err)
+	}
+
+	base := filepath.Base(file)
+	seen := make(map[string]bool)
+	var problems []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			msg := e.Error()
+			if seen[msg] || !(strings.Contains(e.Pos, base) || strings.Contains(e.Msg, base)) {
+				continue
+			}
+			seen[msg] = true
+			problems = append(problems, msg)
+		}
+	})
+	return problems, nil
+}
+
+// vetFile runs go vet on the package of file with src overlaid and returns the
+// findings for file.
+func vetFile(file string, src []byte) ([]string, error) {
+	tmpDir, err := os.MkdirTemp("", "ctest-vet-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcPath := filepath.Join(tmpDir, filepath.Base(file))
+	if err := os.WriteFile(srcPath, src, 0644); err != nil {
+		return nil, err
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{"Replace": {file: srcPath}})
+	if err != nil {
+		return nil, err
+	}
+	overlayPath := filepath.Join(tmpDir, "overlay.json")
+	if err := os.WriteFile(overlayPath, overlay, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("go", "vet", "-overlay="+overlayPath, ".")
+	cmd.Dir = filepath.Dir(file)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err == nil {
+		return nil, nil
+	} else if _, ok := err.(*exec.ExitError); !ok {
+		return nil, fmt.Errorf("failed to run go vet: %w", err)
+	}
+
+	base := filepath.Base(file)
+	var problems []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, base) {
+			problems = append(problems, strings.TrimSpace(line))
+		}
+	}
+	return problems, nil
+}
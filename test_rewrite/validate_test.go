@@ -0,0 +1,73 @@
+package testrewrite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedClient returns its replies in order and records the conversations.
+type scriptedClient struct {
+	replies []string
+	calls   [][]Message
+}
+
+func (c *scriptedClient) Model() string { return "scripted" }
+
+func (c *scriptedClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	c.calls = append(c.calls, messages)
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	return reply, nil
+}
+
+func TestCleanLLMOutput(t *testing.T) {
+	out := CleanLLMOutput("Here is the file:\n```go\npackage foo\n\nfunc A() {}\n```\nDone.")
+	if out != "package foo\n\nfunc A() {}\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestRewriteWithRepair(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":      "module example.com/foo\n\ngo 1.21\n",
+		"foo.go":      "package foo\n\nfunc Double(i int) int { return 2 * i }\n",
+		"foo_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestDouble(t *testing.T) {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newFile := filepath.Join(dir, "ctest_foo_test.go")
+
+	broken := "```go\npackage foo\n\nimport \"testing\"\n\nfunc TestCtestDouble(t *testing.T) { _ = Triple(1) }\n```"
+	fixed := "package foo\n\nimport \"testing\"\n\nfunc TestCtestDouble(t *testing.T) { _ = Double(1) }\n"
+	client := &scriptedClient{replies: []string{broken, fixed}}
+
+	out, err := RewriteWithRepair(context.Background(), client, filepath.Join(dir, "foo_test.go"), newFile, rewriteMessages(t, "prompt"), ValidationOptions{RepairAttempts: 1})
+	if err != nil {
+		t.Fatalf("RewriteWithRepair failed: %v", err)
+	}
+	if !strings.Contains(out, "Double(1)") {
+		t.Errorf("expected the repaired file, got:\n%s", out)
+	}
+	if len(client.calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(client.calls))
+	}
+	repair := client.calls[1][len(client.calls[1])-1].Content
+	if !strings.Contains(repair, "Triple") {
+		t.Errorf("repair prompt does not contain the compiler error:\n%s", repair)
+	}
+	if _, err := os.Stat(newFile); !os.IsNotExist(err) {
+		t.Errorf("rewritten file must not be written by the check")
+	}
+
+	client = &scriptedClient{replies: []string{broken}}
+	_, err = RewriteWithRepair(context.Background(), client, filepath.Join(dir, "foo_test.go"), newFile, rewriteMessages(t, "prompt"), ValidationOptions{})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Errorf("expected a ValidationError without repair attempts, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package walker
+
+import (
+	"strings"
+
+	ctestglobals "k8s.io/kubernetes/test/ctest/ctestglobals"
+)
+
+// commonRules are left out of every walk: version control, vendored and
+// generated code.
+const commonRules = `
+.git/
+vendor/
+_output/
+third_party/
+`
+
+// testRules leave out the test helpers, which are not rewritten, the ctest
+// tooling and the files rewritten already.
+const testRules = `
+.github/
+framework/
+utils/
+ctest/
+framework.go
+utils.go
+doc.go
+util.go
+*ctest_*
+`
+
+// repoTestRules, relative to the Kubernetes root, keep the _test.go files of the
+// top-level directories with tests, and every file under test/.
+const repoTestRules = `
+*
+!*/
+!*_test.go
+!/test/**
+
+/*/
+!/cluster/
+!/cmd/
+!/pkg/
+!/plugin/
+!/staging/
+!/test/
+
+# Sample servers outside test/ are left alone
+sample-*/
+!/test/**/sample-*/
+`
+
+var (
+	// Fixtures are the rules of the repositories scanned for fixtures: chart
+	// templates are not valid YAML, CI files hold no Kubernetes objects, see
+	// ctestglobals.WeirdPaths.
+	Fixtures = MustParseRules(".git/\ntemplates/\n" + anyDepth(ctestglobals.WeirdPaths))
+	// Tests are the rules of a tree of tests to rewrite.
+	Tests = MustParseRules(commonRules + testRules)
+	// RepoTests are the rules of the tests to rewrite anywhere in the
+	// Kubernetes tree; they are relative to the Kubernetes root.
+	RepoTests = MustParseRules(repoTestRules).Append(Tests)
+	// Rewrites are the rules of a tree searched for ctest_ files and the tests
+	// they replace.
+	Rewrites = MustParseRules(commonRules + "ctest/\n")
+)
+
+// anyDepth returns patterns matching paths ending in one of paths.
+func anyDepth(paths []string) string {
+	var b strings.Builder
+	for _, p := range paths {
+		b.WriteString("**/" + strings.Trim(p, "/") + "\n")
+	}
+	return b.String()
+}
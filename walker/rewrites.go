@@ -0,0 +1,25 @@
+package walker
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RewritePrefix starts the name of the rewrite of a test file, which is written
+// next to it.
+const RewritePrefix = "ctest_"
+
+// IsRewrite reports whether the file name is that of a rewrite.
+func IsRewrite(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), RewritePrefix)
+}
+
+// RewriteOf returns the path of the rewrite of the test file original.
+func RewriteOf(original string) string {
+	return filepath.Join(filepath.Dir(original), RewritePrefix+filepath.Base(original))
+}
+
+// OriginalOf returns the path of the test file the rewrite replaces.
+func OriginalOf(rewrite string) string {
+	return filepath.Join(filepath.Dir(rewrite), strings.TrimPrefix(filepath.Base(rewrite), RewritePrefix))
+}
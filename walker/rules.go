@@ -0,0 +1,201 @@
+package walker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Rules is an ordered list of gitignore-style patterns, one per line:
+//
+//   - blank lines and lines starting with "#" are ignored
+//   - a leading "!" re-includes what an earlier pattern ignored
+//   - a trailing "/" only matches directories
+//   - a pattern with a "/" at the start or in the middle is matched against the
+//     whole path relative to the rules, one without only against the name, at
+//     any depth
+//   - "*", "?" and "[...]" match within a segment, see path.Match, and a "**"
+//     segment matches any number of segments
+//
+// The last pattern matching a path decides whether it is ignored. Like git,
+// nothing inside an ignored directory can be re-included.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	text     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+// ParseRules parses the patterns of text.
+func ParseRules(text string) (*Rules, error) {
+	r := &Rules{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		if err := r.add(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return r, scanner.Err()
+}
+
+// MustParseRules is ParseRules for the built-in rule sets; it panics on errors.
+func MustParseRules(text string) *Rules {
+	r, err := ParseRules(text)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LoadRules parses the rules file at path. A missing file has no rules.
+func LoadRules(path string) (*Rules, error) {
+	r, err := loadRulesFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Rules) add(line string) error {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	ru := rule{text: line}
+	if strings.HasPrefix(line, "!") {
+		ru.negate, line = true, line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		ru.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		ru.anchored, line = true, strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return fmt.Errorf("empty pattern %q", ru.text)
+	}
+	if _, err := path.Match(strings.ReplaceAll(line, "**", "*"), ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", ru.text, err)
+	}
+	ru.segments = strings.Split(line, "/")
+	r.rules = append(r.rules, ru)
+	return nil
+}
+
+// Append returns the rules of r followed by those of others, which take
+// precedence.
+func (r *Rules) Append(others ...*Rules) *Rules {
+	out := &Rules{}
+	for _, o := range append([]*Rules{r}, others...) {
+		if o != nil {
+			out.rules = append(out.rules, o.rules...)
+		}
+	}
+	return out
+}
+
+// Len returns the number of patterns.
+func (r *Rules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// match returns whether rel itself is ignored, and whether any pattern matched
+// it at all.
+func (r *Rules) match(rel string, isDir bool) (ignored, matched bool) {
+	if r == nil {
+		return false, false
+	}
+	rel = strings.Trim(rel, "/")
+	for _, ru := range r.rules {
+		if ru.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if ru.anchored {
+			ok = matchSegments(ru.segments, strings.Split(rel, "/"))
+		} else {
+			ok, _ = path.Match(ru.segments[0], path.Base(rel))
+		}
+		if ok {
+			ignored, matched = !ru.negate, true
+		}
+	}
+	return ignored, matched
+}
+
+// Select reports whether the slash-separated path rel passes the include and
+// exclude globs, see MatchGlob: it has to match one of include, unless there
+// are none, and none of exclude.
+func Select(rel string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if MatchGlob(pattern, rel) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckGlobs returns an error for the first malformed glob of patterns.
+func CheckGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchGlob reports whether the slash-separated path rel matches pattern. The
+// pattern is matched segment by segment with path.Match, where a "**" segment
+// matches any number of segments. A pattern without a slash matches the file
+// name alone, so "ctest_*_test.go" matches at any depth.
+func MatchGlob(pattern, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
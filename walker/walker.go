@@ -0,0 +1,275 @@
+// Package walker walks the trees the ctest tools work on: the repositories
+// scanned for fixtures, the tests to rewrite and the rewritten ctest_ files.
+//
+// What a walk leaves out is declared as gitignore-style Rules: one of the
+// built-in sets (Fixtures, Tests, RepoTests, Rewrites) plus the patterns of the
+// .ctestignore files found in the tree, see IgnoreFile.
+package walker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile holds extra rules for the directory it is in and everything below.
+// Its patterns are relative to that directory and take precedence over the rules
+// of the walk and of the ignore files further up.
+const IgnoreFile = ".ctestignore"
+
+// GitIgnoreFile is read like IgnoreFile when Walker.GitIgnore is set, before the
+// IgnoreFile of the same directory.
+const GitIgnoreFile = ".gitignore"
+
+// Walker walks a tree, leaving out what its rules ignore. Ignored directories
+// are not entered.
+type Walker struct {
+	// Rules are matched against the paths relative to Base.
+	Rules *Rules
+	// Base is the directory the rules are relative to, usually the Kubernetes
+	// root; empty means the walked root. The ignore files between Base and the
+	// walked root apply as well.
+	Base string
+	// FS is walked instead of the local file system when set. Paths, Base
+	// included, are then slash-separated paths of FS, see fs.ValidPath.
+	FS fs.FS
+	// GitIgnore also applies the .gitignore files of the tree.
+	GitIgnore bool
+	// SkipErrors leaves out what cannot be read instead of failing the walk.
+	SkipErrors bool
+
+	ignoreFiles map[string]*Rules
+}
+
+// New returns a walker with rules relative to the walked root.
+func New(rules *Rules) *Walker {
+	return &Walker{Rules: rules}
+}
+
+// tree is a walk resolved against Base: fsys is rooted at base, and root is the
+// slash-separated path of the walked root in it, "." for base itself.
+type tree struct {
+	fsys fs.FS
+	base string
+	root string
+}
+
+// path returns the path fn gets for the path p of the tree.
+func (t *tree) path(w *Walker, p string) string {
+	if w.FS != nil {
+		return path.Join(t.base, p)
+	}
+	return filepath.Join(t.base, filepath.FromSlash(p))
+}
+
+// Walk calls fn for every file under root that is not ignored, in lexical
+// order. When root is a file, fn is called for it alone unless it is ignored.
+func (w *Walker) Walk(root string, fn func(path string, d fs.DirEntry) error) error {
+	t, err := w.resolve(root)
+	if err != nil {
+		return err
+	}
+	if t.root != "." {
+		ignored, err := w.ignoredPath(t, t.root)
+		if err != nil || ignored {
+			return err
+		}
+	}
+	return fs.WalkDir(t.fsys, t.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if w.SkipErrors {
+				return nil
+			}
+			return err
+		}
+		if p != t.root {
+			ignored, err := w.ignored(t, p, d.IsDir())
+			if err != nil {
+				return err
+			}
+			if ignored {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return fn(t.path(w, p), d)
+	})
+}
+
+// Files returns the files under root that are not ignored and end in one of
+// suffixes, all files without suffixes.
+func (w *Walker) Files(root string, suffixes ...string) ([]string, error) {
+	var files []string
+	err := w.Walk(root, func(path string, d fs.DirEntry) error {
+		if len(suffixes) == 0 || hasSuffix(d.Name(), suffixes) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Ignored reports whether path, or one of its parent directories below Base, is
+// ignored. Paths outside Base are never ignored.
+func (w *Walker) Ignored(path string) (bool, error) {
+	t, err := w.resolve(path)
+	if err != nil || t.root == "." {
+		return false, err
+	}
+	return w.ignoredPath(t, t.root)
+}
+
+// ignoredPath checks p and every parent directory of it in t.
+func (w *Walker) ignoredPath(t *tree, p string) (bool, error) {
+	parts := strings.Split(p, "/")
+	for i := 1; i <= len(parts); i++ {
+		sub := strings.Join(parts[:i], "/")
+		isDir := i < len(parts)
+		if !isDir {
+			info, err := fs.Stat(t.fsys, sub)
+			isDir = err == nil && info.IsDir()
+		}
+		ignored, err := w.ignored(t, sub, isDir)
+		if err != nil || ignored {
+			return ignored, err
+		}
+	}
+	return false, nil
+}
+
+// resolve returns the tree of a walk of root: rooted at Base when root is under
+// it, else at root or the directory of a root file.
+func (w *Walker) resolve(root string) (*tree, error) {
+	if w.FS != nil {
+		return w.resolveFS(root)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	base := root
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		base = filepath.Dir(root)
+	}
+	if w.Base != "" {
+		if b, err := filepath.Abs(w.Base); err == nil {
+			if rel, err := filepath.Rel(b, root); err == nil && !strings.HasPrefix(rel, "..") {
+				base = b
+			}
+		}
+	}
+	rel, err := filepath.Rel(base, root)
+	if err != nil {
+		return nil, err
+	}
+	return &tree{fsys: os.DirFS(base), base: base, root: filepath.ToSlash(rel)}, nil
+}
+
+func (w *Walker) resolveFS(root string) (*tree, error) {
+	root = path.Clean(root)
+	if !fs.ValidPath(root) {
+		return nil, &fs.PathError{Op: "walk", Path: root, Err: fs.ErrInvalid}
+	}
+	base := root
+	if info, err := fs.Stat(w.FS, root); err == nil && !info.IsDir() {
+		base = path.Dir(root)
+	}
+	if b := path.Clean(w.Base); w.Base != "" && (b == "." || root == b || strings.HasPrefix(root, b+"/")) {
+		base = b
+	}
+	fsys, err := fs.Sub(w.FS, base)
+	if err != nil {
+		return nil, err
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(root, base), "/")
+	if base == "." {
+		rel = root
+	}
+	if rel == "" {
+		rel = "."
+	}
+	return &tree{fsys: fsys, base: base, root: rel}, nil
+}
+
+// ignored decides the path p of t by the walk rules and then by the ignore
+// files from the base of t down to the directory of p.
+func (w *Walker) ignored(t *tree, p string, isDir bool) (bool, error) {
+	ignored, _ := w.Rules.match(p, isDir)
+
+	dir := "."
+	parts := strings.Split(p, "/")
+	for i := 0; i < len(parts); i++ {
+		if i > 0 {
+			dir = path.Join(dir, parts[i-1])
+		}
+		rules, err := w.ignoreFile(t, dir)
+		if err != nil {
+			return false, err
+		}
+		if rules.Len() == 0 {
+			continue
+		}
+		if dirIgnored, matched := rules.match(strings.Join(parts[i:], "/"), isDir); matched {
+			ignored = dirIgnored
+		}
+	}
+	return ignored, nil
+}
+
+// ignoreFile returns the rules of the ignore files in the directory dir of t.
+func (w *Walker) ignoreFile(t *tree, dir string) (*Rules, error) {
+	key := t.path(w, dir)
+	if rules, ok := w.ignoreFiles[key]; ok {
+		return rules, nil
+	}
+	names := []string{IgnoreFile}
+	if w.GitIgnore {
+		names = []string{GitIgnoreFile, IgnoreFile}
+	}
+	rules := &Rules{}
+	for _, name := range names {
+		r, err := loadRulesFS(t.fsys, path.Join(dir, name))
+		if err != nil && !w.SkipErrors {
+			return nil, fmt.Errorf("failed to load ignore file: %w", err)
+		}
+		rules = rules.Append(r)
+	}
+	if w.ignoreFiles == nil {
+		w.ignoreFiles = make(map[string]*Rules)
+	}
+	w.ignoreFiles[key] = rules
+	return rules, nil
+}
+
+func loadRulesFS(fsys fs.FS, name string) (*Rules, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Rules{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseRules(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return r, nil
+}
+
+func hasSuffix(name string, suffixes []string) bool {
+	for _, s := range suffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// relFiles returns the files the walker finds under root, relative to root.
+func relFiles(t *testing.T, w *Walker, root, walkRoot string, suffixes ...string) []string {
+	t.Helper()
+	files, err := w.Files(walkRoot, suffixes...)
+	if err != nil {
+		t.Fatalf("Files(%s) failed: %v", walkRoot, err)
+	}
+	var rels []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f)
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	return rels
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"ctest_*_test.go", "test/e2e/apps/ctest_job_test.go", true},
+		{"test/e2e/**", "test/e2e/apps/ctest_job_test.go", true},
+		{"test/**/apps/*", "test/e2e/apps/ctest_job_test.go", true},
+		{"test/**/apps/*", "test/apps/ctest_job_test.go", true},
+		{"test/e2e/*", "test/e2e/apps/ctest_job_test.go", false},
+		{"**/framework/**", "test/e2e/framework/ctest_x_test.go", true},
+		{"pkg/**", "test/e2e/apps/ctest_job_test.go", false},
+	} {
+		if got := MatchGlob(tc.pattern, tc.rel); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.rel, got, tc.want)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules := MustParseRules(`
+# comment
+*.log
+!keep.log
+build/
+/root.txt
+docs/**/*.md
+\#hash
+`)
+	for _, tc := range []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"a/b/x.log", false, true},
+		{"a/keep.log", false, false},
+		{"a/build", true, true},
+		{"a/build", false, false},
+		{"root.txt", false, true},
+		{"a/root.txt", false, false},
+		{"docs/a/b/x.md", false, true},
+		{"docs/x.md", false, true},
+		{"src/docs/x.md", false, false},
+		{"#hash", false, true},
+	} {
+		if got, _ := rules.match(tc.rel, tc.isDir); got != tc.want {
+			t.Errorf("match(%q, dir=%v) = %v, want %v", tc.rel, tc.isDir, got, tc.want)
+		}
+	}
+	if _, err := ParseRules("[x"); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestWalkIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		IgnoreFile:                 "*.tmp\n",
+		"a/x.go":                   "",
+		"a/x.tmp":                  "",
+		"a/vendor/v.go":            "",
+		"b/" + IgnoreFile:          "!*.tmp\ngen/\n",
+		"b/y.tmp":                  "",
+		"b/gen/z.go":               "",
+		"b/c/gen/z.go":             "",
+		"b/c/ctest_w_test.go":      "",
+		"b/c/w_test.go":            "",
+		"d/ctest/ctest_globals.go": "",
+		"d/ctest_original_test.go": "",
+		"d/framework/framework.go": "",
+		"d/framework/fr_test.go":   "",
+		"d/doc.go":                 "",
+	})
+
+	got := relFiles(t, New(Rewrites), root, root, ".go", ".tmp")
+	want := []string{"a/x.go", "b/c/ctest_w_test.go", "b/c/w_test.go", "b/y.tmp", "d/ctest_original_test.go", "d/doc.go", "d/framework/fr_test.go", "d/framework/framework.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rewrites walk = %v, want %v", got, want)
+	}
+
+	got = relFiles(t, New(Tests), root, root, ".go")
+	want = []string{"a/x.go", "b/c/w_test.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tests walk = %v, want %v", got, want)
+	}
+
+	// The ignore file of root applies to a walk of b/c below it, and a root that
+	// is ignored itself has no files
+	w := &Walker{Rules: Tests, Base: root}
+	if got = relFiles(t, w, root, filepath.Join(root, "b", "c", "gen")); got != nil {
+		t.Errorf("walk of an ignored root = %v", got)
+	}
+	if ignored, err := w.Ignored(filepath.Join(root, "a", "x.tmp")); err != nil || !ignored {
+		t.Errorf("Ignored(a/x.tmp) = %v, %v", ignored, err)
+	}
+	if got = relFiles(t, New(Tests), root, filepath.Join(root, "d", "doc.go")); got != nil {
+		t.Errorf("walk of an ignored file = %v", got)
+	}
+}
+
+func TestWalkFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":             {Data: []byte("build/\n")},
+		"app/" + IgnoreFile:      {Data: []byte("!build/\n")},
+		"app/build/a.yaml":       {},
+		"build/b.yaml":           {},
+		"chart/templates/c.yaml": {},
+		"deploy/d.yml":           {},
+	}
+	w := &Walker{Rules: Fixtures, FS: fsys, GitIgnore: true}
+	files, err := w.Files(".", ".yaml", ".yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app/build/a.yaml", "deploy/d.yml"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("walk with .gitignore = %v, want %v", files, want)
+	}
+	w = &Walker{Rules: Fixtures, FS: fsys}
+	if files, _ = w.Files("build"); !reflect.DeepEqual(files, []string{"build/b.yaml"}) {
+		t.Errorf("walk without .gitignore = %v", files)
+	}
+}
+
+func TestRepoTests(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"api/a_test.go":                          "",
+		"pkg/p.go":                               "",
+		"pkg/p_test.go":                          "",
+		"pkg/ctest_p_test.go":                    "",
+		"staging/src/sample-apiserver/s_test.go": "",
+		"test/e2e/helper.go":                     "",
+		"test/e2e/framework/f.go":                "",
+		"test/images/sample-x/x.go":              "",
+		"test/ctest/c_test.go":                   "",
+		"root_test.go":                           "",
+	})
+	got := relFiles(t, &Walker{Rules: RepoTests, Base: root}, root, root, ".go")
+	want := []string{"pkg/p_test.go", "root_test.go", "test/e2e/helper.go", "test/images/sample-x/x.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RepoTests walk = %v, want %v", got, want)
+	}
+	// Walking a subtree keeps the rules relative to the root
+	got = relFiles(t, &Walker{Rules: RepoTests, Base: root}, root, filepath.Join(root, "api"), ".go")
+	if got != nil {
+		t.Errorf("walk of api = %v, want none", got)
+	}
+}
+
+func TestRewriteNames(t *testing.T) {
+	original := filepath.Join("test", "e2e", "job_test.go")
+	rewrite := RewriteOf(original)
+	if rewrite != filepath.Join("test", "e2e", "ctest_job_test.go") || !IsRewrite(rewrite) || IsRewrite(original) {
+		t.Errorf("RewriteOf(%s) = %s", original, rewrite)
+	}
+	if got := OriginalOf(rewrite); got != original {
+		t.Errorf("OriginalOf(%s) = %s, want %s", rewrite, got, original)
+	}
+}
@@ -15,7 +15,12 @@ func parseYAMLFile(path string) ([]K8sObject, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseYAML(path, data), nil
+}
 
+// ParseYAML decodes the documents of data, the content of the file path, and
+// returns the objects of the included kinds.
+func ParseYAML(path string, data []byte) []K8sObject {
 	var objects []K8sObject
 	docs := strings.Split(string(data), "---")
 
@@ -50,7 +55,7 @@ func parseYAMLFile(path string) ([]K8sObject, error) {
 		})
 	}
 
-	return objects, nil
+	return objects
 }
 
 // extractObjectName extracts the name from a Kubernetes object